@@ -0,0 +1,505 @@
+package smartsplitwise
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aanzolaavila/splitwise.go/resources"
+)
+
+type AuditEntry struct {
+	Sequence  uint64             `json:"seq"`
+	Timestamp time.Time          `json:"timestamp"`
+	Caller    string             `json:"caller,omitempty"`
+	Operation string             `json:"operation"`
+	ExpenseID int                `json:"expense_id,omitempty"`
+	Request   json.RawMessage    `json:"request,omitempty"`
+	Response  json.RawMessage    `json:"response,omitempty"`
+	Error     string             `json:"error,omitempty"`
+	Before    *resources.Expense `json:"before,omitempty"`
+	After     *resources.Expense `json:"after,omitempty"`
+	PrevHash  string             `json:"prev_hash"`
+	Hash      string             `json:"hash"`
+}
+
+// Auditor receives one entry for every write operation performed through a
+// SwConnection.
+type Auditor interface {
+	Record(entry AuditEntry) error
+}
+
+type callerKey struct{}
+
+// WithCaller tags ctx with the identity recorded as the caller of the write
+// operations of a connection opened with it.
+func WithCaller(ctx context.Context, caller string) context.Context {
+	return context.WithValue(ctx, callerKey{}, caller)
+}
+
+func callerFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	caller, _ := ctx.Value(callerKey{}).(string)
+	return caller
+}
+
+type auditRecord struct {
+	conn  *swConnectionStruct
+	entry AuditEntry
+}
+
+func (conn *swConnectionStruct) beginAudit(operation string, expenseID int, request interface{}) *auditRecord {
+	if conn.auditor == nil {
+		return nil
+	}
+
+	record := &auditRecord{
+		conn: conn,
+		entry: AuditEntry{
+			Timestamp: time.Now().UTC(),
+			Caller:    callerFromContext(conn.ctx),
+			Operation: operation,
+			ExpenseID: expenseID,
+		},
+	}
+
+	if content, err := json.Marshal(request); err == nil {
+		record.entry.Request = content
+	}
+
+	// the cached expense may be stale, so the state before the write is
+	// always read from Splitwise
+	if expenseID != 0 {
+		client := conn.getClient()
+		if before, err := client.GetExpense(conn.ctx, expenseID); err == nil {
+			record.entry.Before = &before
+		}
+	}
+
+	return record
+}
+
+func (record *auditRecord) finish(response []resources.Expense, err error) {
+	if record == nil {
+		return
+	}
+
+	if err != nil {
+		record.entry.Error = err.Error()
+	}
+
+	if response != nil {
+		if content, err := json.Marshal(response); err == nil {
+			record.entry.Response = content
+		}
+		if record.entry.ExpenseID == 0 && len(response) > 0 {
+			record.entry.ExpenseID = int(response[0].ID)
+		}
+	}
+
+	if record.entry.ExpenseID != 0 {
+		if after, err := record.conn.GetExpense(record.entry.ExpenseID); err == nil {
+			record.entry.After = &after
+		}
+	}
+
+	if err := record.conn.auditor.Record(record.entry); err != nil {
+		record.conn.logger.Error("unable to record audit entry", slog.String("operation", record.entry.Operation), slog.String("error", err.Error()))
+	}
+}
+
+type AuditChainError struct {
+	File     string
+	Sequence uint64
+	Reason   string
+}
+
+func (e *AuditChainError) Error() string {
+	return fmt.Sprintf("audit log %s: entry %d: %s", e.File, e.Sequence, e.Reason)
+}
+
+// AuditLog is an append-only JSON Lines Auditor. Every entry stores the hash
+// of the previous one, so any edit or removal breaks the chain. When the
+// file grows past MaxBytes it is renamed with the last sequence number as
+// suffix and the chain continues in a new file.
+type AuditLog struct {
+	// Logger, when set, logs the rotations that failed after an entry was
+	// written.
+	Logger *slog.Logger
+
+	path     string
+	maxBytes int64
+
+	mu       sync.Mutex
+	file     *os.File
+	size     int64
+	seq      uint64
+	lastHash string
+}
+
+func OpenAuditLog(path string, maxBytes int64) (*AuditLog, error) {
+	l := &AuditLog{
+		path:     path,
+		maxBytes: maxBytes,
+	}
+
+	if err := trimPartialLine(path); err != nil {
+		return nil, err
+	}
+
+	files, err := auditLogFiles(path)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(files) - 1; i >= 0; i-- {
+		last, err := lastAuditEntry(files[i])
+		if err != nil {
+			return nil, err
+		}
+		if last != nil {
+			l.seq = last.Sequence
+			l.lastHash = last.Hash
+			break
+		}
+	}
+
+	if err := l.openFile(); err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+func (l *AuditLog) Record(entry AuditEntry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return os.ErrClosed
+	}
+
+	entry.Sequence = l.seq + 1
+	entry.PrevHash = l.lastHash
+	entry.Hash = ""
+
+	hash, err := hashAuditEntry(entry)
+	if err != nil {
+		return err
+	}
+	entry.Hash = hash
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	if _, err := l.file.Write(line); err != nil {
+		return err
+	}
+
+	l.seq = entry.Sequence
+	l.lastHash = entry.Hash
+	l.size += int64(len(line))
+
+	// the entry is already written, so a failed rotation is only logged and
+	// retried on the next one
+	if l.maxBytes > 0 && l.size >= l.maxBytes {
+		if err := l.rotate(); err != nil && l.Logger != nil {
+			l.Logger.Error("unable to rotate audit log", slog.String("path", l.path), slog.String("error", err.Error()))
+		}
+	}
+
+	return nil
+}
+
+func (l *AuditLog) Rotate() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.rotate()
+}
+
+func (l *AuditLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return nil
+	}
+
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+func (l *AuditLog) rotate() error {
+	if l.size == 0 {
+		return nil
+	}
+
+	err := l.file.Close()
+	l.file = nil
+	if err == nil {
+		err = os.Rename(l.path, fmt.Sprintf("%s.%020d", l.path, l.seq))
+	}
+
+	// keep appending to the active file when it could not be renamed
+	if openErr := l.openFile(); err == nil {
+		err = openErr
+	}
+	return err
+}
+
+func (l *AuditLog) openFile() error {
+	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	l.file = f
+	l.size = info.Size()
+	return nil
+}
+
+// trimPartialLine drops what follows the last newline of the file at path,
+// the remains of an entry whose write was cut short by a crash.
+func trimPartialLine(path string) error {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	end := info.Size()
+	buf := make([]byte, 4096)
+	for end > 0 {
+		n := int64(len(buf))
+		if n > end {
+			n = end
+		}
+		if _, err := f.ReadAt(buf[:n], end-n); err != nil {
+			return err
+		}
+
+		if i := bytes.LastIndexByte(buf[:n], '\n'); i >= 0 {
+			end -= n - int64(i) - 1
+			break
+		}
+		end -= n
+	}
+
+	if end == info.Size() {
+		return nil
+	}
+	return f.Truncate(end)
+}
+
+func hashAuditEntry(entry AuditEntry) (string, error) {
+	entry.Hash = ""
+
+	content, err := json.Marshal(entry)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// auditLogFiles returns the rotated files in chain order followed by the
+// active file, skipping the ones that do not exist.
+func auditLogFiles(path string) ([]string, error) {
+	rotated, err := filepath.Glob(path + ".*")
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, f := range rotated {
+		suffix := f[len(path)+1:]
+		if len(suffix) == 20 && isDigits(suffix) {
+			files = append(files, f)
+		}
+	}
+	sort.Strings(files)
+
+	if _, err := os.Stat(path); err == nil {
+		files = append(files, path)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func readAuditFile(file string, fn func(entry AuditEntry) error) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		entry := AuditEntry{}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return fmt.Errorf("audit log %s: %w", file, err)
+		}
+
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
+
+func lastAuditEntry(file string) (*AuditEntry, error) {
+	var last *AuditEntry
+	err := readAuditFile(file, func(entry AuditEntry) error {
+		last = &entry
+		return nil
+	})
+	return last, err
+}
+
+// VerifyAuditLog checks the hash chain across the rotated files and the
+// active file at path, returning an *AuditChainError at the first broken
+// link.
+func VerifyAuditLog(path string) error {
+	files, err := auditLogFiles(path)
+	if err != nil {
+		return err
+	}
+
+	var (
+		seq      uint64
+		lastHash string
+	)
+
+	for _, file := range files {
+		err := readAuditFile(file, func(entry AuditEntry) error {
+			if entry.Sequence != seq+1 {
+				return &AuditChainError{File: file, Sequence: entry.Sequence, Reason: fmt.Sprintf("expected sequence %d", seq+1)}
+			}
+
+			if entry.PrevHash != lastHash {
+				return &AuditChainError{File: file, Sequence: entry.Sequence, Reason: "previous hash mismatch"}
+			}
+
+			hash, err := hashAuditEntry(entry)
+			if err != nil {
+				return err
+			}
+			if hash != entry.Hash {
+				return &AuditChainError{File: file, Sequence: entry.Sequence, Reason: "hash mismatch"}
+			}
+
+			seq = entry.Sequence
+			lastHash = entry.Hash
+			return nil
+		})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type AuditQuery struct {
+	Since     time.Time
+	Until     time.Time
+	Caller    string
+	Operation string
+	ExpenseID int
+}
+
+func (q AuditQuery) matches(entry AuditEntry) bool {
+	if !q.Since.IsZero() && entry.Timestamp.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && !entry.Timestamp.Before(q.Until) {
+		return false
+	}
+	if q.Caller != "" && entry.Caller != q.Caller {
+		return false
+	}
+	if q.Operation != "" && entry.Operation != q.Operation {
+		return false
+	}
+	if q.ExpenseID != 0 && entry.ExpenseID != q.ExpenseID {
+		return false
+	}
+	return true
+}
+
+// ReadAuditLog returns the entries matching query, oldest first, from the
+// rotated files and the active file at path.
+func ReadAuditLog(path string, query AuditQuery) ([]AuditEntry, error) {
+	files, err := auditLogFiles(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []AuditEntry
+	for _, file := range files {
+		err := readAuditFile(file, func(entry AuditEntry) error {
+			if query.matches(entry) {
+				entries = append(entries, entry)
+			}
+			return nil
+		})
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,224 @@
+package smartsplitwise
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aanzolaavila/splitwise.go"
+	"github.com/dcerbino-golib/smartsplitwise/splitwisetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func getWriteDoFunc(t *testing.T) func(r *http.Request) (*http.Response, error) {
+	return func(r *http.Request) (*http.Response, error) {
+		resposne := http.Response{}
+		resposne.Header = make(map[string][]string)
+		resposne.Header["Content-Type"] = []string{"application/json", "charset=utf-8"}
+		resposne.Status = "200"
+		resposne.StatusCode = 200
+
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/get_expense/12345"):
+			resposne.Body = io.NopCloser(strings.NewReader(testExpence))
+		case strings.HasSuffix(r.URL.Path, "/update_expense/12345"), strings.HasSuffix(r.URL.Path, "/create_expense"):
+			content := strings.Replace(testExpence, `"expense":`, `"expenses":[`, 1)
+			content = strings.TrimSuffix(strings.TrimSpace(content), "}") + "]}"
+			resposne.Body = io.NopCloser(strings.NewReader(content))
+		case strings.HasSuffix(r.URL.Path, "/delete_expense/12345"):
+			resposne.Body = io.NopCloser(strings.NewReader(`{"success":true}`))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			resposne.Status = "404"
+			resposne.StatusCode = 404
+			resposne.Body = io.NopCloser(strings.NewReader(`{"errors":{"base":["not found"]}}`))
+		}
+
+		return &resposne, nil
+	}
+}
+
+func TestAuditWriteOperations(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	auditLog, err := OpenAuditLog(path, 0)
+	assert.NoError(t, err)
+	defer auditLog.Close()
+
+	conn := getClientMockedConnection(t, getWriteDoFunc(t))
+	conn.(*swConnectionStruct).ctx = WithCaller(context.Background(), "alice")
+	conn.SetAuditor(auditLog)
+
+	expenses, err := conn.CreateExpenseEqualGroupSplit(2048.49, "TelViso", 12345, splitwise.CreateExpenseParams{})
+	assert.NoError(t, err)
+	assert.Len(t, expenses, 1)
+
+	_, err = conn.UpdateExpense(12345, 2048.49, "TelViso", 12345, nil, nil)
+	assert.NoError(t, err)
+
+	err = conn.DeleteExpense(12345)
+	assert.NoError(t, err)
+
+	entries, err := ReadAuditLog(path, AuditQuery{})
+	assert.NoError(t, err)
+	assert.Len(t, entries, 3)
+
+	assert.Equal(t, "create_expense_equal_group_split", entries[0].Operation)
+	assert.Equal(t, 12345, entries[0].ExpenseID)
+	assert.Nil(t, entries[0].Before)
+	assert.NotNil(t, entries[0].After)
+	assert.Contains(t, string(entries[0].Request), `"description":"TelViso"`)
+
+	assert.Equal(t, "update_expense", entries[1].Operation)
+	assert.Equal(t, "alice", entries[1].Caller)
+	assert.NotNil(t, entries[1].Before)
+	assert.Equal(t, "TelViso", entries[1].Before.Description)
+	assert.NotEmpty(t, entries[1].Response)
+
+	assert.Equal(t, "delete_expense", entries[2].Operation)
+	assert.Equal(t, entries[1].Hash, entries[2].PrevHash)
+
+	deletes, err := ReadAuditLog(path, AuditQuery{Operation: "delete_expense"})
+	assert.NoError(t, err)
+	assert.Len(t, deletes, 1)
+
+	assert.NoError(t, VerifyAuditLog(path))
+}
+
+func TestAuditLogRotationAndVerify(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	auditLog, err := OpenAuditLog(path, 300)
+	assert.NoError(t, err)
+
+	for i := 0; i < 6; i++ {
+		assert.NoError(t, auditLog.Record(AuditEntry{Operation: "delete_expense", ExpenseID: i + 1}))
+	}
+	assert.NoError(t, auditLog.Close())
+
+	rotated, err := filepath.Glob(path + ".*")
+	assert.NoError(t, err)
+	assert.NotEmpty(t, rotated)
+	assert.NoError(t, VerifyAuditLog(path))
+
+	reopened, err := OpenAuditLog(path, 0)
+	assert.NoError(t, err)
+	assert.NoError(t, reopened.Record(AuditEntry{Operation: "restore_expense", ExpenseID: 1}))
+	assert.NoError(t, reopened.Close())
+
+	entries, err := ReadAuditLog(path, AuditQuery{ExpenseID: 1})
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, uint64(7), entries[1].Sequence)
+	assert.NoError(t, VerifyAuditLog(path))
+
+	content, err := os.ReadFile(rotated[0])
+	assert.NoError(t, err)
+	tampered := strings.Replace(string(content), `"expense_id":1,`, `"expense_id":2,`, 1)
+	assert.NoError(t, os.WriteFile(rotated[0], []byte(tampered), 0o600))
+
+	err = VerifyAuditLog(path)
+	chainErr := &AuditChainError{}
+	assert.ErrorAs(t, err, &chainErr)
+	assert.Equal(t, uint64(1), chainErr.Sequence)
+}
+
+func TestAuditBeforeBypassesCache(t *testing.T) {
+	server := splitwisetest.NewServer()
+	defer server.Close()
+
+	home := server.AddGroup("Home")
+
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	auditLog, err := OpenAuditLog(path, 0)
+	assert.NoError(t, err)
+	defer auditLog.Close()
+
+	other := OpenWithOptions(WithBaseURL(server.URL, ""), WithLogger(getTestLogger(t)))
+	conn := OpenWithOptions(
+		WithBaseURL(server.URL, ""),
+		WithLogger(getTestLogger(t)),
+		WithCache(NewMemoryCache(0), DefaultCacheTTLs),
+	)
+	conn.SetAuditor(auditLog)
+
+	created, err := other.CreateExpenseEqualGroupSplit(30, "Groceries", int(home.ID), nil)
+	assert.NoError(t, err)
+	id := int(created[0].ID)
+
+	_, err = conn.GetExpense(id)
+	assert.NoError(t, err)
+	_, err = other.UpdateExpense(id, 30, "Market", int(home.ID), nil, nil)
+	assert.NoError(t, err)
+
+	_, err = conn.UpdateExpense(id, 30, "Supermarket", int(home.ID), nil, nil)
+	assert.NoError(t, err)
+
+	entries, err := ReadAuditLog(path, AuditQuery{Operation: "update_expense"})
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 1) && assert.NotNil(t, entries[0].Before) {
+		assert.Equal(t, "Market", entries[0].Before.Description)
+		assert.Equal(t, "Supermarket", entries[0].After.Description)
+	}
+}
+
+func TestOpenAuditLogTrimsPartialLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	auditLog, err := OpenAuditLog(path, 0)
+	assert.NoError(t, err)
+	assert.NoError(t, auditLog.Record(AuditEntry{Operation: "delete_expense", ExpenseID: 1}))
+	assert.NoError(t, auditLog.Record(AuditEntry{Operation: "delete_expense", ExpenseID: 2}))
+	assert.NoError(t, auditLog.Close())
+
+	// a crash halfway through writing the third entry
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+	assert.NoError(t, err)
+	_, err = f.WriteString(`{"seq":3,"timestamp":"2023-`)
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+
+	reopened, err := OpenAuditLog(path, 0)
+	assert.NoError(t, err)
+	assert.NoError(t, reopened.Record(AuditEntry{Operation: "restore_expense", ExpenseID: 1}))
+	assert.NoError(t, reopened.Close())
+
+	entries, err := ReadAuditLog(path, AuditQuery{})
+	assert.NoError(t, err)
+	assert.Len(t, entries, 3)
+	assert.Equal(t, uint64(3), entries[2].Sequence)
+	assert.NoError(t, VerifyAuditLog(path))
+}
+
+func TestAuditRotationFailureIsLogged(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	auditLog, err := OpenAuditLog(path, 1)
+	assert.NoError(t, err)
+	defer auditLog.Close()
+
+	var logs bytes.Buffer
+	auditLog.Logger = slog.New(slog.NewTextHandler(&logs, nil))
+
+	// a directory in the way of the rotated file makes the rename fail
+	blocked := fmt.Sprintf("%s.%020d", path, 1)
+	assert.NoError(t, os.MkdirAll(filepath.Join(blocked, "keep"), 0o700))
+
+	assert.NoError(t, auditLog.Record(AuditEntry{Operation: "delete_expense", ExpenseID: 1}))
+	assert.Contains(t, logs.String(), "unable to rotate audit log")
+
+	assert.NoError(t, os.RemoveAll(blocked))
+	assert.NoError(t, auditLog.Record(AuditEntry{Operation: "delete_expense", ExpenseID: 2}))
+
+	rotated, err := filepath.Glob(path + ".*")
+	assert.NoError(t, err)
+	assert.Len(t, rotated, 1)
+
+	entries, err := ReadAuditLog(path, AuditQuery{})
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+	assert.NoError(t, VerifyAuditLog(path))
+}
@@ -0,0 +1,128 @@
+package smartsplitwise
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aanzolaavila/splitwise.go"
+	"github.com/dcerbino-golib/smartsplitwise/backup"
+)
+
+// backupPageSize is the page size used to stream the expenses.
+const backupPageSize = 100
+
+// Backup writes the full state of the account behind conn to w as a backup
+// archive: the current user, groups, friends, every expense with its
+// comments, deleted ones included, notifications, categories and
+// currencies. It returns the number of records written by kind, and fails
+// without writing the footer when any of them cannot be read in full.
+func Backup(conn SwConnection, w io.Writer, compress bool) (map[backup.Kind]int, error) {
+	user, err := conn.GetCurrentUser()
+	if err != nil {
+		return nil, fmt.Errorf("backup current user: %w", err)
+	}
+
+	bw, err := backup.NewWriter(w, compress, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if err := bw.Write(backup.KindUser, user); err != nil {
+		return nil, err
+	}
+
+	if err := writeAll(bw, backup.KindGroup, conn.GetGroups()); err != nil {
+		return nil, err
+	}
+	if err := writeAll(bw, backup.KindFriend, conn.GetFriends()); err != nil {
+		return nil, err
+	}
+
+	params, err := NewExpensesQuery().Limit(backupPageSize).Params()
+	if err != nil {
+		return nil, err
+	}
+	expenses := conn.GetExpenses(params)
+	defer expenses.Close()
+	for e := range expenses.GetChan() {
+		if err := bw.Write(backup.KindExpense, e); err != nil {
+			return nil, err
+		}
+		if e.CommentsCount == 0 {
+			continue
+		}
+
+		comments, err := conn.GetExpenseComments(int(e.ID))
+		if err != nil {
+			return nil, fmt.Errorf("backup comments of expense %d: %w", e.ID, err)
+		}
+		for _, c := range comments {
+			if err := bw.WriteComment(int(e.ID), c); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := expenses.Err(); err != nil {
+		return nil, fmt.Errorf("backup %s: %w", backup.KindExpense, err)
+	}
+
+	// a zero limit asks for every notification
+	notifications := conn.GetNotifications(splitwise.NotificationsParams{splitwise.NotificationsLimit: 0})
+	if err := writeAll(bw, backup.KindNotification, notifications); err != nil {
+		return nil, err
+	}
+	if err := writeAll(bw, backup.KindCategory, conn.GetMainCategories()); err != nil {
+		return nil, err
+	}
+	if err := writeAll(bw, backup.KindCurrency, conn.GetCurecies()); err != nil {
+		return nil, err
+	}
+
+	if err := bw.Close(); err != nil {
+		return nil, err
+	}
+	return bw.Counts(), nil
+}
+
+// BackupFile writes a backup to path, gzip-compressed when it ends in
+// ".gz". The file is replaced only once the backup is complete.
+func BackupFile(conn SwConnection, path string) (map[backup.Kind]int, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+
+	counts, err := Backup(conn, tmp, strings.HasSuffix(path, ".gz"))
+	if err != nil {
+		tmp.Close()
+		return nil, err
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, err
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// writeAll writes every entity of ce, failing when ce does so that the
+// footer is never written for a partial read.
+func writeAll[T splitwiseResouces](bw *backup.Writer, kind backup.Kind, ce CommandExecutor[T]) error {
+	defer ce.Close()
+
+	for v := range ce.GetChan() {
+		if err := bw.Write(kind, v); err != nil {
+			return err
+		}
+	}
+	if err := ce.Err(); err != nil {
+		return fmt.Errorf("backup %s: %w", kind, err)
+	}
+	return nil
+}
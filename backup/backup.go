@@ -0,0 +1,336 @@
+// Package backup reads and writes account backups as JSON Lines archives,
+// optionally gzip-compressed.
+//
+// An archive starts with a header record carrying the format version, has
+// one record per entity, and ends with a footer carrying the number of
+// records of each kind, so that a truncated archive is detected:
+//
+//	{"type":"header","version":1,"created_at":"2024-03-01T03:00:00Z"}
+//	{"type":"user","data":{"id":1,"first_name":"Test",...}}
+//	{"type":"expense","data":{"id":4,"cost":"25.00",...}}
+//	{"type":"comment","expense_id":4,"data":{"id":9,"content":"paid",...}}
+//	{"type":"footer","counts":{"expense":1,"comment":1,"user":1}}
+package backup
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/aanzolaavila/splitwise.go/resources"
+)
+
+// Version is the format version written in the header. Readers accept
+// archives up to this version.
+const Version = 1
+
+type Kind string
+
+const (
+	KindHeader       Kind = "header"
+	KindUser         Kind = "user"
+	KindGroup        Kind = "group"
+	KindFriend       Kind = "friend"
+	KindExpense      Kind = "expense"
+	KindComment      Kind = "comment"
+	KindNotification Kind = "notification"
+	KindCategory     Kind = "category"
+	KindCurrency     Kind = "currency"
+	KindFooter       Kind = "footer"
+)
+
+var (
+	ErrTruncated = errors.New("backup archive is truncated")
+	ErrCorrupted = errors.New("backup archive is corrupted")
+)
+
+type UnsupportedVersionError struct {
+	Version int
+}
+
+func (e *UnsupportedVersionError) Error() string {
+	return fmt.Sprintf("unsupported backup version %d, expected at most %d", e.Version, Version)
+}
+
+// Record is a line of an archive. Data holds the entity, and ExpenseID the
+// expense a comment belongs to.
+type Record struct {
+	Type      Kind            `json:"type"`
+	Version   int             `json:"version,omitempty"`
+	CreatedAt *time.Time      `json:"created_at,omitempty"`
+	ExpenseID int             `json:"expense_id,omitempty"`
+	Data      json.RawMessage `json:"data,omitempty"`
+	Counts    map[Kind]int    `json:"counts,omitempty"`
+}
+
+// Writer streams records to an archive. Close must be called to write the
+// footer; it does not close the underlying writer.
+type Writer struct {
+	buf    *bufio.Writer
+	gz     *gzip.Writer
+	enc    *json.Encoder
+	counts map[Kind]int
+}
+
+// NewWriter writes the header of an archive created at createdAt to w.
+func NewWriter(w io.Writer, compress bool, createdAt time.Time) (*Writer, error) {
+	bw := &Writer{counts: map[Kind]int{}}
+	if compress {
+		bw.gz = gzip.NewWriter(w)
+		w = bw.gz
+	}
+	bw.buf = bufio.NewWriter(w)
+	bw.enc = json.NewEncoder(bw.buf)
+
+	createdAt = createdAt.UTC()
+	if err := bw.enc.Encode(Record{Type: KindHeader, Version: Version, CreatedAt: &createdAt}); err != nil {
+		return nil, err
+	}
+	return bw, nil
+}
+
+// Write adds an entity of the given kind.
+func (w *Writer) Write(kind Kind, v interface{}) error {
+	return w.write(Record{Type: kind}, v)
+}
+
+func (w *Writer) WriteComment(expenseID int, c resources.Comment) error {
+	return w.write(Record{Type: KindComment, ExpenseID: expenseID}, c)
+}
+
+func (w *Writer) write(r Record, v interface{}) error {
+	if r.Type == KindHeader || r.Type == KindFooter {
+		return fmt.Errorf("cannot write a %s record", r.Type)
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("encode %s: %w", r.Type, err)
+	}
+	r.Data = data
+
+	if err := w.enc.Encode(r); err != nil {
+		return err
+	}
+	w.counts[r.Type]++
+	return nil
+}
+
+// Counts returns the number of records written so far by kind.
+func (w *Writer) Counts() map[Kind]int {
+	counts := make(map[Kind]int, len(w.counts))
+	for k, n := range w.counts {
+		counts[k] = n
+	}
+	return counts
+}
+
+// Close writes the footer and flushes the archive.
+func (w *Writer) Close() error {
+	if err := w.enc.Encode(Record{Type: KindFooter, Counts: w.Counts()}); err != nil {
+		return err
+	}
+	if err := w.buf.Flush(); err != nil {
+		return err
+	}
+	if w.gz != nil {
+		return w.gz.Close()
+	}
+	return nil
+}
+
+// Reader reads the records of an archive, compressed or not.
+type Reader struct {
+	dec       *json.Decoder
+	version   int
+	createdAt time.Time
+	counts    map[Kind]int
+	done      bool
+}
+
+// NewReader reads the header of the archive in r, detecting gzip from its
+// magic bytes.
+func NewReader(r io.Reader) (*Reader, error) {
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrCorrupted, err)
+		}
+		r = gz
+	} else {
+		r = br
+	}
+
+	reader := &Reader{dec: json.NewDecoder(r), counts: map[Kind]int{}}
+
+	var header Record
+	if err := reader.dec.Decode(&header); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("%w: no header", ErrTruncated)
+		}
+		return nil, fmt.Errorf("%w: %v", ErrCorrupted, err)
+	}
+	if header.Type != KindHeader {
+		return nil, fmt.Errorf("%w: expected a header, got %q", ErrCorrupted, header.Type)
+	}
+	if header.Version < 1 || header.Version > Version {
+		return nil, &UnsupportedVersionError{Version: header.Version}
+	}
+
+	reader.version = header.Version
+	if header.CreatedAt != nil {
+		reader.createdAt = *header.CreatedAt
+	}
+	return reader, nil
+}
+
+func (r *Reader) Version() int {
+	return r.version
+}
+
+func (r *Reader) CreatedAt() time.Time {
+	return r.createdAt
+}
+
+// Next returns the next record, or io.EOF once the footer is read and
+// matches the records read.
+func (r *Reader) Next() (Record, error) {
+	if r.done {
+		return Record{}, io.EOF
+	}
+
+	var rec Record
+	if err := r.dec.Decode(&rec); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return Record{}, fmt.Errorf("%w: no footer", ErrTruncated)
+		}
+		return Record{}, fmt.Errorf("%w: %v", ErrCorrupted, err)
+	}
+
+	switch rec.Type {
+	case KindHeader:
+		return Record{}, fmt.Errorf("%w: unexpected header", ErrCorrupted)
+	case KindFooter:
+		r.done = true
+		for _, kind := range sortedKinds(rec.Counts, r.counts) {
+			if rec.Counts[kind] != r.counts[kind] {
+				return Record{}, fmt.Errorf("%w: footer counts %d %s records, read %d", ErrTruncated, rec.Counts[kind], kind, r.counts[kind])
+			}
+		}
+		return Record{}, io.EOF
+	}
+
+	r.counts[rec.Type]++
+	return rec, nil
+}
+
+// Archive is a backup loaded in memory.
+type Archive struct {
+	Version       int
+	CreatedAt     time.Time
+	CurrentUser   resources.User
+	Groups        []resources.Group
+	Friends       []resources.Friend
+	Expenses      []resources.Expense
+	Comments      map[int][]resources.Comment
+	Notifications []resources.Notification
+	Categories    []resources.MainCategory
+	Currencies    []resources.Currency
+}
+
+// Load reads a whole archive. Records of unknown kinds, written by a newer
+// minor revision of the format, are skipped.
+func Load(r io.Reader) (*Archive, error) {
+	reader, err := NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &Archive{
+		Version:   reader.Version(),
+		CreatedAt: reader.CreatedAt(),
+		Comments:  map[int][]resources.Comment{},
+	}
+
+	for {
+		rec, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return a, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if err := a.add(rec); err != nil {
+			return nil, fmt.Errorf("%w: %s record: %v", ErrCorrupted, rec.Type, err)
+		}
+	}
+}
+
+// LoadFile loads the archive at path.
+func LoadFile(path string) (*Archive, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return Load(f)
+}
+
+func (a *Archive) add(rec Record) error {
+	switch rec.Type {
+	case KindUser:
+		return json.Unmarshal(rec.Data, &a.CurrentUser)
+	case KindGroup:
+		return appendDecoded(rec.Data, &a.Groups)
+	case KindFriend:
+		return appendDecoded(rec.Data, &a.Friends)
+	case KindExpense:
+		return appendDecoded(rec.Data, &a.Expenses)
+	case KindNotification:
+		return appendDecoded(rec.Data, &a.Notifications)
+	case KindCategory:
+		return appendDecoded(rec.Data, &a.Categories)
+	case KindCurrency:
+		return appendDecoded(rec.Data, &a.Currencies)
+	case KindComment:
+		var c resources.Comment
+		if err := json.Unmarshal(rec.Data, &c); err != nil {
+			return err
+		}
+		a.Comments[rec.ExpenseID] = append(a.Comments[rec.ExpenseID], c)
+	}
+	return nil
+}
+
+func appendDecoded[T any](data json.RawMessage, list *[]T) error {
+	var v T
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*list = append(*list, v)
+	return nil
+}
+
+func sortedKinds(maps ...map[Kind]int) []Kind {
+	seen := map[Kind]bool{}
+	kinds := []Kind{}
+	for _, m := range maps {
+		for k := range m {
+			if !seen[k] {
+				seen[k] = true
+				kinds = append(kinds, k)
+			}
+		}
+	}
+	sort.Slice(kinds, func(i, j int) bool { return kinds[i] < kinds[j] })
+	return kinds
+}
@@ -0,0 +1,128 @@
+package backup_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aanzolaavila/splitwise.go/resources"
+	"github.com/dcerbino-golib/smartsplitwise/backup"
+	"github.com/stretchr/testify/assert"
+)
+
+var createdAt = time.Date(2024, 3, 1, 3, 0, 0, 0, time.UTC)
+
+func writeArchive(t *testing.T, compress bool) []byte {
+	var buf bytes.Buffer
+	w, err := backup.NewWriter(&buf, compress, createdAt)
+	assert.NoError(t, err)
+
+	rent := resources.Expense{ID: 4, CommentsCount: 1}
+	rent.Description = "Rent"
+	rent.Cost = "100.00"
+	deleted := resources.Expense{ID: 5, DeletedAt: "2024-02-01T10:00:00Z"}
+	deleted.Description = "Wrong"
+
+	for _, set := range []func() error{
+		func() error { return w.Write(backup.KindUser, resources.User{ID: 1, FirstName: "Test"}) },
+		func() error { return w.Write(backup.KindGroup, resources.Group{ID: 3, Name: "Familia"}) },
+		func() error { return w.Write(backup.KindFriend, resources.Friend{ID: 2, FirstName: "Ana"}) },
+		func() error { return w.Write(backup.KindExpense, rent) },
+		func() error { return w.WriteComment(4, resources.Comment{ID: 9, Content: "paid in cash"}) },
+		func() error { return w.Write(backup.KindExpense, deleted) },
+		func() error { return w.Write(backup.KindCurrency, resources.Currency{CurrencyCode: "ARS", Unit: "$"}) },
+	} {
+		assert.NoError(t, set())
+	}
+	assert.NoError(t, w.Close())
+	assert.Equal(t, 2, w.Counts()[backup.KindExpense])
+
+	return buf.Bytes()
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, compress := range []bool{false, true} {
+		content := writeArchive(t, compress)
+		if !compress {
+			lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+			assert.Len(t, lines, 9)
+			assert.JSONEq(t, `{"type":"header","version":1,"created_at":"2024-03-01T03:00:00Z"}`, lines[0])
+			assert.JSONEq(t, `{"type":"footer","counts":{"user":1,"group":1,"friend":1,"expense":2,"comment":1,"currency":1}}`, lines[8])
+		}
+
+		a, err := backup.Load(bytes.NewReader(content))
+		assert.NoError(t, err, "compress %v", compress)
+		assert.Equal(t, backup.Version, a.Version)
+		assert.Equal(t, createdAt, a.CreatedAt)
+		assert.Equal(t, "Test", a.CurrentUser.FirstName)
+		assert.Equal(t, "Familia", a.Groups[0].Name)
+		assert.Equal(t, "Ana", a.Friends[0].FirstName)
+		assert.Len(t, a.Expenses, 2)
+		assert.Equal(t, "2024-02-01T10:00:00Z", a.Expenses[1].DeletedAt)
+		assert.Equal(t, "paid in cash", a.Comments[4][0].Content)
+		assert.Equal(t, "$", a.Currencies[0].Unit)
+		assert.Empty(t, a.Notifications)
+	}
+}
+
+func TestTruncatedArchive(t *testing.T) {
+	content := writeArchive(t, false)
+	lines := strings.SplitAfter(string(content), "\n")
+
+	// without the footer
+	_, err := backup.Load(strings.NewReader(strings.Join(lines[:8], "")))
+	assert.ErrorIs(t, err, backup.ErrTruncated)
+
+	// without a record the footer counts
+	_, err = backup.Load(strings.NewReader(strings.Join(append(lines[:4:4], lines[5:]...), "")))
+	assert.ErrorIs(t, err, backup.ErrTruncated)
+	assert.ErrorContains(t, err, "footer counts 2 expense records, read 1")
+
+	compressed := writeArchive(t, true)
+	_, err = backup.Load(bytes.NewReader(compressed[:len(compressed)/2]))
+	assert.ErrorIs(t, err, backup.ErrTruncated)
+
+	_, err = backup.Load(strings.NewReader(""))
+	assert.ErrorIs(t, err, backup.ErrTruncated)
+}
+
+func TestReaderRejects(t *testing.T) {
+	_, err := backup.NewReader(strings.NewReader(`{"type":"header","version":2}` + "\n"))
+	var version *backup.UnsupportedVersionError
+	assert.True(t, errors.As(err, &version))
+	assert.Equal(t, 2, version.Version)
+
+	_, err = backup.NewReader(strings.NewReader(`{"type":"user","data":{}}` + "\n"))
+	assert.ErrorIs(t, err, backup.ErrCorrupted)
+
+	_, err = backup.Load(strings.NewReader(`{"type":"header","version":1}` + "\n" + `{"type":"expense","data":{"id":"x"}}` + "\n"))
+	assert.ErrorIs(t, err, backup.ErrCorrupted)
+}
+
+func TestReaderSkipsUnknownKinds(t *testing.T) {
+	records := []backup.Record{
+		{Type: backup.KindHeader, Version: 1},
+		{Type: "reaction", Data: json.RawMessage(`{}`)},
+		{Type: backup.KindFooter, Counts: map[backup.Kind]int{"reaction": 1}},
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, r := range records {
+		assert.NoError(t, enc.Encode(r))
+	}
+
+	r, err := backup.NewReader(bytes.NewReader(buf.Bytes()))
+	assert.NoError(t, err)
+	rec, err := r.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, backup.Kind("reaction"), rec.Type)
+	_, err = r.Next()
+	assert.Equal(t, io.EOF, err)
+
+	_, err = backup.Load(bytes.NewReader(buf.Bytes()))
+	assert.NoError(t, err)
+}
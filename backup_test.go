@@ -0,0 +1,127 @@
+package smartsplitwise
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aanzolaavila/splitwise.go"
+	"github.com/aanzolaavila/splitwise.go/resources"
+	"github.com/dcerbino-golib/smartsplitwise/backup"
+	"github.com/dcerbino-golib/smartsplitwise/splitwisetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackupRestoresIntoServer(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	server := newFamiliaServer(t, "Rent", now)
+
+	_, err := server.AddComment(4, "paid in cash")
+	assert.NoError(t, err)
+	_, err = server.AddExpense(3, "Wrong", "10", now,
+		splitwisetest.Share{UserID: 1, Paid: "10", Owed: "10"},
+	)
+	assert.NoError(t, err)
+
+	conn := OpenWithOptions(WithBaseURL(server.URL, ""))
+	assert.NoError(t, conn.DeleteExpense(6))
+
+	path := filepath.Join(t.TempDir(), "account.jsonl.gz")
+	counts, err := BackupFile(conn, path)
+	assert.NoError(t, err)
+	assert.Equal(t, map[backup.Kind]int{
+		backup.KindUser:         1,
+		backup.KindGroup:        1,
+		backup.KindFriend:       1,
+		backup.KindExpense:      2,
+		backup.KindComment:      1,
+		backup.KindNotification: 1,
+		backup.KindCategory:     2,
+		backup.KindCurrency:     3,
+	}, counts)
+
+	archive, err := backup.LoadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "paid in cash", archive.Comments[4][0].Content)
+
+	restored := splitwisetest.NewServer()
+	defer restored.Close()
+	restored.LoadArchive(archive)
+	restoredConn := OpenWithOptions(WithBaseURL(restored.URL, ""))
+
+	for _, c := range []SwConnection{conn, restoredConn} {
+		user, err := c.GetCurrentUser()
+		assert.NoError(t, err)
+		assert.Equal(t, archive.CurrentUser, user)
+	}
+
+	// the executors move the offset of the params they are given
+	expenses, err := Collect(conn.GetExpenses(splitwise.ExpensesParams{}))
+	assert.NoError(t, err)
+	assert.Len(t, expenses, 2)
+	restoredExpenses, err := Collect(restoredConn.GetExpenses(splitwise.ExpensesParams{}))
+	assert.NoError(t, err)
+	assert.Equal(t, expenses, restoredExpenses)
+
+	group, err := conn.GetGroup(3)
+	assert.NoError(t, err)
+	restoredGroup, err := restoredConn.GetGroup(3)
+	assert.NoError(t, err)
+	assert.Equal(t, group, restoredGroup)
+
+	friends := collect(conn.GetFriends())
+	assert.Equal(t, friends, collect(restoredConn.GetFriends()))
+	assert.Equal(t, collect(conn.GetNotifications(nil)), collect(restoredConn.GetNotifications(nil)))
+
+	comments, err := restoredConn.GetExpenseComments(4)
+	assert.NoError(t, err)
+	assert.Equal(t, archive.Comments[4], comments)
+
+	// new writes do not reuse the IDs of the archive
+	created, err := restoredConn.CreateExpenseEqualGroupSplit(20, "Dinner", 3, nil)
+	assert.NoError(t, err)
+	assert.Greater(t, int(created[0].ID), 7)
+}
+
+func TestBackupFileKeepsPreviousOnFailure(t *testing.T) {
+	server := splitwisetest.NewServer()
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "account.jsonl")
+	_, err := BackupFile(OpenWithOptions(WithBaseURL(server.URL, "")), path)
+	assert.NoError(t, err)
+
+	server.Inject(splitwisetest.Unauthorized())
+	_, err = BackupFile(OpenWithOptions(WithBaseURL(server.URL, "")), path)
+	assert.Error(t, err)
+
+	archive, err := backup.LoadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, resources.UserID(1), archive.CurrentUser.ID)
+	assert.Empty(t, archive.Expenses)
+}
+
+func collect[T splitwiseResouces](ce CommandExecutor[T]) []T {
+	var list []T
+	for v := range ce.GetChan() {
+		list = append(list, v)
+	}
+	return list
+}
+
+func TestBackupFailsOnPartialRead(t *testing.T) {
+	for _, path := range []string{"/get_groups", "/get_friends", "/get_expenses", "/get_notifications", "/get_categories"} {
+		server := newFamiliaServer(t, "Rent", time.Now())
+		fault := splitwisetest.ServerError()
+		fault.Path = path
+		server.Inject(fault)
+
+		var buf bytes.Buffer
+		_, err := Backup(OpenWithOptions(WithBaseURL(server.URL, "")), &buf, false)
+		assert.Error(t, err, path)
+
+		_, err = backup.Load(&buf)
+		assert.ErrorIs(t, err, backup.ErrTruncated, "no footer should vouch for the partial archive of %s", path)
+	}
+}
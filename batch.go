@@ -0,0 +1,65 @@
+package smartsplitwise
+
+import (
+	"sync"
+
+	"github.com/aanzolaavila/splitwise.go/resources"
+)
+
+const DefaultConcurrency = 4
+
+// WithConcurrency bounds the requests run in parallel by the batch getters.
+// The rate limiter, if any, still applies to each of them.
+func WithConcurrency(workers int) Option {
+	return func(o *connOptions) {
+		o.concurrency = workers
+	}
+}
+
+type BatchResult[T any] struct {
+	ID    int
+	Value T
+	Err   error
+}
+
+// fetchAll calls fetch for every ID over at most workers goroutines and
+// returns the results in the order of ids.
+func fetchAll[T any](ids []int, workers int, fetch func(id int) (T, error)) []BatchResult[T] {
+	results := make([]BatchResult[T], len(ids))
+	if workers < 1 {
+		workers = 1
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers && w < len(ids); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				value, err := fetch(ids[i])
+				results[i] = BatchResult[T]{ID: ids[i], Value: value, Err: err}
+			}
+		}()
+	}
+
+	for i := range ids {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	return results
+}
+
+func (conn *swConnectionStruct) GetExpensesByID(ids []int) []BatchResult[resources.Expense] {
+	return fetchAll(ids, conn.concurrency, conn.GetExpense)
+}
+
+func (conn *swConnectionStruct) GetGroupsByID(ids []int) []BatchResult[resources.Group] {
+	return fetchAll(ids, conn.concurrency, conn.GetGroup)
+}
+
+func (conn *swConnectionStruct) GetFriendsByID(ids []int) []BatchResult[resources.Friend] {
+	return fetchAll(ids, conn.concurrency, conn.GetFriend)
+}
@@ -0,0 +1,58 @@
+package smartsplitwise
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetExpensesByID(t *testing.T) {
+	var inFlight, maxInFlight int32
+	doFunc := func(r *http.Request) (*http.Response, error) {
+		current := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if current <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, current) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+
+		id := path.Base(r.URL.Path)
+		resposne := http.Response{}
+		resposne.StatusCode = 200
+		resposne.Body = io.NopCloser(strings.NewReader(fmt.Sprintf(`{"expense": {"id": %s, "description": "expense %s"}}`, id, id)))
+		if id == "13" {
+			resposne.StatusCode = 404
+			resposne.Body = io.NopCloser(strings.NewReader(`{"errors": {"base": ["Invalid expense"]}}`))
+		}
+		return &resposne, nil
+	}
+
+	conn := OpenWithOptions(WithHTTPClient(httpClientStub{DoFunc: doFunc}), WithConcurrency(2))
+
+	ids := []int{10, 11, 12, 13, 14, 15}
+	results := conn.GetExpensesByID(ids)
+
+	assert.Len(t, results, len(ids))
+	for i, r := range results {
+		assert.Equal(t, ids[i], r.ID)
+		if r.ID == 13 {
+			assert.Error(t, r.Err)
+			continue
+		}
+		assert.NoError(t, r.Err)
+		assert.Equal(t, fmt.Sprintf("expense %d", r.ID), r.Value.Description)
+	}
+	assert.Equal(t, int32(2), maxInFlight)
+
+	assert.Empty(t, conn.GetGroupsByID(nil))
+}
@@ -0,0 +1,378 @@
+package smartsplitwise
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/aanzolaavila/splitwise.go"
+	"github.com/aanzolaavila/splitwise.go/resources"
+)
+
+// Cache stores encoded entities by key. Implementations must be safe for
+// concurrent use.
+type Cache interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, value []byte, ttl time.Duration)
+	Delete(key string)
+}
+
+// CacheTTLs sets how long each kind of entity is served from the cache.
+// A zero TTL disables the cache for that kind.
+type CacheTTLs struct {
+	Friend      time.Duration
+	Group       time.Duration
+	Expense     time.Duration
+	CurrentUser time.Duration
+}
+
+var DefaultCacheTTLs = CacheTTLs{
+	Friend:      5 * time.Minute,
+	Group:       5 * time.Minute,
+	Expense:     10 * time.Minute,
+	CurrentUser: time.Hour,
+}
+
+// etagTTL bounds how long a response body is kept to revalidate it with
+// If-None-Match.
+const etagTTL = 24 * time.Hour
+
+// WithCache keeps the friends, groups and expenses fetched one by one in
+// cache, and revalidates the GET responses carrying an ETag instead of
+// downloading them again. Writes through the connection invalidate the
+// entries of the expense, group and friends they touch. Entries are kept per
+// token, so connections of several accounts can share one cache.
+func WithCache(cache Cache, ttls CacheTTLs) Option {
+	return func(o *connOptions) {
+		o.cache = cache
+		o.ttls = ttls
+	}
+}
+
+type memoryEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// MemoryCache is an in-memory LRU cache.
+type MemoryCache struct {
+	maxEntries int
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+// NewMemoryCache returns a cache evicting the least recently used entry
+// beyond maxEntries. Zero means no limit.
+func NewMemoryCache(maxEntries int) *MemoryCache {
+	return &MemoryCache{
+		maxEntries: maxEntries,
+		order:      list.New(),
+		entries:    make(map[string]*list.Element),
+	}
+}
+
+func (c *MemoryCache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := elem.Value.(*memoryEntry)
+	if !entry.expires.IsZero() && time.Now().After(entry.expires) {
+		c.order.Remove(elem)
+		delete(c.entries, key)
+		return nil, false
+	}
+
+	c.order.MoveToFront(elem)
+	return entry.value, true
+}
+
+// Set keeps value for ttl, or until evicted when ttl is zero.
+func (c *MemoryCache) Set(key string, value []byte, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := &memoryEntry{key: key, value: value}
+	if ttl > 0 {
+		entry.expires = time.Now().Add(ttl)
+	}
+
+	if elem, ok := c.entries[key]; ok {
+		elem.Value = entry
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(entry)
+	if c.maxEntries > 0 && c.order.Len() > c.maxEntries {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*memoryEntry).key)
+	}
+}
+
+func (c *MemoryCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		c.order.Remove(elem)
+		delete(c.entries, key)
+	}
+}
+
+func (c *MemoryCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.order.Len()
+}
+
+// DiskCache stores one file per entry in Dir, so the cache survives the
+// process.
+type DiskCache struct {
+	Dir string
+}
+
+type diskEntry struct {
+	Expires time.Time `json:"expires"`
+	Value   []byte    `json:"value"`
+}
+
+func (c DiskCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.Dir, hex.EncodeToString(sum[:]))
+}
+
+func (c DiskCache) Get(key string) ([]byte, bool) {
+	content, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return nil, false
+	}
+
+	entry := diskEntry{}
+	if err := json.Unmarshal(content, &entry); err != nil {
+		return nil, false
+	}
+	if !entry.Expires.IsZero() && time.Now().After(entry.Expires) {
+		os.Remove(c.path(key))
+		return nil, false
+	}
+	return entry.Value, true
+}
+
+func (c DiskCache) Set(key string, value []byte, ttl time.Duration) {
+	entry := diskEntry{Value: value}
+	if ttl > 0 {
+		entry.Expires = time.Now().Add(ttl)
+	}
+
+	content, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+
+	if err := os.MkdirAll(c.Dir, 0o700); err != nil {
+		return
+	}
+
+	tmp, err := os.CreateTemp(c.Dir, ".entry.*")
+	if err != nil {
+		return
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return
+	}
+	if err := tmp.Close(); err != nil {
+		return
+	}
+
+	os.Rename(tmp.Name(), c.path(key))
+}
+
+func (c DiskCache) Delete(key string) {
+	os.Remove(c.path(key))
+}
+
+// cacheScope hashes the token of the connection, like etagClient does with
+// the Authorization header, so that the entities of different accounts stay
+// apart when the cache is shared.
+func (conn *swConnectionStruct) cacheScope() string {
+	token := conn.client.Token
+	if conn.tokenSource != nil {
+		if t, err := conn.tokenSource.Token(); err == nil {
+			token = t.AccessToken
+		}
+	}
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:8])
+}
+
+func cacheKey(scope string, resource string, id int) string {
+	return fmt.Sprintf("%s:%s:%d", scope, resource, id)
+}
+
+// cached returns the entity at key from the connection cache, fetching and
+// storing it for ttl on a miss.
+func cached[T any](conn *swConnectionStruct, key string, ttl time.Duration, fetch func() (T, error)) (T, error) {
+	if conn.cache == nil || ttl <= 0 {
+		return fetch()
+	}
+
+	var value T
+	if content, ok := conn.cache.Get(key); ok {
+		if err := json.Unmarshal(content, &value); err == nil {
+			return value, nil
+		}
+		conn.cache.Delete(key)
+	}
+
+	value, err := fetch()
+	if err != nil {
+		return value, err
+	}
+
+	if content, err := json.Marshal(value); err == nil {
+		conn.cache.Set(key, content, ttl)
+	}
+	return value, nil
+}
+
+func (conn *swConnectionStruct) invalidate(keys ...string) {
+	if conn.cache == nil {
+		return
+	}
+
+	for _, key := range keys {
+		conn.cache.Delete(key)
+	}
+}
+
+// expenseKeys returns the cache keys of e and of the group and friends whose
+// balances change with it.
+func expenseKeys(scope string, e resources.Expense) []string {
+	keys := []string{cacheKey(scope, "expense", int(e.ID))}
+	if e.GroupId != 0 {
+		keys = append(keys, cacheKey(scope, "group", int(e.GroupId)))
+	}
+	for _, u := range e.Users {
+		keys = append(keys, cacheKey(scope, "friend", int(u.UserId)))
+	}
+	return keys
+}
+
+// writeKeys collects the keys touched by a write on expense id: the ones of
+// its cached state before the write and the ones of the write itself.
+func (conn *swConnectionStruct) writeKeys(id int, groupId int, users []splitwise.ExpenseUser, expenses []resources.Expense) []string {
+	var keys []string
+	if conn.cache == nil {
+		return keys
+	}
+	scope := conn.cacheScope()
+
+	if id != 0 {
+		keys = append(keys, cacheKey(scope, "expense", id))
+		if content, ok := conn.cache.Get(cacheKey(scope, "expense", id)); ok {
+			before := resources.Expense{}
+			if json.Unmarshal(content, &before) == nil {
+				keys = append(keys, expenseKeys(scope, before)...)
+			}
+		}
+	}
+
+	if groupId != 0 {
+		keys = append(keys, cacheKey(scope, "group", groupId))
+	}
+	for _, u := range users {
+		keys = append(keys, cacheKey(scope, "friend", int(u.Id)))
+	}
+	for _, e := range expenses {
+		keys = append(keys, expenseKeys(scope, e)...)
+	}
+
+	return keys
+}
+
+// etagClient revalidates cached GET responses with If-None-Match and turns
+// a 304 back into the stored response.
+type etagClient struct {
+	cache Cache
+	next  HTTPClient
+}
+
+type etagEntry struct {
+	ETag        string `json:"etag"`
+	ContentType string `json:"content_type"`
+	Body        []byte `json:"body"`
+}
+
+func (c etagClient) Do(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		return c.next.Do(req)
+	}
+
+	// the Authorization header keeps the responses of different accounts
+	// apart when the cache is shared
+	sum := sha256.Sum256([]byte(req.Header.Get("Authorization") + " " + req.URL.String()))
+	key := "etag:" + hex.EncodeToString(sum[:])
+
+	entry := etagEntry{}
+	if content, ok := c.cache.Get(key); ok && json.Unmarshal(content, &entry) == nil && entry.ETag != "" {
+		req.Header.Set("If-None-Match", entry.ETag)
+	} else {
+		entry = etagEntry{}
+	}
+
+	res, err := c.next.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode == http.StatusNotModified && entry.ETag != "" {
+		io.Copy(io.Discard, res.Body)
+		res.Body.Close()
+
+		res.StatusCode = http.StatusOK
+		res.Status = "200 OK"
+		res.Header.Set("Content-Type", entry.ContentType)
+		res.Body = io.NopCloser(bytes.NewReader(entry.Body))
+		return res, nil
+	}
+
+	etag := res.Header.Get("ETag")
+	if res.StatusCode != http.StatusOK || etag == "" {
+		return res, nil
+	}
+
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = io.NopCloser(bytes.NewReader(body))
+
+	content, err := json.Marshal(etagEntry{ETag: etag, ContentType: res.Header.Get("Content-Type"), Body: body})
+	if err == nil {
+		c.cache.Set(key, content, etagTTL)
+	}
+	return res, nil
+}
@@ -0,0 +1,192 @@
+package smartsplitwise
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := NewMemoryCache(2)
+
+	cache.Set("a", []byte("1"), 0)
+	cache.Set("b", []byte("2"), 0)
+	_, ok := cache.Get("a")
+	assert.True(t, ok)
+
+	cache.Set("c", []byte("3"), 0)
+	assert.Equal(t, 2, cache.Len())
+
+	_, ok = cache.Get("b")
+	assert.False(t, ok)
+	_, ok = cache.Get("a")
+	assert.True(t, ok)
+	_, ok = cache.Get("c")
+	assert.True(t, ok)
+}
+
+func TestDiskCache(t *testing.T) {
+	cache := DiskCache{Dir: t.TempDir()}
+
+	cache.Set("group:1", []byte(`{"id":1}`), 0)
+	cache.Set("group:2", []byte(`{"id":2}`), time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+
+	value, ok := DiskCache{Dir: cache.Dir}.Get("group:1")
+	assert.True(t, ok)
+	assert.Equal(t, []byte(`{"id":1}`), value)
+
+	_, ok = cache.Get("group:2")
+	assert.False(t, ok)
+
+	cache.Delete("group:1")
+	_, ok = cache.Get("group:1")
+	assert.False(t, ok)
+}
+
+func TestETagRevalidation(t *testing.T) {
+	var notModified int32
+	doFunc := func(r *http.Request) (*http.Response, error) {
+		resposne := http.Response{}
+		resposne.Header = make(map[string][]string)
+		resposne.Header["Content-Type"] = []string{"application/json", "charset=utf-8"}
+
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			atomic.AddInt32(&notModified, 1)
+			resposne.Body = io.NopCloser(strings.NewReader(""))
+			resposne.Status = "304"
+			resposne.StatusCode = 304
+			return &resposne, nil
+		}
+
+		resposne.Header["Etag"] = []string{`"v1"`}
+		resposne.Body = io.NopCloser(strings.NewReader(testExpence))
+		resposne.Status = "200"
+		resposne.StatusCode = 200
+		return &resposne, nil
+	}
+
+	// entity TTLs of zero leave the revalidation to the ETags
+	conn := OpenWithOptions(
+		WithHTTPClient(httpClientStub{DoFunc: doFunc}),
+		WithCache(NewMemoryCache(0), CacheTTLs{}),
+	)
+
+	first, err := conn.GetExpense(2123851796)
+	assert.NoError(t, err)
+	second, err := conn.GetExpense(2123851796)
+	assert.NoError(t, err)
+
+	assert.Equal(t, first, second)
+	assert.Equal(t, "TelViso", second.Description)
+	assert.Equal(t, int32(1), notModified)
+}
+
+func TestWriteInvalidatesGroupAndFriends(t *testing.T) {
+	doFunc := func(r *http.Request) (*http.Response, error) {
+		body := testExpence
+		if r.Method == http.MethodPost {
+			body = `{"success": true}`
+		}
+
+		resposne := http.Response{}
+		resposne.Body = io.NopCloser(strings.NewReader(body))
+		resposne.Header = make(map[string][]string)
+		resposne.Header["Content-Type"] = []string{"application/json", "charset=utf-8"}
+		resposne.Status = "200"
+		resposne.StatusCode = 200
+		return &resposne, nil
+	}
+
+	cache := NewMemoryCache(0)
+	conn := OpenWithOptions(
+		WithHTTPClient(httpClientStub{DoFunc: doFunc}),
+		WithCache(cache, DefaultCacheTTLs),
+	)
+
+	_, err := conn.GetExpense(2123851796)
+	assert.NoError(t, err)
+
+	scope := conn.(*swConnectionStruct).cacheScope()
+	cache.Set(scope+":group:12345", []byte("{}"), 0)
+	cache.Set(scope+":friend:21702157", []byte("{}"), 0)
+	cache.Set(scope+":friend:1", []byte("{}"), 0)
+
+	assert.NoError(t, conn.DeleteExpense(2123851796))
+
+	for _, key := range []string{"expense:2123851796", "group:12345", "friend:21702157"} {
+		_, ok := cache.Get(scope + ":" + key)
+		assert.False(t, ok, key)
+	}
+	_, ok := cache.Get(scope + ":friend:1")
+	assert.True(t, ok)
+}
+
+func TestSharedCacheKeepsAccountsApart(t *testing.T) {
+	doFunc := func(r *http.Request) (*http.Response, error) {
+		name := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+		resposne := http.Response{}
+		resposne.Body = io.NopCloser(strings.NewReader(`{"group": {"id": 3, "name": "` + name + `"}}`))
+		resposne.Header = make(map[string][]string)
+		resposne.Header["Content-Type"] = []string{"application/json", "charset=utf-8"}
+		resposne.Status = "200"
+		resposne.StatusCode = 200
+		return &resposne, nil
+	}
+
+	cache := NewMemoryCache(0)
+	for _, token := range []string{"diego", "ana", "diego"} {
+		conn := OpenWithOptions(
+			WithToken(token),
+			WithHTTPClient(httpClientStub{DoFunc: doFunc}),
+			WithCache(cache, DefaultCacheTTLs),
+		)
+
+		group, err := conn.GetGroup(3)
+		assert.NoError(t, err)
+		assert.Equal(t, token, group.Name)
+	}
+	assert.Equal(t, 2, cache.Len())
+}
+
+func TestCurrentUserPerConnection(t *testing.T) {
+	var calls int32
+	doFunc := func(r *http.Request) (*http.Response, error) {
+		atomic.AddInt32(&calls, 1)
+
+		resposne := http.Response{}
+		resposne.Body = io.NopCloser(strings.NewReader(testUser))
+		resposne.Header = make(map[string][]string)
+		resposne.Header["Content-Type"] = []string{"application/json", "charset=utf-8"}
+		resposne.Status = "200"
+		resposne.StatusCode = 200
+		return &resposne, nil
+	}
+
+	first := OpenWithOptions(WithHTTPClient(httpClientStub{DoFunc: doFunc}))
+	second := OpenWithOptions(
+		WithHTTPClient(httpClientStub{DoFunc: doFunc}),
+		WithCache(NewMemoryCache(0), CacheTTLs{CurrentUser: time.Millisecond}),
+	)
+
+	_, err := first.GetCurrentUser()
+	assert.NoError(t, err)
+	_, err = first.GetCurrentUser()
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), calls)
+
+	_, err = second.GetCurrentUser()
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), calls)
+
+	time.Sleep(5 * time.Millisecond)
+	_, err = second.GetCurrentUser()
+	assert.NoError(t, err)
+	assert.Equal(t, int32(3), calls)
+}
@@ -0,0 +1,257 @@
+// Package cassette records the HTTP interactions of a connection with
+// Splitwise and replays them in tests, so the library and the projects
+// using it can be tested without the network.
+//
+// A recorder is plugged in with smartsplitwise.WithHTTPClient. Recorded
+// interactions are scrubbed of tokens, emails and avatars before they are
+// saved.
+package cassette
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+type Mode int
+
+const (
+	// ModeReplay answers every request from the cassette and never touches
+	// the network.
+	ModeReplay Mode = iota
+	// ModeRecord sends every request to the real client and keeps the
+	// interaction for Save.
+	ModeRecord
+)
+
+type HTTPClient interface {
+	Do(*http.Request) (*http.Response, error)
+}
+
+type Request struct {
+	Method string `yaml:"method"`
+	Path   string `yaml:"path"`
+	Query  string `yaml:"query,omitempty"`
+	Body   string `yaml:"body,omitempty"`
+}
+
+type Response struct {
+	StatusCode int               `yaml:"status_code"`
+	Header     map[string]string `yaml:"header,omitempty"`
+	Body       string            `yaml:"body"`
+}
+
+type Interaction struct {
+	Request  Request  `yaml:"request"`
+	Response Response `yaml:"response"`
+}
+
+type Cassette struct {
+	Interactions []Interaction `yaml:"interactions"`
+}
+
+// NoInteractionError is returned in replay mode for a request the cassette
+// does not have an unused interaction for.
+type NoInteractionError struct {
+	Method string
+	Path   string
+	Query  string
+}
+
+func (e *NoInteractionError) Error() string {
+	return fmt.Sprintf("cassette: no interaction for %s %s?%s", e.Method, e.Path, e.Query)
+}
+
+// recordedHeaders are the response headers kept on the cassette; the rest,
+// cookies included, are dropped.
+var recordedHeaders = []string{"Content-Type", "Etag", "Retry-After"}
+
+// Recorder is an HTTP client recording to or replaying from the cassette at
+// Path.
+type Recorder struct {
+	Path     string
+	Mode     Mode
+	Real     HTTPClient
+	Scrubber *Scrubber
+
+	mu       sync.Mutex
+	cassette Cassette
+	used     []bool
+}
+
+// New opens the cassette at path. In replay mode the file must exist; in
+// record mode the requests go through real, or http.DefaultClient if nil.
+func New(path string, mode Mode, real HTTPClient) (*Recorder, error) {
+	r := &Recorder{Path: path, Mode: mode, Real: real, Scrubber: DefaultScrubber()}
+	if r.Real == nil {
+		r.Real = http.DefaultClient
+	}
+
+	if mode == ModeReplay {
+		cassette, err := Load(path)
+		if err != nil {
+			return nil, err
+		}
+		r.cassette = cassette
+		r.used = make([]bool, len(cassette.Interactions))
+	}
+
+	return r, nil
+}
+
+func Load(path string) (Cassette, error) {
+	cassette := Cassette{}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return cassette, err
+	}
+
+	err = yaml.Unmarshal(content, &cassette)
+	return cassette, err
+}
+
+func (r *Recorder) Do(req *http.Request) (*http.Response, error) {
+	if r.Mode == ModeRecord {
+		return r.record(req)
+	}
+	return r.replay(req)
+}
+
+func (r *Recorder) replay(req *http.Request) (*http.Response, error) {
+	query := req.URL.Query().Encode()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, interaction := range r.cassette.Interactions {
+		if r.used[i] || !matches(interaction.Request, req.Method, req.URL.Path, query) {
+			continue
+		}
+		r.used[i] = true
+
+		return interaction.Response.toHTTP(req), nil
+	}
+
+	return nil, &NoInteractionError{Method: req.Method, Path: req.URL.Path, Query: query}
+}
+
+func matches(recorded Request, method, path, query string) bool {
+	return recorded.Method == method && recorded.Path == path && recorded.Query == query
+}
+
+func (r *Recorder) record(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil {
+		var err error
+		reqBody, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(reqBody))
+	}
+
+	res, err := r.Real.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resBody, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = io.NopCloser(bytes.NewReader(resBody))
+
+	interaction := Interaction{
+		Request: Request{
+			Method: req.Method,
+			Path:   req.URL.Path,
+			Query:  req.URL.Query().Encode(),
+			Body:   string(reqBody),
+		},
+		Response: Response{
+			StatusCode: res.StatusCode,
+			Header:     map[string]string{},
+			Body:       string(resBody),
+		},
+	}
+	for _, name := range recordedHeaders {
+		if value := res.Header.Get(name); value != "" {
+			interaction.Response.Header[name] = value
+		}
+	}
+	if r.Scrubber != nil {
+		interaction = r.Scrubber.Scrub(interaction)
+	}
+
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, interaction)
+	r.mu.Unlock()
+
+	return res, nil
+}
+
+// Save writes the recorded interactions to Path. It does nothing in replay
+// mode.
+func (r *Recorder) Save() error {
+	if r.Mode != ModeRecord {
+		return nil
+	}
+
+	r.mu.Lock()
+	content, err := yaml.Marshal(r.cassette)
+	r.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(r.Path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(r.Path, content, 0o644)
+}
+
+// Unused returns the interactions not replayed yet, to check a test made
+// every request it was recorded with.
+func (r *Recorder) Unused() []Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	unused := []Interaction{}
+	if r.Mode != ModeReplay {
+		return unused
+	}
+	for i, interaction := range r.cassette.Interactions {
+		if !r.used[i] {
+			unused = append(unused, interaction)
+		}
+	}
+	return unused
+}
+
+func (res Response) toHTTP(req *http.Request) *http.Response {
+	header := http.Header{}
+	for name, value := range res.Header {
+		header.Set(name, value)
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", res.StatusCode, http.StatusText(res.StatusCode)),
+		StatusCode:    res.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader([]byte(res.Body))),
+		ContentLength: int64(len(res.Body)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,87 @@
+package cassette
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dcerbino-golib/smartsplitwise"
+	"github.com/stretchr/testify/assert"
+)
+
+const testFriend = `{"friend":{"id":21679690,"first_name":"test2","last_name":"test","email":"test2@test.com","picture":{"medium":"https://s3.amazonaws.com/splitwise/uploads/user/default_avatars/avatar-orange1-100px.png"},"balance":[{"currency_code":"ARS","amount":"-541.96"}],"groups":[]}}`
+
+const testGroup = `{"group":{"id":11741221,"name":"Familia","invite_link":"https://www.splitwise.com/join/DoD65gHhn9w+cvgzh","members":[{"id":21623741,"first_name":"test1","email":"test@test.com"},{"id":21679690,"first_name":"test2","email":"test2@test.com"}]}}`
+
+func TestRecordAndReplay(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Set-Cookie", "session=secret")
+		switch r.URL.Path {
+		case "/api/v3.0/get_friend/21679690":
+			w.Write([]byte(testFriend))
+		case "/api/v3.0/get_group/11741221":
+			w.Write([]byte(testGroup))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer upstream.Close()
+
+	path := filepath.Join(t.TempDir(), "cassettes", "friend.yaml")
+
+	recorder, err := New(path, ModeRecord, nil)
+	assert.NoError(t, err)
+	recorder.Scrubber.Secrets = []string{"secret-token"}
+
+	conn := smartsplitwise.OpenWithOptions(
+		smartsplitwise.WithToken("secret-token"),
+		smartsplitwise.WithHTTPClient(recorder),
+		smartsplitwise.WithBaseURL(upstream.URL, ""),
+	)
+	recorded, err := conn.GetFriend(21679690)
+	assert.NoError(t, err)
+	_, err = conn.GetGroup(11741221)
+	assert.NoError(t, err)
+	assert.NoError(t, recorder.Save())
+
+	content, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(content), "test2@test.com")
+	assert.NotContains(t, string(content), "avatar-orange1")
+	assert.NotContains(t, string(content), "DoD65gHhn9w")
+	assert.NotContains(t, string(content), "secret")
+	assert.Contains(t, string(content), "user1@example.com")
+	assert.Contains(t, string(content), "user2@example.com")
+
+	replayer, err := New(path, ModeReplay, nil)
+	assert.NoError(t, err)
+
+	// the base URL does not take part in the match, only method, path and
+	// query
+	conn = smartsplitwise.OpenWithOptions(
+		smartsplitwise.WithHTTPClient(replayer),
+		smartsplitwise.WithBaseURL("http://offline.invalid", ""),
+	)
+	replayed, err := conn.GetFriend(21679690)
+	assert.NoError(t, err)
+	assert.Equal(t, recorded.ID, replayed.ID)
+	assert.Equal(t, recorded.FirstName, replayed.FirstName)
+	assert.Equal(t, "user1@example.com", replayed.Email)
+	assert.Len(t, replayer.Unused(), 1)
+
+	_, err = conn.GetFriend(21679690)
+	noInteraction := &NoInteractionError{}
+	assert.True(t, errors.As(err, &noInteraction))
+	assert.Equal(t, "/api/v3.0/get_friend/21679690", noInteraction.Path)
+}
+
+func TestScrubQuery(t *testing.T) {
+	scrubber := DefaultScrubber()
+
+	interaction := scrubber.Scrub(Interaction{Request: Request{Query: "access_token=abc&email=test@test.com&limit=20"}})
+	assert.Equal(t, "access_token=REDACTED&email=user1%40example.com&limit=20", interaction.Request.Query)
+}
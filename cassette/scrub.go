@@ -0,0 +1,96 @@
+package cassette
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const (
+	Redacted     = "REDACTED"
+	ScrubbedURL  = "https://example.com/scrubbed.png"
+	emailPattern = `[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`
+)
+
+var (
+	emailRegexp = regexp.MustCompile(emailPattern)
+	// pictures, receipts and group covers are all served from uploads
+	avatarRegexp = regexp.MustCompile(`https?://[^"\s]*(?:avatar|uploads)[^"\s]*`)
+	secretRegexp = regexp.MustCompile(`"(access_token|refresh_token|token|invite_link)"(\s*:\s*)"[^"]*"`)
+)
+
+// secretParams are the query parameters replaced on the recorded requests.
+var secretParams = []string{"access_token", "token", "client_secret", "code"}
+
+// Scrubber removes personal data and credentials from the interactions
+// before they are saved. Each email is replaced by a distinct placeholder
+// so that recorded users stay apart.
+type Scrubber struct {
+	// Secrets are replaced anywhere they appear, such as the token the
+	// cassette was recorded with.
+	Secrets []string
+
+	mu     sync.Mutex
+	emails map[string]string
+}
+
+func DefaultScrubber() *Scrubber {
+	return &Scrubber{}
+}
+
+func (s *Scrubber) Scrub(interaction Interaction) Interaction {
+	interaction.Request.Query = s.scrubQuery(interaction.Request.Query)
+	interaction.Request.Body = s.scrubText(interaction.Request.Body)
+	interaction.Response.Body = s.scrubText(interaction.Response.Body)
+	return interaction
+}
+
+func (s *Scrubber) scrubText(text string) string {
+	for _, secret := range s.Secrets {
+		if secret != "" {
+			text = strings.ReplaceAll(text, secret, Redacted)
+		}
+	}
+
+	text = secretRegexp.ReplaceAllString(text, `"$1"$2"`+Redacted+`"`)
+	text = avatarRegexp.ReplaceAllString(text, ScrubbedURL)
+	return emailRegexp.ReplaceAllStringFunc(text, s.email)
+}
+
+func (s *Scrubber) scrubQuery(query string) string {
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return s.scrubText(query)
+	}
+
+	for _, name := range secretParams {
+		if values.Has(name) {
+			values.Set(name, Redacted)
+		}
+	}
+	for name, list := range values {
+		for i := range list {
+			list[i] = s.scrubText(list[i])
+		}
+		values[name] = list
+	}
+	return values.Encode()
+}
+
+func (s *Scrubber) email(address string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.emails == nil {
+		s.emails = make(map[string]string)
+	}
+	if placeholder, ok := s.emails[address]; ok {
+		return placeholder
+	}
+
+	placeholder := fmt.Sprintf("user%d@example.com", len(s.emails)+1)
+	s.emails[address] = placeholder
+	return placeholder
+}
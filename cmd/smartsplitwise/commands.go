@@ -0,0 +1,435 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aanzolaavila/splitwise.go"
+	"github.com/aanzolaavila/splitwise.go/resources"
+	"github.com/dcerbino-golib/smartsplitwise"
+	"github.com/dcerbino-golib/smartsplitwise/backup"
+)
+
+type runFunc func(conn smartsplitwise.SwConnection, args []string) (result, error)
+
+// startFunc runs a command that takes over the terminal or keeps serving
+// until interrupted, instead of rendering a result.
+type startFunc func(conn smartsplitwise.SwConnection, cfg config) error
+
+// command registers its flags on the given set and returns the function
+// running it once the flags are parsed. Long running commands have no setup
+// and a start function instead.
+type command struct {
+	name  string
+	usage string
+	setup func(fs *flag.FlagSet) runFunc
+	start func(fs *flag.FlagSet) startFunc
+}
+
+var commands = []command{
+	{"me", "show the current user", noFlags(runMe), nil},
+	{"groups", "list the groups", noFlags(runGroups), nil},
+	{"group", "show a group and its debts: group <id>", noFlags(runGroup), nil},
+	{"friends", "list the friends and balances", noFlags(runFriends), nil},
+	{"expenses", "list expenses, see -h for filters", expensesFlags, nil},
+	{"notifications", "list recent notifications", notificationsFlags, nil},
+	{"currencies", "list the supported currencies", noFlags(runCurrencies), nil},
+	{"categories", "list the expense categories", noFlags(runCategories), nil},
+	{"backup", "back up the whole account to a file, see -h", backupFlags, nil},
+	{"migrate", "recreate the expenses of a group in another one, see -h", migrateFlags, nil},
+	{"tui", "browse groups and expenses interactively", nil, noFlagsStart(runTUI)},
+	{"serve", "serve the local JSON gateway, see -h", nil, serveFlags},
+}
+
+func noFlags(run runFunc) func(fs *flag.FlagSet) runFunc {
+	return func(fs *flag.FlagSet) runFunc {
+		return run
+	}
+}
+
+func noFlagsStart(start startFunc) func(fs *flag.FlagSet) startFunc {
+	return func(fs *flag.FlagSet) startFunc {
+		return start
+	}
+}
+
+func runMe(conn smartsplitwise.SwConnection, args []string) (result, error) {
+	user, err := conn.GetCurrentUser()
+	if err != nil {
+		return result{}, err
+	}
+
+	return result{
+		columns: []string{"ID", "NAME", "EMAIL", "CURRENCY", "LOCALE"},
+		rows: [][]string{{
+			strconv.FormatUint(uint64(user.ID), 10),
+			fullName(user.FirstName, user.LastName),
+			user.Email,
+			user.DefaultCurrency,
+			user.Locale,
+		}},
+		data: user,
+	}, nil
+}
+
+func runGroups(conn smartsplitwise.SwConnection, args []string) (result, error) {
+	r := result{columns: []string{"ID", "NAME", "TYPE", "MEMBERS", "UPDATED"}}
+
+	groups := []resources.Group{}
+	executor := conn.GetGroups()
+	for g := range executor.GetChan() {
+		groups = append(groups, g)
+		r.rows = append(r.rows, []string{
+			strconv.FormatUint(uint64(g.ID), 10),
+			g.Name,
+			g.Type,
+			strconv.Itoa(len(g.Members)),
+			formatTime(g.UpdatedAt),
+		})
+	}
+	if err := executor.Err(); err != nil {
+		return result{}, err
+	}
+	r.data = groups
+
+	return r, nil
+}
+
+func runGroup(conn smartsplitwise.SwConnection, args []string) (result, error) {
+	if len(args) != 1 {
+		return result{}, fmt.Errorf("usage: group <id>")
+	}
+
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		return result{}, fmt.Errorf("invalid group id %q", args[0])
+	}
+
+	group, err := conn.GetGroup(id)
+	if err != nil {
+		return result{}, err
+	}
+
+	names := make(map[int]string, len(group.Members))
+	for _, m := range group.Members {
+		names[int(m.ID)] = fullName(m.FirstName, m.LastName)
+	}
+
+	r := result{
+		columns: []string{"FROM", "TO", "AMOUNT", "CURRENCY"},
+		data:    group,
+	}
+	for _, d := range group.OriginalDebts {
+		r.rows = append(r.rows, []string{memberName(names, d.From), memberName(names, d.To), d.Amount, d.CurrencyCode})
+	}
+
+	return r, nil
+}
+
+func runFriends(conn smartsplitwise.SwConnection, args []string) (result, error) {
+	r := result{columns: []string{"ID", "NAME", "EMAIL", "BALANCE"}}
+
+	friends := []resources.Friend{}
+	executor := conn.GetFriends()
+	for f := range executor.GetChan() {
+		friends = append(friends, f)
+
+		balances := make([]string, 0, len(f.Balance))
+		for _, b := range f.Balance {
+			balances = append(balances, b.Amount+" "+b.CurrencyCode)
+		}
+
+		r.rows = append(r.rows, []string{
+			strconv.FormatUint(uint64(f.ID), 10),
+			fullName(f.FirstName, f.LastName),
+			f.Email,
+			strings.Join(balances, ", "),
+		})
+	}
+	if err := executor.Err(); err != nil {
+		return result{}, err
+	}
+	r.data = friends
+
+	return r, nil
+}
+
+func expensesFlags(fs *flag.FlagSet) runFunc {
+	groupID := fs.Int("group", 0, "only expenses of this group id")
+	friendID := fs.Int("friend", 0, "only expenses with this friend id")
+	datedAfter := fs.String("dated-after", "", "only expenses dated after this date (YYYY-MM-DD or RFC3339)")
+	datedBefore := fs.String("dated-before", "", "only expenses dated before this date")
+	updatedAfter := fs.String("updated-after", "", "only expenses updated after this date")
+	updatedBefore := fs.String("updated-before", "", "only expenses updated before this date")
+	pageSize := fs.Int("page-size", 50, "expenses fetched per request")
+	max := fs.Int("max", 0, "stop after this many expenses, 0 for all")
+
+	return func(conn smartsplitwise.SwConnection, args []string) (result, error) {
+		query := smartsplitwise.NewExpensesQuery().Limit(*pageSize)
+		if *groupID != 0 {
+			query.Group(*groupID)
+		}
+		if *friendID != 0 {
+			query.Friend(*friendID)
+		}
+
+		dateFilters := []struct {
+			name  string
+			value string
+			apply func(t time.Time) *smartsplitwise.ExpensesQuery
+		}{
+			{"dated-after", *datedAfter, query.DatedAfter},
+			{"dated-before", *datedBefore, query.DatedBefore},
+			{"updated-after", *updatedAfter, query.UpdatedAfter},
+			{"updated-before", *updatedBefore, query.UpdatedBefore},
+		}
+
+		for _, f := range dateFilters {
+			if f.value == "" {
+				continue
+			}
+			t, err := parseDate(f.value)
+			if err != nil {
+				return result{}, fmt.Errorf("-%s: %w", f.name, err)
+			}
+			f.apply(t)
+		}
+
+		params, err := query.Params()
+		if err != nil {
+			return result{}, err
+		}
+
+		r := result{columns: []string{"ID", "DATE", "DESCRIPTION", "COST", "CURRENCY", "CATEGORY", "GROUP", "DELETED"}}
+
+		expenses := []resources.Expense{}
+		executor := conn.GetExpenses(params)
+		for e := range executor.GetChan() {
+			expenses = append(expenses, e)
+			r.rows = append(r.rows, []string{
+				strconv.FormatUint(uint64(e.ID), 10),
+				e.Date,
+				e.Description,
+				e.Cost,
+				e.CurrencyCode,
+				e.Category.Name,
+				strconv.FormatUint(uint64(e.GroupId), 10),
+				strconv.FormatBool(e.DeletedAt != ""),
+			})
+
+			if *max > 0 && len(expenses) >= *max {
+				executor.Close()
+			}
+		}
+		if err := executor.Err(); err != nil {
+			return result{}, err
+		}
+		r.data = expenses
+
+		return r, nil
+	}
+}
+
+func notificationsFlags(fs *flag.FlagSet) runFunc {
+	updatedAfter := fs.String("updated-after", "", "only notifications after this date (YYYY-MM-DD or RFC3339)")
+	limit := fs.Int("limit", 0, "maximum number of notifications, 0 for the server default")
+
+	return func(conn smartsplitwise.SwConnection, args []string) (result, error) {
+		params := splitwise.NotificationsParams{}
+		if *updatedAfter != "" {
+			t, err := parseDate(*updatedAfter)
+			if err != nil {
+				return result{}, fmt.Errorf("-updated-after: %w", err)
+			}
+			params[splitwise.NotificationsUpdatedAfter] = t.Format(time.RFC3339)
+		}
+		if *limit > 0 {
+			params[splitwise.NotificationsLimit] = *limit
+		}
+
+		r := result{columns: []string{"ID", "CREATED", "EVENT", "ACTOR", "SUBJECT", "GROUP", "AMOUNT"}}
+
+		notifications := []resources.Notification{}
+		executor := conn.GetNotifications(params)
+		for n := range executor.GetChan() {
+			notifications = append(notifications, n)
+
+			ev := smartsplitwise.ParseNotification(n)
+			amount := ""
+			if ev.Amount != "" {
+				amount = strings.TrimSpace(ev.Amount + " " + ev.Currency)
+			}
+
+			r.rows = append(r.rows, []string{
+				strconv.FormatUint(uint64(n.ID), 10),
+				formatTime(n.CreatedAt),
+				ev.Type.String(),
+				ev.Actor,
+				ev.Subject,
+				ev.GroupName,
+				amount,
+			})
+		}
+		if err := executor.Err(); err != nil {
+			return result{}, err
+		}
+		r.data = notifications
+
+		return r, nil
+	}
+}
+
+func runCurrencies(conn smartsplitwise.SwConnection, args []string) (result, error) {
+	currencies, err := smartsplitwise.Collect(conn.GetCurecies())
+	if err != nil {
+		return result{}, err
+	}
+	sort.Slice(currencies, func(i, j int) bool {
+		return currencies[i].CurrencyCode < currencies[j].CurrencyCode
+	})
+
+	r := result{columns: []string{"CODE", "UNIT"}, data: currencies}
+	for _, c := range currencies {
+		r.rows = append(r.rows, []string{c.CurrencyCode, c.Unit})
+	}
+
+	return r, nil
+}
+
+func runCategories(conn smartsplitwise.SwConnection, args []string) (result, error) {
+	categories, err := smartsplitwise.Collect(conn.GetMainCategories())
+	if err != nil {
+		return result{}, err
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		return categories[i].ID < categories[j].ID
+	})
+
+	r := result{columns: []string{"ID", "NAME", "SUBCATEGORIES"}, data: categories}
+	for _, c := range categories {
+		subcategories := make([]string, 0, len(c.Subcategories))
+		for _, s := range c.Subcategories {
+			subcategories = append(subcategories, fmt.Sprintf("%s (%d)", s.Name, s.ID))
+		}
+		r.rows = append(r.rows, []string{
+			strconv.FormatUint(uint64(c.ID), 10),
+			c.Name,
+			strings.Join(subcategories, ", "),
+		})
+	}
+
+	return r, nil
+}
+
+func backupFlags(fs *flag.FlagSet) runFunc {
+	output := fs.String("o", "", "archive to write, gzip-compressed when it ends in .gz")
+
+	return func(conn smartsplitwise.SwConnection, args []string) (result, error) {
+		if *output == "" {
+			return result{}, fmt.Errorf("-o is required")
+		}
+
+		counts, err := smartsplitwise.BackupFile(conn, *output)
+		if err != nil {
+			return result{}, err
+		}
+
+		kinds := make([]string, 0, len(counts))
+		for kind := range counts {
+			kinds = append(kinds, string(kind))
+		}
+		sort.Strings(kinds)
+
+		r := result{columns: []string{"KIND", "COUNT"}, data: counts}
+		for _, kind := range kinds {
+			r.rows = append(r.rows, []string{kind, strconv.Itoa(counts[backup.Kind(kind)])})
+		}
+
+		return r, nil
+	}
+}
+
+func migrateFlags(fs *flag.FlagSet) runFunc {
+	archive := fs.String("archive", "", "read the source group from this backup archive instead of the account")
+	sourceGroup := fs.Int("source-group", 0, "group to migrate from, 0 for the expenses outside of groups")
+	group := fs.Int("group", 0, "group to migrate to")
+	users := fs.String("users", "", "JSON file mapping source user IDs to target ones; the rest are matched by email")
+	checkpoint := fs.String("checkpoint", "", "file keeping the progress, to resume an interrupted migration")
+
+	return func(conn smartsplitwise.SwConnection, args []string) (result, error) {
+		if *group <= 0 {
+			return result{}, fmt.Errorf("-group is required")
+		}
+
+		var source smartsplitwise.MigrationSource
+		if *archive != "" {
+			a, err := backup.LoadFile(*archive)
+			if err != nil {
+				return result{}, err
+			}
+			source = smartsplitwise.ArchiveSource(a, *sourceGroup)
+		} else {
+			var err error
+			if source, err = smartsplitwise.GroupSource(conn, *sourceGroup); err != nil {
+				return result{}, err
+			}
+		}
+
+		migration := smartsplitwise.NewMigration(conn, *group)
+		if *users != "" {
+			mapping, err := smartsplitwise.LoadUserMap(*users)
+			if err != nil {
+				return result{}, err
+			}
+			migration.Users = mapping
+		}
+		if *checkpoint != "" {
+			migration.Store = smartsplitwise.FileCheckpointStore{Path: *checkpoint}
+		}
+
+		report, err := migration.Run(source)
+		if err != nil {
+			return result{}, err
+		}
+
+		r := result{columns: []string{"SOURCE", "TARGET", "RESUMED"}, data: report}
+		for _, m := range report.Migrated {
+			r.rows = append(r.rows, []string{
+				strconv.FormatUint(uint64(m.SourceID), 10),
+				strconv.FormatUint(uint64(m.TargetID), 10),
+				strconv.FormatBool(m.Resumed),
+			})
+		}
+
+		return r, nil
+	}
+}
+
+func parseDate(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02", value, time.Local)
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+func fullName(first string, last string) string {
+	return strings.TrimSpace(first + " " + last)
+}
+
+func memberName(names map[int]string, id int) string {
+	if name, ok := names[id]; ok {
+		return name
+	}
+	return strconv.Itoa(id)
+}
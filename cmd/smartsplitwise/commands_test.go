@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"path/filepath"
+	"testing"
+
+	"github.com/dcerbino-golib/smartsplitwise/fake"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListCommandsFailWithTheAPI(t *testing.T) {
+	failure := errors.New("splitwise is down")
+
+	testCases := []struct {
+		Method string
+		Setup  func(fs *flag.FlagSet) runFunc
+	}{
+		{"GetGroups", noFlags(runGroups)},
+		{"GetFriends", noFlags(runFriends)},
+		{"GetExpenses", expensesFlags},
+		{"GetNotifications", notificationsFlags},
+		{"GetCurecies", noFlags(runCurrencies)},
+		{"GetMainCategories", noFlags(runCategories)},
+	}
+
+	for _, tc := range testCases {
+		run := tc.Setup(flag.NewFlagSet(tc.Method, flag.ContinueOnError))
+		_, err := run(fake.New(fake.WithError(tc.Method, failure)), nil)
+		assert.ErrorIs(t, err, failure, tc.Method)
+	}
+}
+
+func TestRunRejectsFormatBeforeCalling(t *testing.T) {
+	t.Setenv(tokenEnv, "not-a-token")
+	stdout, stderr := bytes.Buffer{}, bytes.Buffer{}
+
+	// any request would fail with exit code 1
+	code := run([]string{"-config", filepath.Join(t.TempDir(), "missing.yaml"), "-output", "xml", "groups"}, &stdout, &stderr)
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr.String(), `unknown output format "xml"`)
+	assert.Empty(t, stdout.String())
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+const tokenEnv = "SPLITWISE_TOKEN"
+
+type config struct {
+	Token  string `yaml:"token"`
+	Output string `yaml:"output"`
+	// APIKeys maps the keys accepted by the serve command to the name of the
+	// client using each of them.
+	APIKeys map[string]string `yaml:"api_keys"`
+}
+
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "smartsplitwise", "config.yaml")
+}
+
+// loadConfig reads the config file, if any, and lets SPLITWISE_TOKEN
+// override the token stored there.
+func loadConfig(path string) (config, error) {
+	cfg := config{}
+
+	if path != "" {
+		content, err := os.ReadFile(path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return cfg, err
+		}
+		if err == nil {
+			if err := yaml.Unmarshal(content, &cfg); err != nil {
+				return cfg, fmt.Errorf("config %s: %w", path, err)
+			}
+		}
+	}
+
+	if token := os.Getenv(tokenEnv); token != "" {
+		cfg.Token = token
+	}
+
+	if cfg.Token == "" {
+		return cfg, fmt.Errorf("no token found: set %s or add \"token\" to %s", tokenEnv, path)
+	}
+
+	return cfg, nil
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte("token: from-file\noutput: json\n"), 0o600))
+
+	t.Setenv(tokenEnv, "")
+	cfg, err := loadConfig(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "from-file", cfg.Token)
+	assert.Equal(t, "json", cfg.Output)
+
+	t.Setenv(tokenEnv, "from-env")
+	cfg, err = loadConfig(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "from-env", cfg.Token)
+
+	t.Setenv(tokenEnv, "")
+	_, err = loadConfig(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,130 @@
+// Command smartsplitwise queries a Splitwise account from the terminal.
+//
+//	smartsplitwise [-output table|json|csv|yaml] [-config file] <command> [flags] [args]
+//
+// The token is read from SPLITWISE_TOKEN or from the "token" key of the
+// yaml config file. The serve command accepts the keys listed under "api_keys".
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/dcerbino-golib/smartsplitwise"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout io.Writer, stderr io.Writer) int {
+	global := flag.NewFlagSet("smartsplitwise", flag.ContinueOnError)
+	global.SetOutput(stderr)
+	output := global.String("output", "", "output format: table, json, csv or yaml")
+	configPath := global.String("config", defaultConfigPath(), "config file")
+	verbose := global.Bool("verbose", false, "log the requests sent to Splitwise")
+	global.Usage = func() { usage(global) }
+
+	if err := global.Parse(args); err != nil {
+		return 2
+	}
+
+	if global.NArg() == 0 {
+		usage(global)
+		return 2
+	}
+
+	name := global.Arg(0)
+	var cmd *command
+	for i := range commands {
+		if commands[i].name == name {
+			cmd = &commands[i]
+		}
+	}
+	if cmd == nil {
+		fmt.Fprintf(stderr, "unknown command %q\n", name)
+		usage(global)
+		return 2
+	}
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(output, "output", *output, "output format: table, json, csv or yaml")
+	var (
+		runCmd   runFunc
+		startCmd startFunc
+	)
+	if cmd.setup != nil {
+		runCmd = cmd.setup(fs)
+	} else {
+		startCmd = cmd.start(fs)
+	}
+
+	if err := fs.Parse(global.Args()[1:]); err != nil {
+		return 2
+	}
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	format := *output
+	if format == "" {
+		format = cfg.Output
+	}
+	if runCmd != nil {
+		if err := checkFormat(format); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+	}
+
+	var logger *slog.Logger
+	if *verbose {
+		logger = slog.New(slog.NewTextHandler(stderr, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	}
+
+	conn := smartsplitwise.OpenWithOptions(
+		smartsplitwise.WithToken(cfg.Token),
+		smartsplitwise.WithLogger(logger),
+		smartsplitwise.WithUserAgent("smartsplitwise-cli"),
+		smartsplitwise.WithRetry(smartsplitwise.DefaultRetryPolicy),
+	)
+
+	if startCmd != nil {
+		if err := startCmd(conn, cfg); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		return 0
+	}
+
+	r, err := runCmd(conn, fs.Args())
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	if err := render(stdout, format, r); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	return 0
+}
+
+func usage(fs *flag.FlagSet) {
+	w := fs.Output()
+	fmt.Fprintln(w, "usage: smartsplitwise [flags] <command> [command flags] [args]")
+	fmt.Fprintln(w, "\ncommands:")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-14s %s\n", c.name, c.usage)
+	}
+	fmt.Fprintln(w, "\nflags:")
+	fs.PrintDefaults()
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputCSV   = "csv"
+	outputYAML  = "yaml"
+)
+
+// result holds both views of a command output: the flattened columns used
+// by table and csv, and the raw resources used by json and yaml.
+type result struct {
+	columns []string
+	rows    [][]string
+	data    interface{}
+}
+
+// checkFormat rejects an unknown format before the command runs.
+func checkFormat(format string) error {
+	switch format {
+	case outputTable, outputCSV, outputJSON, outputYAML, "":
+		return nil
+	default:
+		return fmt.Errorf("unknown output format %q, expected table, json, csv or yaml", format)
+	}
+}
+
+func render(w io.Writer, format string, r result) error {
+	switch format {
+	case outputTable, "":
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.Join(r.columns, "\t"))
+		for _, row := range r.rows {
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+		return tw.Flush()
+	case outputCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(r.columns); err != nil {
+			return err
+		}
+		if err := cw.WriteAll(r.rows); err != nil {
+			return err
+		}
+		return cw.Error()
+	case outputJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r.data)
+	case outputYAML:
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(toYAMLValue(r.data)); err != nil {
+			return err
+		}
+		return enc.Close()
+	default:
+		return checkFormat(format)
+	}
+}
+
+// toYAMLValue round trips through JSON so the yaml keys follow the json
+// tags of the splitwise resources. Numbers are kept as integers when
+// possible, otherwise yaml would print large IDs in exponent notation.
+func toYAMLValue(v interface{}) interface{} {
+	content, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(content))
+	dec.UseNumber()
+
+	var generic interface{}
+	if err := dec.Decode(&generic); err != nil {
+		return v
+	}
+	return convertNumbers(generic)
+}
+
+func convertNumbers(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for k, e := range value {
+			value[k] = convertNumbers(e)
+		}
+	case []interface{}:
+		for i, e := range value {
+			value[i] = convertNumbers(e)
+		}
+	case json.Number:
+		if i, err := value.Int64(); err == nil {
+			return i
+		}
+		if f, err := value.Float64(); err == nil {
+			return f
+		}
+	}
+	return v
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/aanzolaavila/splitwise.go/resources"
+	"github.com/stretchr/testify/assert"
+)
+
+func testResult() result {
+	currencies := []resources.Currency{
+		{CurrencyCode: "ARS", Unit: "$"},
+		{CurrencyCode: "EUR", Unit: "€"},
+	}
+
+	return result{
+		columns: []string{"CODE", "UNIT"},
+		rows:    [][]string{{"ARS", "$"}, {"EUR", "€"}},
+		data:    currencies,
+	}
+}
+
+func TestRenderFormats(t *testing.T) {
+	testCases := []struct {
+		Format string
+		Want   string
+	}{
+		{outputTable, "CODE  UNIT\nARS   $\nEUR   €\n"},
+		{outputCSV, "CODE,UNIT\nARS,$\nEUR,€\n"},
+		{outputJSON, "[\n  {\n    \"currency_code\": \"ARS\",\n    \"unit\": \"$\"\n  },\n  {\n    \"currency_code\": \"EUR\",\n    \"unit\": \"€\"\n  }\n]\n"},
+		{outputYAML, "- currency_code: ARS\n  unit: $\n- currency_code: EUR\n  unit: €\n"},
+	}
+
+	for _, tc := range testCases {
+		buf := bytes.Buffer{}
+		err := render(&buf, tc.Format, testResult())
+
+		assert.NoError(t, err, tc.Format)
+		assert.Equal(t, tc.Want, buf.String(), tc.Format)
+	}
+}
+
+func TestRenderYAMLKeepsIntegers(t *testing.T) {
+	buf := bytes.Buffer{}
+	err := render(&buf, outputYAML, result{data: resources.Group{ID: 11741221, Name: "Familia"}})
+
+	assert.NoError(t, err)
+	assert.Contains(t, buf.String(), "id: 11741221\n")
+}
+
+func TestRenderUnknownFormat(t *testing.T) {
+	err := render(&bytes.Buffer{}, "xml", testResult())
+	assert.Error(t, err)
+}
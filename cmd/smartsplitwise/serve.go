@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/dcerbino-golib/smartsplitwise"
+	"github.com/dcerbino-golib/smartsplitwise/gateway"
+)
+
+func serveFlags(fs *flag.FlagSet) startFunc {
+	addr := fs.String("addr", "127.0.0.1:8080", "address to listen on")
+	ttl := fs.Duration("ttl", gateway.DefaultTTL, "how long responses are cached, 0 disables the cache")
+
+	return func(conn smartsplitwise.SwConnection, cfg config) error {
+		if len(cfg.APIKeys) == 0 {
+			return errors.New("no API keys configured: add \"api_keys\" to the config file")
+		}
+
+		gw := gateway.New(conn, cfg.APIKeys)
+		gw.TTL = *ttl
+		gw.Logger = slog.New(slog.NewTextHandler(os.Stderr, nil)).With(slog.String("component", "gateway"))
+
+		server := &http.Server{
+			Addr:              *addr,
+			Handler:           gw,
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		go func() {
+			<-ctx.Done()
+			shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			server.Shutdown(shutdown)
+		}()
+
+		gw.Logger.Info("listening", slog.String("addr", *addr))
+		if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	}
+}
@@ -0,0 +1,16 @@
+package main
+
+import (
+	"github.com/dcerbino-golib/smartsplitwise"
+	"github.com/dcerbino-golib/smartsplitwise/tui"
+	"github.com/gdamore/tcell/v2"
+)
+
+func runTUI(conn smartsplitwise.SwConnection, cfg config) error {
+	screen, err := tcell.NewScreen()
+	if err != nil {
+		return err
+	}
+
+	return tui.New(conn, screen).Run()
+}
@@ -0,0 +1,216 @@
+package smartsplitwise
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/aanzolaavila/splitwise.go/resources"
+)
+
+type ExpenseChangeKind string
+
+const (
+	ChangeNone     ExpenseChangeKind = ""
+	ChangeCreated  ExpenseChangeKind = "created"
+	ChangeModified ExpenseChangeKind = "modified"
+	ChangeDeleted  ExpenseChangeKind = "deleted"
+	ChangeRestored ExpenseChangeKind = "restored"
+	// ChangeMissing marks an expense present in the old snapshot only,
+	// usually because it was deleted and the new fetch excluded it.
+	ChangeMissing ExpenseChangeKind = "missing"
+)
+
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+type ShareChange struct {
+	UserID       uint64 `json:"user_id"`
+	OldPaidShare string `json:"old_paid_share"`
+	NewPaidShare string `json:"new_paid_share"`
+	OldOwedShare string `json:"old_owed_share"`
+	NewOwedShare string `json:"new_owed_share"`
+}
+
+type ExpenseDiff struct {
+	ID          resources.ExpenseID `json:"id"`
+	Description string              `json:"description"`
+	Kind        ExpenseChangeKind   `json:"kind"`
+	Fields      []FieldChange       `json:"fields,omitempty"`
+	Shares      []ShareChange       `json:"shares,omitempty"`
+}
+
+func (d ExpenseDiff) HasChanges() bool {
+	return d.Kind != ChangeNone
+}
+
+// DiffExpense compares two versions of the same expense. Amounts and dates
+// are compared by value, so "1185.0" and "1185.00" are not a change.
+func DiffExpense(old resources.Expense, new resources.Expense) ExpenseDiff {
+	diff := ExpenseDiff{
+		ID:          new.ID,
+		Description: new.Description,
+	}
+
+	addField := func(field string, oldValue string, newValue string, equal func(string, string) bool) {
+		if !equal(oldValue, newValue) {
+			diff.Fields = append(diff.Fields, FieldChange{Field: field, Old: oldValue, New: newValue})
+		}
+	}
+
+	addField("cost", old.Cost, new.Cost, equalAmounts)
+	addField("currency_code", old.CurrencyCode, new.CurrencyCode, equalStrings)
+	addField("date", old.Date, new.Date, equalDates)
+	addField("description", old.Description, new.Description, equalStrings)
+	addField("details", old.Details, new.Details, equalStrings)
+	addField("category", categoryLabel(old), categoryLabel(new), equalStrings)
+	addField("group_id", fmt.Sprint(old.GroupId), fmt.Sprint(new.GroupId), equalStrings)
+	addField("payment", fmt.Sprint(old.Payment), fmt.Sprint(new.Payment), equalStrings)
+
+	diff.Shares = diffShares(old, new)
+
+	switch {
+	case old.DeletedAt == "" && new.DeletedAt != "":
+		diff.Kind = ChangeDeleted
+	case old.DeletedAt != "" && new.DeletedAt == "":
+		diff.Kind = ChangeRestored
+	case len(diff.Fields) > 0 || len(diff.Shares) > 0:
+		diff.Kind = ChangeModified
+	}
+
+	return diff
+}
+
+// DiffSnapshots compares two full expense listings, as returned by
+// GetExpenses, and returns the changed expenses ordered by ID.
+func DiffSnapshots(old []resources.Expense, new []resources.Expense) []ExpenseDiff {
+	oldByID := make(map[resources.ExpenseID]resources.Expense, len(old))
+	for _, e := range old {
+		oldByID[e.ID] = e
+	}
+
+	var diffs []ExpenseDiff
+	seen := make(map[resources.ExpenseID]bool, len(new))
+
+	for _, e := range new {
+		seen[e.ID] = true
+
+		previous, ok := oldByID[e.ID]
+		if !ok {
+			diffs = append(diffs, ExpenseDiff{ID: e.ID, Description: e.Description, Kind: ChangeCreated})
+			continue
+		}
+
+		if diff := DiffExpense(previous, e); diff.HasChanges() {
+			diffs = append(diffs, diff)
+		}
+	}
+
+	for _, e := range old {
+		if !seen[e.ID] {
+			diffs = append(diffs, ExpenseDiff{ID: e.ID, Description: e.Description, Kind: ChangeMissing})
+		}
+	}
+
+	sort.Slice(diffs, func(i, j int) bool {
+		return diffs[i].ID < diffs[j].ID
+	})
+
+	return diffs
+}
+
+type expenseShare struct {
+	paidShare string
+	owedShare string
+}
+
+func expenseShares(e resources.Expense) map[uint64]expenseShare {
+	shares := make(map[uint64]expenseShare, len(e.Users))
+	for _, u := range e.Users {
+		shares[u.UserId] = expenseShare{
+			paidShare: u.PaidShare,
+			owedShare: u.OwedShare,
+		}
+	}
+	return shares
+}
+
+func diffShares(old resources.Expense, new resources.Expense) []ShareChange {
+	oldShares := expenseShares(old)
+	newShares := expenseShares(new)
+
+	ids := make([]uint64, 0, len(oldShares)+len(newShares))
+	for id := range oldShares {
+		ids = append(ids, id)
+	}
+	for id := range newShares {
+		if _, ok := oldShares[id]; !ok {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	var changes []ShareChange
+	for _, id := range ids {
+		o, n := oldShares[id], newShares[id]
+		if equalAmounts(o.paidShare, n.paidShare) && equalAmounts(o.owedShare, n.owedShare) {
+			continue
+		}
+
+		changes = append(changes, ShareChange{
+			UserID:       id,
+			OldPaidShare: o.paidShare,
+			NewPaidShare: n.paidShare,
+			OldOwedShare: o.owedShare,
+			NewOwedShare: n.owedShare,
+		})
+	}
+
+	return changes
+}
+
+func categoryLabel(e resources.Expense) string {
+	if e.Category.ID == 0 && e.Category.Name == "" {
+		return ""
+	}
+	return fmt.Sprintf("%d %s", e.Category.ID, e.Category.Name)
+}
+
+func equalStrings(a string, b string) bool {
+	return a == b
+}
+
+// equalAmounts treats a missing share as zero, so a user added with a zero
+// share is not reported.
+func equalAmounts(a string, b string) bool {
+	if a == b {
+		return true
+	}
+
+	ma, errA := ParseMoney(defaultAmount(a), "")
+	mb, errB := ParseMoney(defaultAmount(b), "")
+	return errA == nil && errB == nil && ma.Equal(mb)
+}
+
+func defaultAmount(a string) string {
+	if a == "" {
+		return "0"
+	}
+	return a
+}
+
+func equalDates(a string, b string) bool {
+	if a == b {
+		return true
+	}
+
+	ta, errA := time.Parse(time.RFC3339, a)
+	tb, errB := time.Parse(time.RFC3339, b)
+	if errA != nil || errB != nil {
+		return false
+	}
+	return ta.Equal(tb)
+}
@@ -0,0 +1,110 @@
+package smartsplitwise
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/aanzolaavila/splitwise.go/resources"
+	"github.com/stretchr/testify/assert"
+)
+
+func getTestExpenses(t *testing.T) []resources.Expense {
+	conn, _ := replayConnection(t, "expenses")
+
+	expenses, err := Collect(conn.GetExpenses(cassetteExpensesParams(0)))
+	assert.NoError(t, err)
+	return expenses
+}
+
+// cloneExpense deep copies an expense so a test can edit the shares.
+func cloneExpense(t *testing.T, e resources.Expense) resources.Expense {
+	content, err := json.Marshal(e)
+	assert.NoError(t, err)
+
+	clone := resources.Expense{}
+	assert.NoError(t, json.Unmarshal(content, &clone))
+	return clone
+}
+
+func TestDiffExpenseUnchanged(t *testing.T) {
+	expenses := getTestExpenses(t)
+
+	edited := cloneExpense(t, expenses[1])
+	edited.Cost = "1185.00"
+	edited.Date = "2023-01-06T18:44:18-03:00"
+
+	diff := DiffExpense(expenses[1], edited)
+	assert.False(t, diff.HasChanges())
+	assert.Empty(t, diff.Fields)
+}
+
+func TestDiffExpenseFields(t *testing.T) {
+	expenses := getTestExpenses(t)
+	old := expenses[1]
+
+	edited := cloneExpense(t, old)
+	edited.Cost = "1285.0"
+	edited.Description = "Fiambre y queso"
+	edited.Date = "2022-12-06T21:44:18Z"
+	edited.Category.ID = 18
+	edited.Category.Name = "Generale"
+	edited.Users[0].PaidShare = "1285.0"
+	edited.Users[0].OwedShare = "692.5"
+
+	diff := DiffExpense(old, edited)
+	assert.Equal(t, ChangeModified, diff.Kind)
+	assert.Equal(t, []FieldChange{
+		{Field: "cost", Old: "1185.0", New: "1285.0"},
+		{Field: "date", Old: "2023-01-06T21:44:18Z", New: "2022-12-06T21:44:18Z"},
+		{Field: "description", Old: "Fiambre", New: "Fiambre y queso"},
+		{Field: "category", Old: "12 Alimentari", New: "18 Generale"},
+	}, diff.Fields)
+	assert.Equal(t, []ShareChange{
+		{
+			UserID:       21623741,
+			OldPaidShare: "1185.0",
+			NewPaidShare: "1285.0",
+			OldOwedShare: "592.5",
+			NewOwedShare: "692.5",
+		},
+	}, diff.Shares)
+}
+
+func TestDiffExpenseDeletedAndRestored(t *testing.T) {
+	expenses := getTestExpenses(t)
+	old := expenses[0]
+
+	deleted := cloneExpense(t, old)
+	deleted.DeletedAt = "2023-04-01T10:00:00Z"
+
+	assert.Equal(t, ChangeDeleted, DiffExpense(old, deleted).Kind)
+	assert.Equal(t, ChangeRestored, DiffExpense(deleted, old).Kind)
+}
+
+func TestDiffSnapshots(t *testing.T) {
+	expenses := getTestExpenses(t)
+
+	old := expenses[:len(expenses)-1]
+
+	var new []resources.Expense
+	for _, e := range expenses[1:] {
+		new = append(new, cloneExpense(t, e))
+	}
+	new[0].Description = "Edited"
+
+	diffs := DiffSnapshots(old, new)
+
+	kinds := make(map[resources.ExpenseID]ExpenseChangeKind)
+	for _, d := range diffs {
+		kinds[d.ID] = d.Kind
+	}
+
+	assert.Len(t, diffs, 3)
+	assert.Equal(t, ChangeMissing, kinds[expenses[0].ID])
+	assert.Equal(t, ChangeModified, kinds[expenses[1].ID])
+	assert.Equal(t, ChangeCreated, kinds[expenses[len(expenses)-1].ID])
+
+	for i := 1; i < len(diffs); i++ {
+		assert.Less(t, diffs[i-1].ID, diffs[i].ID)
+	}
+}
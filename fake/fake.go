@@ -0,0 +1,672 @@
+// Package fake provides an in-memory smartsplitwise.SwConnection, so that
+// code depending on the library can be unit tested without a server.
+//
+//	conn := fake.New(
+//		fake.WithGroups(home),
+//		fake.WithExpenses(groceries, rent),
+//	)
+package fake
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aanzolaavila/splitwise.go"
+	"github.com/aanzolaavila/splitwise.go/resources"
+	"github.com/dcerbino-golib/smartsplitwise"
+	"github.com/dcerbino-golib/smartsplitwise/backup"
+)
+
+var _ smartsplitwise.SwConnection = (*Connection)(nil)
+
+// Call is a write made through the connection.
+type Call struct {
+	Method    string
+	ExpenseID int
+}
+
+// Connection is a fake SwConnection. Reads are served from the entities it
+// was built with and writes change them, like the API would. The zero value
+// is not usable; build one with New.
+type Connection struct {
+	mu            sync.Mutex
+	currentUser   resources.User
+	expenses      map[int]resources.Expense
+	groups        map[int]resources.Group
+	friends       map[int]resources.Friend
+	notifications []resources.Notification
+	categories    []resources.MainCategory
+	currencies    []resources.Currency
+	comments      map[int][]resources.Comment
+	errors        map[string]error
+	auditor       smartsplitwise.Auditor
+	calls         []Call
+	lastID        int
+}
+
+type Option func(c *Connection)
+
+func WithCurrentUser(user resources.User) Option {
+	return func(c *Connection) {
+		c.currentUser = user
+	}
+}
+
+func WithExpenses(expenses ...resources.Expense) Option {
+	return func(c *Connection) {
+		for _, e := range expenses {
+			c.expenses[int(e.ID)] = e
+			c.lastID = max(c.lastID, int(e.ID))
+		}
+	}
+}
+
+func WithGroups(groups ...resources.Group) Option {
+	return func(c *Connection) {
+		for _, g := range groups {
+			c.groups[int(g.ID)] = g
+		}
+	}
+}
+
+func WithFriends(friends ...resources.Friend) Option {
+	return func(c *Connection) {
+		for _, f := range friends {
+			c.friends[int(f.ID)] = f
+		}
+	}
+}
+
+func WithNotifications(notifications ...resources.Notification) Option {
+	return func(c *Connection) {
+		c.notifications = append(c.notifications, notifications...)
+	}
+}
+
+func WithCategories(categories ...resources.MainCategory) Option {
+	return func(c *Connection) {
+		c.categories = append(c.categories, categories...)
+	}
+}
+
+func WithCurrencies(currencies ...resources.Currency) Option {
+	return func(c *Connection) {
+		c.currencies = append(c.currencies, currencies...)
+	}
+}
+
+func WithComments(expenseID int, comments ...resources.Comment) Option {
+	return func(c *Connection) {
+		c.comments[expenseID] = append(c.comments[expenseID], comments...)
+	}
+}
+
+// WithArchive loads the state of a backup archive, as read by backup.Load.
+func WithArchive(a *backup.Archive) Option {
+	return func(c *Connection) {
+		opts := []Option{
+			WithCurrentUser(a.CurrentUser),
+			WithGroups(a.Groups...),
+			WithFriends(a.Friends...),
+			WithExpenses(a.Expenses...),
+			WithNotifications(a.Notifications...),
+			WithCategories(a.Categories...),
+			WithCurrencies(a.Currencies...),
+		}
+		for expenseID, comments := range a.Comments {
+			opts = append(opts, WithComments(expenseID, comments...))
+		}
+		for _, opt := range opts {
+			opt(c)
+		}
+	}
+}
+
+// WithError makes the method of the given name, such as "GetGroup" or
+// "DeleteExpense", fail with err.
+func WithError(method string, err error) Option {
+	return func(c *Connection) {
+		c.errors[method] = err
+	}
+}
+
+func New(opts ...Option) *Connection {
+	c := &Connection{
+		currentUser: resources.User{ID: 1, FirstName: "Test", LastName: "User"},
+		expenses:    map[int]resources.Expense{},
+		groups:      map[int]resources.Group{},
+		friends:     map[int]resources.Friend{},
+		comments:    map[int][]resources.Comment{},
+		errors:      map[string]error{},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Calls returns the writes made so far, in order.
+func (c *Connection) Calls() []Call {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]Call(nil), c.calls...)
+}
+
+// Expenses returns the current state of every expense, deleted ones
+// included, by ID.
+func (c *Connection) Expenses() []resources.Expense {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return sortedValues(c.expenses)
+}
+
+func (c *Connection) fail(method string) error {
+	return c.errors[method]
+}
+
+func sortedValues[T any](m map[int]T) []T {
+	ids := make([]int, 0, len(m))
+	for id := range m {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	values := make([]T, 0, len(ids))
+	for _, id := range ids {
+		values = append(values, m[id])
+	}
+	return values
+}
+
+func get[T any](c *Connection, method string, m map[int]T, id int) (T, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var zero T
+	if err := c.fail(method); err != nil {
+		return zero, err
+	}
+
+	value, ok := m[id]
+	if !ok {
+		return zero, splitwise.ErrNotFound
+	}
+	return value, nil
+}
+
+func batch[T any](ids []int, get func(id int) (T, error)) []smartsplitwise.BatchResult[T] {
+	results := make([]smartsplitwise.BatchResult[T], len(ids))
+	for i, id := range ids {
+		value, err := get(id)
+		results[i] = smartsplitwise.BatchResult[T]{ID: id, Value: value, Err: err}
+	}
+	return results
+}
+
+func (c *Connection) GetMainCategories() smartsplitwise.CommandExecutor[resources.MainCategory] {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.fail("GetMainCategories"); err != nil {
+		return failedExecutor[resources.MainCategory](err)
+	}
+	return newExecutor(c.categories)
+}
+
+func (c *Connection) GetMainCategory(id resources.Identifier) (*resources.MainCategory, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, category := range c.categories {
+		if resources.Identifier(category.ID) == id {
+			return &category, nil
+		}
+	}
+	return nil, &smartsplitwise.ElementNotFound{}
+}
+
+func (c *Connection) GetCurecies() smartsplitwise.CommandExecutor[resources.Currency] {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.fail("GetCurecies"); err != nil {
+		return failedExecutor[resources.Currency](err)
+	}
+	return newExecutor(c.currencies)
+}
+
+func (c *Connection) GetCurency(code string) (*resources.Currency, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, currency := range c.currencies {
+		if currency.CurrencyCode == code {
+			return &currency, nil
+		}
+	}
+	return nil, &smartsplitwise.ElementNotFound{}
+}
+
+func (c *Connection) GetFriends() smartsplitwise.CommandExecutor[resources.Friend] {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.fail("GetFriends"); err != nil {
+		return failedExecutor[resources.Friend](err)
+	}
+	return newExecutor(sortedValues(c.friends))
+}
+
+func (c *Connection) GetFriend(id int) (resources.Friend, error) {
+	return get(c, "GetFriend", c.friends, id)
+}
+
+func (c *Connection) GetFriendsByID(ids []int) []smartsplitwise.BatchResult[resources.Friend] {
+	return batch(ids, c.GetFriend)
+}
+
+func (c *Connection) GetGroups() smartsplitwise.CommandExecutor[resources.Group] {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.fail("GetGroups"); err != nil {
+		return failedExecutor[resources.Group](err)
+	}
+	return newExecutor(sortedValues(c.groups))
+}
+
+func (c *Connection) GetGroup(id int) (resources.Group, error) {
+	return get(c, "GetGroup", c.groups, id)
+}
+
+func (c *Connection) GetGroupsByID(ids []int) []smartsplitwise.BatchResult[resources.Group] {
+	return batch(ids, c.GetGroup)
+}
+
+// GetNotifications filters by updated_after and limit, newest first.
+func (c *Connection) GetNotifications(params splitwise.NotificationsParams) smartsplitwise.CommandExecutor[resources.Notification] {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.fail("GetNotifications"); err != nil {
+		return failedExecutor[resources.Notification](err)
+	}
+
+	after := paramTime(params[splitwise.NotificationsUpdatedAfter])
+
+	list := []resources.Notification{}
+	for _, n := range c.notifications {
+		if !after.IsZero() && n.CreatedAt.Before(after) {
+			continue
+		}
+		list = append(list, n)
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].CreatedAt.After(list[j].CreatedAt) })
+
+	if limit, ok := params[splitwise.NotificationsLimit].(int); ok && limit > 0 && limit < len(list) {
+		list = list[:limit]
+	}
+	return newExecutor(list)
+}
+
+func (c *Connection) GetExpense(id int) (resources.Expense, error) {
+	return get(c, "GetExpense", c.expenses, id)
+}
+
+func (c *Connection) GetExpensesByID(ids []int) []smartsplitwise.BatchResult[resources.Expense] {
+	return batch(ids, c.GetExpense)
+}
+
+// GetExpenses filters by group, friend and the date ranges, ignoring limit
+// and offset since the executor returns every page anyway.
+func (c *Connection) GetExpenses(params splitwise.ExpensesParams) smartsplitwise.CommandExecutor[resources.Expense] {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.fail("GetExpenses"); err != nil {
+		return failedExecutor[resources.Expense](err)
+	}
+
+	groupID, hasGroup := paramInt(params[splitwise.ExpensesGroupId])
+	friendID, hasFriend := paramInt(params[splitwise.ExpensesFriendId])
+	datedAfter := paramTime(params[splitwise.ExpensesDatedAfter])
+	datedBefore := paramTime(params[splitwise.ExpensesDatedBefore])
+	updatedAfter := paramTime(params[splitwise.ExpensesUpdatedAfter])
+	updatedBefore := paramTime(params[splitwise.ExpensesUpdatedBefore])
+
+	list := []resources.Expense{}
+	for _, e := range c.expenses {
+		if hasGroup && int(e.GroupId) != groupID {
+			continue
+		}
+		if hasFriend && !involves(e, friendID) {
+			continue
+		}
+		if !inRange(e.Date, datedAfter, datedBefore) || !inRange(e.UpdatedAt, updatedAfter, updatedBefore) {
+			continue
+		}
+		list = append(list, e)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Date != list[j].Date {
+			return list[i].Date > list[j].Date
+		}
+		return list[i].ID > list[j].ID
+	})
+
+	return newExecutor(list)
+}
+
+func (c *Connection) GetExpenseComments(expenseId int) ([]resources.Comment, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.fail("GetExpenseComments"); err != nil {
+		return nil, err
+	}
+	return append([]resources.Comment{}, c.comments[expenseId]...), nil
+}
+
+func (c *Connection) GetCurrentUser() (resources.User, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.fail("GetCurrentUser"); err != nil {
+		return resources.User{}, err
+	}
+	return c.currentUser, nil
+}
+
+func (c *Connection) SetAuditor(auditor smartsplitwise.Auditor) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.auditor = auditor
+}
+
+// CreateExpenseEqualGroupSplit splits cost equally between the members of
+// the group, paid by the current user.
+func (c *Connection) CreateExpenseEqualGroupSplit(cost float64, description string, groupId int, params splitwise.CreateExpenseParams) ([]resources.Expense, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	users := []splitwise.ExpenseUser{{Id: c.currentUser.ID}}
+	if g, ok := c.groups[groupId]; ok && len(g.Members) > 0 {
+		users = nil
+		for _, m := range g.Members {
+			users = append(users, splitwise.ExpenseUser{Id: m.ID})
+		}
+	}
+
+	each := cost / float64(len(users))
+	for i := range users {
+		users[i].OwedShare = each
+		if users[i].Id == c.currentUser.ID {
+			users[i].PaidShare = cost
+		}
+	}
+
+	return c.create("CreateExpenseEqualGroupSplit", cost, description, groupId, params, users)
+}
+
+func (c *Connection) CreateExpenseByShares(cost float64, description string, groupId int, params splitwise.CreateExpenseParams, users []splitwise.ExpenseUser) ([]resources.Expense, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.create("CreateExpenseByShares", cost, description, groupId, params, users)
+}
+
+func (c *Connection) create(method string, cost float64, description string, groupId int, params splitwise.CreateExpenseParams, users []splitwise.ExpenseUser) ([]resources.Expense, error) {
+	c.calls = append(c.calls, Call{Method: method})
+	if err := c.fail(method); err != nil {
+		c.audit(method, 0, nil, nil, err)
+		return nil, err
+	}
+
+	c.lastID++
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	e := resources.Expense{ID: resources.ExpenseID(c.lastID)}
+	e.Date = now
+	e.CreatedAt = now
+	e.CreatedBy = c.currentUser
+	fill(&e, cost, description, groupId, params, users)
+
+	c.expenses[int(e.ID)] = e
+	c.calls[len(c.calls)-1].ExpenseID = int(e.ID)
+	c.audit(method, int(e.ID), nil, &e, nil)
+
+	return []resources.Expense{e}, nil
+}
+
+func (c *Connection) UpdateExpense(id int, cost float64, description string, groupId int, params splitwise.CreateExpenseParams, users []splitwise.ExpenseUser) ([]resources.Expense, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.calls = append(c.calls, Call{Method: "UpdateExpense", ExpenseID: id})
+
+	before, ok := c.expenses[id]
+	err := c.fail("UpdateExpense")
+	if err == nil && !ok {
+		err = splitwise.ErrNotFound
+	}
+	if err != nil {
+		c.audit("UpdateExpense", id, nil, nil, err)
+		return nil, err
+	}
+
+	e := before
+	if users == nil {
+		for _, u := range before.Users {
+			paid, _ := parseAmount(u.PaidShare)
+			owed, _ := parseAmount(u.OwedShare)
+			users = append(users, splitwise.ExpenseUser{Id: resources.UserID(u.UserId), PaidShare: paid, OwedShare: owed})
+		}
+	}
+	fill(&e, cost, description, groupId, params, users)
+
+	c.expenses[id] = e
+	c.audit("UpdateExpense", id, &before, &e, nil)
+
+	return []resources.Expense{e}, nil
+}
+
+func (c *Connection) DeleteExpense(id int) error {
+	return c.setDeleted("DeleteExpense", id, true)
+}
+
+func (c *Connection) RestoreExpense(id int) error {
+	return c.setDeleted("RestoreExpense", id, false)
+}
+
+func (c *Connection) setDeleted(method string, id int, deleted bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.calls = append(c.calls, Call{Method: method, ExpenseID: id})
+
+	before, ok := c.expenses[id]
+	err := c.fail(method)
+	if err == nil && !ok {
+		err = splitwise.ErrNotFound
+	}
+	if err != nil {
+		c.audit(method, id, nil, nil, err)
+		return err
+	}
+
+	e := before
+	e.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+	e.DeletedAt = ""
+	if deleted {
+		e.DeletedAt = e.UpdatedAt
+	}
+
+	c.expenses[id] = e
+	c.audit(method, id, &before, &e, nil)
+
+	return nil
+}
+
+// operations names the writes in audit entries like the real connection.
+var operations = map[string]string{
+	"CreateExpenseEqualGroupSplit": "create_expense_equal_group_split",
+	"CreateExpenseByShares":        "create_expense_by_shares",
+	"UpdateExpense":                "update_expense",
+	"DeleteExpense":                "delete_expense",
+	"RestoreExpense":               "restore_expense",
+}
+
+func (c *Connection) audit(method string, id int, before, after *resources.Expense, err error) {
+	if c.auditor == nil {
+		return
+	}
+
+	entry := smartsplitwise.AuditEntry{
+		Timestamp: time.Now().UTC(),
+		Operation: operations[method],
+		ExpenseID: id,
+		Before:    before,
+		After:     after,
+	}
+	if after != nil {
+		entry.Response, _ = json.Marshal([]resources.Expense{*after})
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	c.auditor.Record(entry)
+}
+
+func fill(e *resources.Expense, cost float64, description string, groupId int, params splitwise.CreateExpenseParams, users []splitwise.ExpenseUser) {
+	e.Cost = fmt.Sprintf("%.2f", cost)
+	e.Description = description
+	e.GroupId = uint32(groupId)
+	e.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+
+	if v, ok := params[splitwise.CreateExpenseCurrencyCode].(string); ok {
+		e.CurrencyCode = v
+	}
+	if v, ok := params[splitwise.CreateExpenseDetails].(string); ok {
+		e.Details = v
+	}
+	if v, ok := params[splitwise.CreateExpenseCategoryId].(int); ok {
+		e.CategoryId = uint32(v)
+	}
+	if v := paramTime(params[splitwise.CreateExpenseDate]); !v.IsZero() {
+		e.Date = v.UTC().Format(time.RFC3339)
+	}
+	if v, ok := params["payment"].(bool); ok {
+		e.Payment = v
+	}
+
+	e.Users = nil
+	for _, u := range users {
+		e.Users = append(e.Users, struct {
+			resources.User
+			UserId     uint64 `json:"user_id"`
+			PaidShare  string `json:"paid_share"`
+			OwedShare  string `json:"owed_share"`
+			NetBalance string `json:"net_balance"`
+		}{
+			User:       resources.User{ID: u.Id, FirstName: u.Firstname, LastName: u.Lastname, Email: u.Email},
+			UserId:     uint64(u.Id),
+			PaidShare:  fmt.Sprintf("%.2f", u.PaidShare),
+			OwedShare:  fmt.Sprintf("%.2f", u.OwedShare),
+			NetBalance: fmt.Sprintf("%.2f", u.PaidShare-u.OwedShare),
+		})
+	}
+}
+
+func involves(e resources.Expense, userID int) bool {
+	for _, u := range e.Users {
+		if int(u.UserId) == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// inRange reports whether the RFC3339 timestamp is at or after after and
+// before before, a zero bound being open.
+func inRange(timestamp string, after, before time.Time) bool {
+	if after.IsZero() && before.IsZero() {
+		return true
+	}
+
+	t, err := time.Parse(time.RFC3339, timestamp)
+	if err != nil {
+		return false
+	}
+	return (after.IsZero() || !t.Before(after)) && (before.IsZero() || t.Before(before))
+}
+
+func paramInt(value interface{}) (int, bool) {
+	switch v := value.(type) {
+	case int:
+		return v, true
+	case string:
+		var n int
+		_, err := fmt.Sscanf(v, "%d", &n)
+		return n, err == nil
+	default:
+		return 0, false
+	}
+}
+
+func paramTime(value interface{}) time.Time {
+	switch v := value.(type) {
+	case time.Time:
+		return v
+	case string:
+		t, _ := time.Parse(time.RFC3339, v)
+		return t
+	default:
+		return time.Time{}
+	}
+}
+
+func parseAmount(amount string) (float64, error) {
+	var value float64
+	_, err := fmt.Sscanf(amount, "%g", &value)
+	return value, err
+}
+
+// executor hands out a fixed list, so unlike the real ones it never blocks
+// nor leaks when the consumer stops early.
+type executor[T any] struct {
+	ch  chan T
+	err error
+}
+
+func newExecutor[T any](items []T) *executor[T] {
+	ch := make(chan T, len(items))
+	for _, item := range items {
+		ch <- item
+	}
+	close(ch)
+
+	return &executor[T]{ch: ch}
+}
+
+// failedExecutor sends nothing and reports err, like a failed fetch.
+func failedExecutor[T any](err error) *executor[T] {
+	e := newExecutor[T](nil)
+	e.err = err
+	return e
+}
+
+func (e *executor[T]) Close() {}
+
+func (e *executor[T]) Err() error {
+	return e.err
+}
+
+func (e *executor[T]) GetChan() <-chan T {
+	return e.ch
+}
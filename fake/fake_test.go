@@ -0,0 +1,198 @@
+package fake_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/aanzolaavila/splitwise.go"
+	"github.com/aanzolaavila/splitwise.go/resources"
+	"github.com/dcerbino-golib/smartsplitwise"
+	"github.com/dcerbino-golib/smartsplitwise/backup"
+	"github.com/dcerbino-golib/smartsplitwise/fake"
+	"github.com/stretchr/testify/assert"
+)
+
+func expense(id int, groupId int, date string, userIds ...int) resources.Expense {
+	e := resources.Expense{ID: resources.ExpenseID(id)}
+	e.GroupId = uint32(groupId)
+	e.Date = date
+	e.UpdatedAt = date
+	for _, userId := range userIds {
+		e.Users = append(e.Users, struct {
+			resources.User
+			UserId     uint64 `json:"user_id"`
+			PaidShare  string `json:"paid_share"`
+			OwedShare  string `json:"owed_share"`
+			NetBalance string `json:"net_balance"`
+		}{UserId: uint64(userId)})
+	}
+	return e
+}
+
+func collect[T any](ch <-chan T) []T {
+	list := []T{}
+	for item := range ch {
+		list = append(list, item)
+	}
+	return list
+}
+
+func TestGetExpensesFilters(t *testing.T) {
+	var conn smartsplitwise.SwConnection = fake.New(fake.WithExpenses(
+		expense(1, 10, "2024-01-05T00:00:00Z", 1, 2),
+		expense(2, 10, "2024-02-05T00:00:00Z", 1, 3),
+		expense(3, 20, "2024-03-05T00:00:00Z", 1, 2),
+	))
+
+	all := collect(conn.GetExpenses(splitwise.ExpensesParams{}).GetChan())
+	assert.Len(t, all, 3)
+	assert.Equal(t, resources.ExpenseID(3), all[0].ID)
+
+	group := collect(conn.GetExpenses(splitwise.ExpensesParams{splitwise.ExpensesGroupId: 10}).GetChan())
+	assert.Len(t, group, 2)
+
+	friend := collect(conn.GetExpenses(splitwise.ExpensesParams{splitwise.ExpensesFriendId: 2}).GetChan())
+	assert.Len(t, friend, 2)
+
+	dated := collect(conn.GetExpenses(splitwise.ExpensesParams{
+		splitwise.ExpensesDatedAfter:  "2024-02-01T00:00:00Z",
+		splitwise.ExpensesDatedBefore: "2024-03-01T00:00:00Z",
+	}).GetChan())
+	assert.Len(t, dated, 1)
+	assert.Equal(t, resources.ExpenseID(2), dated[0].ID)
+}
+
+func TestGroupsAndNotFound(t *testing.T) {
+	conn := fake.New(fake.WithGroups(resources.Group{ID: 10, Name: "Home"}))
+
+	group, err := conn.GetGroup(10)
+	assert.Nil(t, err)
+	assert.Equal(t, "Home", group.Name)
+
+	_, err = conn.GetGroup(11)
+	assert.ErrorIs(t, err, splitwise.ErrNotFound)
+
+	results := conn.GetGroupsByID([]int{10, 11})
+	assert.Nil(t, results[0].Err)
+	assert.NotNil(t, results[1].Err)
+}
+
+func TestWrites(t *testing.T) {
+	conn := fake.New(
+		fake.WithCurrentUser(resources.User{ID: 1}),
+		fake.WithGroups(resources.Group{ID: 10, Members: []resources.User{{ID: 1}, {ID: 2}}}),
+	)
+
+	created, err := conn.CreateExpenseEqualGroupSplit(30, "Dinner", 10, splitwise.CreateExpenseParams{
+		splitwise.CreateExpenseCurrencyCode: "EUR",
+	})
+	assert.Nil(t, err)
+	assert.Len(t, created, 1)
+	assert.Equal(t, "30.00", created[0].Cost)
+	assert.Equal(t, "EUR", created[0].CurrencyCode)
+	assert.Equal(t, "30.00", created[0].Users[0].PaidShare)
+	assert.Equal(t, "15.00", created[0].Users[1].OwedShare)
+
+	id := int(created[0].ID)
+	updated, err := conn.UpdateExpense(id, 40, "Dinner", 10, nil, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "40.00", updated[0].Cost)
+	assert.Len(t, updated[0].Users, 2)
+
+	assert.Nil(t, conn.DeleteExpense(id))
+	deleted, _ := conn.GetExpense(id)
+	assert.NotEmpty(t, deleted.DeletedAt)
+
+	assert.Nil(t, conn.RestoreExpense(id))
+	restored, _ := conn.GetExpense(id)
+	assert.Empty(t, restored.DeletedAt)
+
+	assert.ErrorIs(t, conn.DeleteExpense(999), splitwise.ErrNotFound)
+
+	assert.Equal(t, []fake.Call{
+		{Method: "CreateExpenseEqualGroupSplit", ExpenseID: id},
+		{Method: "UpdateExpense", ExpenseID: id},
+		{Method: "DeleteExpense", ExpenseID: id},
+		{Method: "RestoreExpense", ExpenseID: id},
+		{Method: "DeleteExpense", ExpenseID: 999},
+	}, conn.Calls())
+}
+
+func TestWithError(t *testing.T) {
+	failure := errors.New("boom")
+	conn := fake.New(fake.WithError("DeleteExpense", failure), fake.WithExpenses(expense(1, 10, "")))
+
+	assert.ErrorIs(t, conn.DeleteExpense(1), failure)
+	assert.Nil(t, conn.RestoreExpense(1))
+}
+
+type auditorStub struct {
+	entries []smartsplitwise.AuditEntry
+}
+
+func (a *auditorStub) Record(entry smartsplitwise.AuditEntry) error {
+	a.entries = append(a.entries, entry)
+	return nil
+}
+
+func TestAuditor(t *testing.T) {
+	auditor := &auditorStub{}
+	conn := fake.New(fake.WithExpenses(expense(1, 10, "")))
+	conn.SetAuditor(auditor)
+
+	assert.Nil(t, conn.DeleteExpense(1))
+
+	assert.Len(t, auditor.entries, 1)
+	assert.Equal(t, "delete_expense", auditor.entries[0].Operation)
+	assert.Empty(t, auditor.entries[0].Before.DeletedAt)
+	assert.NotEmpty(t, auditor.entries[0].After.DeletedAt)
+}
+
+func TestWithArchive(t *testing.T) {
+	commented := expense(1, 10, "2024-01-01T00:00:00Z", 7)
+	commented.CommentsCount = 1
+
+	var buf bytes.Buffer
+	source := fake.New(
+		fake.WithCurrentUser(resources.User{ID: 7, FirstName: "Diego"}),
+		fake.WithGroups(resources.Group{ID: 10, Name: "Home"}),
+		fake.WithExpenses(commented, expense(2, 10, "2024-01-02T00:00:00Z", 7)),
+		fake.WithComments(1, resources.Comment{ID: 3, Content: "paid"}),
+		fake.WithCurrencies(resources.Currency{CurrencyCode: "ARS", Unit: "$"}),
+	)
+	assert.Nil(t, source.DeleteExpense(2))
+
+	_, err := smartsplitwise.Backup(source, &buf, true)
+	assert.Nil(t, err)
+	archive, err := backup.Load(&buf)
+	assert.Nil(t, err)
+
+	conn := fake.New(fake.WithArchive(archive))
+
+	user, err := conn.GetCurrentUser()
+	assert.Nil(t, err)
+	assert.Equal(t, "Diego", user.FirstName)
+	assert.Equal(t, source.Expenses(), conn.Expenses())
+
+	group, err := conn.GetGroup(10)
+	assert.Nil(t, err)
+	assert.Equal(t, "Home", group.Name)
+
+	comments, err := conn.GetExpenseComments(1)
+	assert.Nil(t, err)
+	assert.Equal(t, "paid", comments[0].Content)
+	assert.Len(t, collect(conn.GetCurecies().GetChan()), 1)
+}
+
+func TestWithErrorFailsExecutors(t *testing.T) {
+	failure := errors.New("boom")
+	conn := fake.New(fake.WithError("GetGroups", failure), fake.WithGroups(resources.Group{ID: 1}))
+
+	groups, err := smartsplitwise.Collect(conn.GetGroups())
+	assert.ErrorIs(t, err, failure)
+	assert.Empty(t, groups)
+
+	_, err = smartsplitwise.Collect(conn.GetFriends())
+	assert.Nil(t, err)
+}
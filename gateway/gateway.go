@@ -0,0 +1,503 @@
+// Package gateway serves a simplified local JSON API over a SwConnection, so
+// internal tools authenticate with their own API key instead of holding the
+// Splitwise token.
+package gateway
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aanzolaavila/splitwise.go"
+	"github.com/aanzolaavila/splitwise.go/resources"
+	"github.com/dcerbino-golib/smartsplitwise"
+)
+
+const (
+	APIKeyHeader      = "X-API-Key"
+	DefaultTTL        = time.Minute
+	DefaultMaxEntries = 1000
+)
+
+type Server struct {
+	// TTL is how long a response is served from the cache before the
+	// connection is queried again. Zero disables the cache.
+	TTL time.Duration
+	// MaxEntries bounds the cached responses; the ones closest to expiring
+	// are dropped first. Zero leaves it unbounded.
+	MaxEntries int
+	// Logger, when set, logs every request and the upstream failures.
+	Logger *slog.Logger
+
+	conn    smartsplitwise.SwConnection
+	clients map[string]string
+	mux     *http.ServeMux
+
+	mu    sync.Mutex
+	cache map[string]cachedResponse
+}
+
+type cachedResponse struct {
+	body    []byte
+	expires time.Time
+}
+
+// New returns a gateway over conn. apiKeys maps every accepted key to the
+// name of the client using it, which is reported in the logs.
+func New(conn smartsplitwise.SwConnection, apiKeys map[string]string) *Server {
+	s := &Server{
+		TTL:        DefaultTTL,
+		MaxEntries: DefaultMaxEntries,
+		conn:       conn,
+		clients:    apiKeys,
+		mux:        http.NewServeMux(),
+		cache:      make(map[string]cachedResponse),
+	}
+
+	s.mux.HandleFunc("/groups", s.cached(s.handleGroups))
+	s.mux.HandleFunc("/expenses", s.cached(s.handleExpenses, "group", "friend", "after", "before", "updated_after", "limit"))
+	s.mux.HandleFunc("/balances", s.cached(s.handleBalances, "group"))
+	s.mux.HandleFunc("/settle-plan", s.cached(s.handleSettlePlan, "group"))
+	s.mux.HandleFunc("/notifications", s.cached(s.handleNotifications, "after"))
+
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	client, ok := s.authenticate(r)
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "missing or invalid API key")
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "only GET is supported")
+		return
+	}
+
+	start := time.Now()
+	rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	s.mux.ServeHTTP(rec, r)
+	if s.Logger != nil {
+		s.Logger.Info("request",
+			slog.String("client", client),
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.RequestURI()),
+			slog.Int("status", rec.status),
+			slog.Duration("duration", time.Since(start)),
+		)
+	}
+}
+
+// statusRecorder keeps the status written, for the request log.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Invalidate drops every cached response.
+func (s *Server) Invalidate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cache = make(map[string]cachedResponse)
+}
+
+func (s *Server) authenticate(r *http.Request) (string, bool) {
+	key := r.Header.Get(APIKeyHeader)
+	if key == "" {
+		key = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	}
+	if key == "" {
+		return "", false
+	}
+
+	for k, client := range s.clients {
+		if subtle.ConstantTimeCompare([]byte(k), []byte(key)) == 1 {
+			return client, true
+		}
+	}
+	return "", false
+}
+
+// handlerFunc returns the value to serve, or the status and error to report
+// instead. Only the values are cached.
+type handlerFunc func(r *http.Request) (interface{}, int, error)
+
+// cached serves h from the cache, keyed on the params h reads so that
+// unknown or reordered query parameters share the entry.
+func (s *Server) cached(h handlerFunc, params ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := cacheKey(r, params)
+
+		if body, ok := s.lookup(key); ok {
+			w.Header().Set("X-Cache", "HIT")
+			writeBody(w, http.StatusOK, body)
+			return
+		}
+
+		value, status, err := h(r)
+		if err != nil {
+			if status >= 500 && s.Logger != nil {
+				s.Logger.Warn("upstream failed", slog.String("path", r.URL.Path), slog.String("error", err.Error()))
+			}
+			writeError(w, status, err.Error())
+			return
+		}
+
+		body, err := json.Marshal(value)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		s.store(key, body)
+		w.Header().Set("X-Cache", "MISS")
+		writeBody(w, http.StatusOK, body)
+	}
+}
+
+func cacheKey(r *http.Request, params []string) string {
+	query := r.URL.Query()
+	values := url.Values{}
+	for _, p := range params {
+		if v := query.Get(p); v != "" {
+			values.Set(p, v)
+		}
+	}
+	return r.URL.Path + "?" + values.Encode()
+}
+
+func (s *Server) lookup(key string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.cache[key]
+	if !ok || time.Now().After(entry.expires) {
+		return nil, false
+	}
+	return entry.body, true
+}
+
+func (s *Server) store(key string, body []byte) {
+	if s.TTL <= 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if _, ok := s.cache[key]; !ok && s.MaxEntries > 0 && len(s.cache) >= s.MaxEntries {
+		s.evict(now)
+	}
+	s.cache[key] = cachedResponse{body: body, expires: now.Add(s.TTL)}
+}
+
+// evict drops the expired responses, or the one closest to expiring if
+// none is, to make room for another.
+func (s *Server) evict(now time.Time) {
+	oldest := ""
+	for key, entry := range s.cache {
+		if now.After(entry.expires) {
+			delete(s.cache, key)
+			continue
+		}
+		if oldest == "" || entry.expires.Before(s.cache[oldest].expires) {
+			oldest = key
+		}
+	}
+	if len(s.cache) >= s.MaxEntries {
+		delete(s.cache, oldest)
+	}
+}
+
+type badRequest string
+
+func (e badRequest) Error() string {
+	return string(e)
+}
+
+type member struct {
+	ID   uint64 `json:"id"`
+	Name string `json:"name"`
+}
+
+type group struct {
+	ID        uint64    `json:"id"`
+	Name      string    `json:"name"`
+	Type      string    `json:"type"`
+	Members   []member  `json:"members"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (s *Server) handleGroups(r *http.Request) (interface{}, int, error) {
+	groups := []group{}
+	executor := s.conn.GetGroups()
+	for g := range executor.GetChan() {
+		groups = append(groups, toGroup(g))
+	}
+	if err := executor.Err(); err != nil {
+		return nil, http.StatusBadGateway, err
+	}
+	return groups, http.StatusOK, nil
+}
+
+func toGroup(g resources.Group) group {
+	out := group{
+		ID:        uint64(g.ID),
+		Name:      g.Name,
+		Type:      g.Type,
+		Members:   []member{},
+		UpdatedAt: g.UpdatedAt,
+	}
+	for _, m := range g.Members {
+		out.Members = append(out.Members, member{ID: uint64(m.ID), Name: strings.TrimSpace(m.FirstName + " " + m.LastName)})
+	}
+	return out
+}
+
+type share struct {
+	UserID uint64 `json:"user_id"`
+	Paid   string `json:"paid"`
+	Owed   string `json:"owed"`
+}
+
+type expense struct {
+	ID          uint64  `json:"id"`
+	GroupID     uint32  `json:"group_id"`
+	Date        string  `json:"date"`
+	Description string  `json:"description"`
+	Cost        string  `json:"cost"`
+	Currency    string  `json:"currency"`
+	Category    string  `json:"category"`
+	Payment     bool    `json:"payment"`
+	Deleted     bool    `json:"deleted"`
+	Shares      []share `json:"shares"`
+}
+
+// handleExpenses accepts group, friend, after and before (dates as
+// YYYY-MM-DD or RFC3339), updated_after and limit.
+func (s *Server) handleExpenses(r *http.Request) (interface{}, int, error) {
+	query := r.URL.Query()
+	params := splitwise.ExpensesParams{}
+
+	ints := []struct {
+		name  string
+		apply func(v int)
+	}{
+		{"group", func(v int) { params[splitwise.ExpensesGroupId] = v }},
+		{"friend", func(v int) { params[splitwise.ExpensesFriendId] = v }},
+	}
+	for _, p := range ints {
+		if value := query.Get(p.name); value != "" {
+			v, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, http.StatusBadRequest, badRequest("invalid " + p.name)
+			}
+			p.apply(v)
+		}
+	}
+
+	dates := []struct {
+		name  string
+		apply func(t time.Time)
+	}{
+		{"after", func(t time.Time) { params[splitwise.ExpensesDatedAfter] = t }},
+		{"before", func(t time.Time) { params[splitwise.ExpensesDatedBefore] = t }},
+		{"updated_after", func(t time.Time) { params[splitwise.ExpensesUpdatedAfter] = t }},
+	}
+	for _, p := range dates {
+		if value := query.Get(p.name); value != "" {
+			t, err := parseDate(value)
+			if err != nil {
+				return nil, http.StatusBadRequest, badRequest("invalid " + p.name)
+			}
+			p.apply(t)
+		}
+	}
+
+	limit := 0
+	if value := query.Get("limit"); value != "" {
+		v, err := strconv.Atoi(value)
+		if err != nil || v < 0 {
+			return nil, http.StatusBadRequest, badRequest("invalid limit")
+		}
+		limit = v
+	}
+
+	expenses := []expense{}
+	executor := s.conn.GetExpenses(params)
+	for e := range executor.GetChan() {
+		expenses = append(expenses, toExpense(e))
+		if limit > 0 && len(expenses) >= limit {
+			executor.Close()
+		}
+	}
+	if err := executor.Err(); err != nil {
+		return nil, http.StatusBadGateway, err
+	}
+
+	return expenses, http.StatusOK, nil
+}
+
+func toExpense(e resources.Expense) expense {
+	out := expense{
+		ID:          uint64(e.ID),
+		GroupID:     e.GroupId,
+		Date:        e.Date,
+		Description: e.Description,
+		Cost:        e.Cost,
+		Currency:    e.CurrencyCode,
+		Category:    e.Category.Name,
+		Payment:     e.Payment,
+		Deleted:     e.DeletedAt != "",
+		Shares:      []share{},
+	}
+	for _, u := range e.Users {
+		out.Shares = append(out.Shares, share{UserID: u.UserId, Paid: u.PaidShare, Owed: u.OwedShare})
+	}
+	return out
+}
+
+type balance struct {
+	UserID   uint64 `json:"user_id"`
+	Name     string `json:"name"`
+	Currency string `json:"currency"`
+	Amount   string `json:"amount"`
+}
+
+// handleBalances reports the member balances of ?group=, or the overall
+// balance with every friend when no group is given.
+func (s *Server) handleBalances(r *http.Request) (interface{}, int, error) {
+	balances := []balance{}
+
+	if value := r.URL.Query().Get("group"); value != "" {
+		g, status, err := s.group(value)
+		if err != nil {
+			return nil, status, err
+		}
+
+		for _, m := range g.Members {
+			for _, b := range m.Balance {
+				balances = append(balances, balance{uint64(m.ID), strings.TrimSpace(m.FirstName + " " + m.LastName), b.CurrencyCode, b.Amount})
+			}
+		}
+		return balances, http.StatusOK, nil
+	}
+
+	executor := s.conn.GetFriends()
+	for f := range executor.GetChan() {
+		for _, b := range f.Balance {
+			balances = append(balances, balance{uint64(f.ID), strings.TrimSpace(f.FirstName + " " + f.LastName), b.CurrencyCode, b.Amount})
+		}
+	}
+	if err := executor.Err(); err != nil {
+		return nil, http.StatusBadGateway, err
+	}
+	return balances, http.StatusOK, nil
+}
+
+func (s *Server) handleSettlePlan(r *http.Request) (interface{}, int, error) {
+	value := r.URL.Query().Get("group")
+	if value == "" {
+		return nil, http.StatusBadRequest, badRequest("group is required")
+	}
+
+	g, status, err := s.group(value)
+	if err != nil {
+		return nil, status, err
+	}
+
+	transfers := smartsplitwise.SettlePlan(g)
+	if transfers == nil {
+		transfers = []smartsplitwise.Transfer{}
+	}
+	return transfers, http.StatusOK, nil
+}
+
+type notification struct {
+	ID        uint64    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Event     string    `json:"event"`
+	Actor     string    `json:"actor"`
+	Subject   string    `json:"subject"`
+	Group     string    `json:"group"`
+	Amount    string    `json:"amount,omitempty"`
+	Currency  string    `json:"currency,omitempty"`
+	Direction string    `json:"direction"`
+}
+
+func (s *Server) handleNotifications(r *http.Request) (interface{}, int, error) {
+	params := splitwise.NotificationsParams{}
+	if value := r.URL.Query().Get("after"); value != "" {
+		t, err := parseDate(value)
+		if err != nil {
+			return nil, http.StatusBadRequest, badRequest("invalid after")
+		}
+		params[splitwise.NotificationsUpdatedAfter] = t.Format(time.RFC3339)
+	}
+
+	notifications := []notification{}
+	executor := s.conn.GetNotifications(params)
+	for n := range executor.GetChan() {
+		ev := smartsplitwise.ParseNotification(n)
+		notifications = append(notifications, notification{
+			ID:        uint64(n.ID),
+			CreatedAt: n.CreatedAt,
+			Event:     ev.Type.String(),
+			Actor:     ev.Actor,
+			Subject:   ev.Subject,
+			Group:     ev.GroupName,
+			Amount:    ev.Amount,
+			Currency:  ev.Currency,
+			Direction: ev.Direction.String(),
+		})
+	}
+	if err := executor.Err(); err != nil {
+		return nil, http.StatusBadGateway, err
+	}
+	return notifications, http.StatusOK, nil
+}
+
+func (s *Server) group(value string) (resources.Group, int, error) {
+	id, err := strconv.Atoi(value)
+	if err != nil {
+		return resources.Group{}, http.StatusBadRequest, badRequest("invalid group")
+	}
+
+	g, err := s.conn.GetGroup(id)
+	if err != nil {
+		return resources.Group{}, http.StatusBadGateway, err
+	}
+	return g, http.StatusOK, nil
+}
+
+func parseDate(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
+
+func writeBody(w http.ResponseWriter, status int, body []byte) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	body, _ := json.Marshal(map[string]string{"error": message})
+	writeBody(w, status, body)
+}
@@ -0,0 +1,262 @@
+package gateway
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/dcerbino-golib/smartsplitwise"
+	"github.com/stretchr/testify/assert"
+)
+
+const testGroup = `{"group": {"id": 10, "name": "Home", "group_type": "home", "updated_at": "2023-01-09T14:41:00Z",
+	"members": [
+		{"id": 1, "first_name": "Ana", "last_name": "B", "balance": [{"currency_code": "USD", "amount": "-30.0"}]},
+		{"id": 2, "first_name": "Bob", "last_name": "C", "balance": [{"currency_code": "USD", "amount": "30.0"}]}
+	]}}`
+
+const testExpenses = `{"expenses": [
+	{"id": 100, "group_id": 10, "description": "Groceries", "cost": "60.0", "currency_code": "USD", "date": "2023-01-09T14:41:00Z",
+		"users": [{"user_id": 1, "paid_share": "0.0", "owed_share": "30.0"}, {"user_id": 2, "paid_share": "60.0", "owed_share": "30.0"}]},
+	{"id": 101, "group_id": 10, "description": "Rent", "cost": "500.0", "currency_code": "USD", "date": "2023-01-01T10:00:00Z",
+		"deleted_at": "2023-01-02T10:00:00Z"}
+]}`
+
+const testNotifications = `{"notifications": [
+	{"id": 7, "type": 0, "created_at": "2023-01-09T14:41:00Z",
+		"content": "<strong>Ana B.</strong> added <strong>“Groceries”</strong> in <strong>“Home”</strong>.<br><font color=\"#ff652f\">You owe $30.00</font>"}
+]}`
+
+func newTestServer(t *testing.T, calls *int32) *Server {
+	return newTestServerWith(t, testUpstream(calls))
+}
+
+func testUpstream(calls *int32) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(calls, 1)
+
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/get_groups"):
+			w.Write([]byte(`{"groups": [` + strings.TrimSuffix(strings.TrimPrefix(testGroup, `{"group": `), "}") + `]}`))
+		case strings.HasSuffix(r.URL.Path, "/get_group/10"):
+			w.Write([]byte(testGroup))
+		case strings.HasSuffix(r.URL.Path, "/get_expenses"):
+			if r.URL.Query().Get("offset") != "" {
+				w.Write([]byte(`{"expenses": []}`))
+				return
+			}
+			w.Write([]byte(testExpenses))
+		case strings.HasSuffix(r.URL.Path, "/get_friends"):
+			w.Write([]byte(`{"friends": [{"id": 2, "first_name": "Bob", "last_name": "C", "balance": [{"currency_code": "USD", "amount": "30.0"}]}]}`))
+		case strings.HasSuffix(r.URL.Path, "/get_notifications"):
+			w.Write([]byte(testNotifications))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors": {"base": ["not found"]}}`))
+		}
+	}
+}
+
+func newTestServerWith(t *testing.T, handler http.HandlerFunc) *Server {
+	upstream := httptest.NewServer(handler)
+	t.Cleanup(upstream.Close)
+
+	conn := smartsplitwise.OpenWithOptions(
+		smartsplitwise.WithToken("testtoken"),
+		smartsplitwise.WithBaseURL(upstream.URL, ""),
+		smartsplitwise.WithLogger(slog.New(slog.NewTextHandler(testWriter{t}, nil))),
+	)
+	return New(conn, map[string]string{"secret": "reports"})
+}
+
+type testWriter struct {
+	t *testing.T
+}
+
+func (w testWriter) Write(p []byte) (int, error) {
+	w.t.Log(string(p))
+	return len(p), nil
+}
+
+func get(t *testing.T, s *Server, target string, key string, v interface{}) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	if key != "" {
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+
+	if v != nil {
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), v), rec.Body.String())
+	}
+	return rec
+}
+
+func TestAuthentication(t *testing.T) {
+	var calls int32
+	s := newTestServer(t, &calls)
+
+	rec := get(t, s, "/groups", "", nil)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = get(t, s, "/groups", "wrong", nil)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Equal(t, int32(0), calls)
+
+	req := httptest.NewRequest(http.MethodGet, "/groups", nil)
+	req.Header.Set(APIKeyHeader, "secret")
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/groups", nil)
+	req.Header.Set(APIKeyHeader, "secret")
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
+
+func TestGroupsAndCache(t *testing.T) {
+	var calls int32
+	s := newTestServer(t, &calls)
+
+	groups := []group{}
+	rec := get(t, s, "/groups", "secret", &groups)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "MISS", rec.Header().Get("X-Cache"))
+	assert.Len(t, groups, 1)
+	assert.Equal(t, "Home", groups[0].Name)
+	assert.Equal(t, []member{{1, "Ana B"}, {2, "Bob C"}}, groups[0].Members)
+
+	rec = get(t, s, "/groups", "secret", nil)
+	assert.Equal(t, "HIT", rec.Header().Get("X-Cache"))
+	assert.Equal(t, int32(1), calls)
+
+	s.Invalidate()
+	get(t, s, "/groups", "secret", nil)
+	assert.Equal(t, int32(2), calls)
+}
+
+func TestExpenses(t *testing.T) {
+	var calls int32
+	s := newTestServer(t, &calls)
+
+	expenses := []expense{}
+	rec := get(t, s, "/expenses?group=10&after=2023-01-01", "secret", &expenses)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Len(t, expenses, 2)
+	assert.Equal(t, "Groceries", expenses[0].Description)
+	assert.Equal(t, []share{{1, "0.0", "30.0"}, {2, "60.0", "30.0"}}, expenses[0].Shares)
+	assert.True(t, expenses[1].Deleted)
+
+	expenses = []expense{}
+	get(t, s, "/expenses?limit=1", "secret", &expenses)
+	assert.Len(t, expenses, 1)
+
+	rec = get(t, s, "/expenses?after=yesterday", "secret", nil)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestBalancesAndSettlePlan(t *testing.T) {
+	var calls int32
+	s := newTestServer(t, &calls)
+
+	balances := []balance{}
+	rec := get(t, s, "/balances?group=10", "secret", &balances)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, []balance{{1, "Ana B", "USD", "-30.0"}, {2, "Bob C", "USD", "30.0"}}, balances)
+
+	transfers := []smartsplitwise.Transfer{}
+	get(t, s, "/settle-plan?group=10", "secret", &transfers)
+	assert.Equal(t, []smartsplitwise.Transfer{{From: 1, To: 2, Amount: "30.00", CurrencyCode: "USD"}}, transfers)
+
+	rec = get(t, s, "/settle-plan", "secret", nil)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = get(t, s, "/settle-plan?group=99", "secret", nil)
+	assert.Equal(t, http.StatusBadGateway, rec.Code)
+}
+
+func TestNotifications(t *testing.T) {
+	var calls int32
+	s := newTestServer(t, &calls)
+
+	notifications := []notification{}
+	rec := get(t, s, "/notifications", "secret", &notifications)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Len(t, notifications, 1)
+	assert.Equal(t, "expense_added", notifications[0].Event)
+	assert.Equal(t, "Groceries", notifications[0].Subject)
+	assert.Equal(t, "30.00", notifications[0].Amount)
+	assert.Equal(t, "you_owe", notifications[0].Direction)
+}
+
+func TestUpstreamFailuresAreNotCached(t *testing.T) {
+	var calls, down int32 = 0, 1
+	next := testUpstream(&calls)
+	s := newTestServerWith(t, func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&down) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"errors": {"base": ["unavailable"]}}`))
+			return
+		}
+		next(w, r)
+	})
+
+	paths := []string{"/groups", "/expenses?group=10", "/balances", "/notifications"}
+	for _, path := range paths {
+		rec := get(t, s, path, "secret", nil)
+		assert.Equal(t, http.StatusBadGateway, rec.Code, path)
+		assert.Empty(t, rec.Header().Get("X-Cache"), path)
+	}
+
+	atomic.StoreInt32(&down, 0)
+	for _, path := range paths {
+		rec := get(t, s, path, "secret", nil)
+		assert.Equal(t, http.StatusOK, rec.Code, path)
+		assert.Equal(t, "MISS", rec.Header().Get("X-Cache"), path)
+	}
+}
+
+func TestRequestLog(t *testing.T) {
+	var calls int32
+	s := newTestServer(t, &calls)
+	buf := bytes.Buffer{}
+	s.Logger = slog.New(slog.NewJSONHandler(&buf, nil))
+
+	get(t, s, "/groups", "secret", nil)
+	get(t, s, "/settle-plan?group=99", "secret", nil)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 3)
+	assert.Contains(t, lines[0], `"msg":"request","client":"reports","method":"GET","path":"/groups","status":200`)
+	assert.Contains(t, lines[1], `"msg":"upstream failed","path":"/settle-plan"`)
+	assert.Contains(t, lines[2], `"path":"/settle-plan?group=99","status":502`)
+}
+
+func TestCacheKeysAndBound(t *testing.T) {
+	var calls int32
+	s := newTestServer(t, &calls)
+	s.MaxEntries = 2
+
+	get(t, s, "/expenses?group=10&limit=1", "secret", nil)
+	rec := get(t, s, "/expenses?limit=1&group=10&cachebuster=1", "secret", nil)
+	assert.Equal(t, "HIT", rec.Header().Get("X-Cache"), "unknown and reordered parameters share the entry")
+
+	for i := 0; i < 5; i++ {
+		get(t, s, fmt.Sprintf("/expenses?limit=%d", i+2), "secret", nil)
+	}
+	s.mu.Lock()
+	assert.Len(t, s.cache, 2)
+	s.mu.Unlock()
+
+	rec = get(t, s, "/expenses?limit=6", "secret", nil)
+	assert.Equal(t, "HIT", rec.Header().Get("X-Cache"), "the newest entries are kept")
+}
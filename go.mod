@@ -1,14 +1,26 @@
 module github.com/dcerbino-golib/smartsplitwise
 
-go 1.20
+go 1.21
 
 require (
 	github.com/aanzolaavila/splitwise.go v0.2.0
+	github.com/gdamore/tcell/v2 v2.7.4
+	github.com/graph-gophers/dataloader v5.0.0+incompatible
+	github.com/graph-gophers/graphql-go v1.5.0
+	github.com/mattn/go-runewidth v0.0.15
 	github.com/stretchr/testify v1.8.2
+	golang.org/x/oauth2 v0.21.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/gdamore/encoding v1.0.0 // indirect
+	github.com/lucasb-eyer/go-colorful v1.2.0 // indirect
+	github.com/opentracing/opentracing-go v1.2.0 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
+	github.com/rivo/uniseg v0.4.3 // indirect
+	golang.org/x/sys v0.18.0 // indirect
+	golang.org/x/term v0.18.0 // indirect
+	golang.org/x/text v0.14.0 // indirect
 )
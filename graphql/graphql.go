@@ -0,0 +1,40 @@
+// Package graphql serves the Splitwise object graph reachable from a
+// SwConnection as a GraphQL schema. Friends, groups and expenses referenced
+// by ID are loaded through per-request dataloaders, so a query never fetches
+// the same object twice.
+package graphql
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/dcerbino-golib/smartsplitwise"
+	graphqlgo "github.com/graph-gophers/graphql-go"
+	"github.com/graph-gophers/graphql-go/relay"
+)
+
+type Server struct {
+	conn   smartsplitwise.SwConnection
+	schema *graphqlgo.Schema
+}
+
+func New(conn smartsplitwise.SwConnection) *Server {
+	return &Server{
+		conn:   conn,
+		schema: graphqlgo.MustParseSchema(schema, &rootResolver{conn: conn}),
+	}
+}
+
+// Exec runs a single query with fresh loaders.
+func (s *Server) Exec(ctx context.Context, query string, operationName string, variables map[string]interface{}) *graphqlgo.Response {
+	ctx = context.WithValue(ctx, loadersKey{}, newLoaders(s.conn))
+	return s.schema.Exec(ctx, query, operationName, variables)
+}
+
+// ServeHTTP accepts the usual {"query", "operationName", "variables"} POST
+// body.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := context.WithValue(r.Context(), loadersKey{}, newLoaders(s.conn))
+	handler := &relay.Handler{Schema: s.schema}
+	handler.ServeHTTP(w, r.WithContext(ctx))
+}
@@ -0,0 +1,248 @@
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/dcerbino-golib/smartsplitwise"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testCurrentUser = `{"user": {"id": 1, "first_name": "Me", "last_name": "Myself", "default_currency": "USD"}}`
+
+	testGroupHome = `{"id": 10, "name": "Home", "group_type": "home",
+		"members": [{"id": 1, "first_name": "Me"}, {"id": 2, "first_name": "Ana"}],
+		"simplified_debts": [{"from": 2, "to": 1, "amount": "30.0", "currency_code": "USD"}]}`
+	testGroupTrip = `{"id": 11, "name": "Trip", "group_type": "trip", "members": [{"id": 1, "first_name": "Me"}, {"id": 3, "first_name": "Bob"}]}`
+
+	testFriends = `{"friends": [
+		{"id": 2, "first_name": "Ana", "groups": [{"group_id": 10}, {"group_id": 0}], "balance": [{"currency_code": "USD", "amount": "-30.0"}]},
+		{"id": 3, "first_name": "Bob", "groups": [{"group_id": 11}]}
+	]}`
+
+	testExpense = `{"id": 100, "group_id": 10, "description": "Groceries", "cost": "60.0", "currency_code": "USD",
+		"category": {"id": 12, "name": "Groceries"},
+		"users": [{"user_id": 1, "paid_share": "60.0", "owed_share": "30.0"}, {"user_id": 2, "paid_share": "0.0", "owed_share": "30.0"}]}`
+
+	testExpenses = `{"expenses": [` + testExpense + `,
+		{"id": 101, "group_id": 10, "description": "Pizza", "cost": "20.0", "currency_code": "USD",
+			"users": [{"user_id": 2, "paid_share": "20.0", "owed_share": "10.0"}, {"user_id": 3, "paid_share": "0.0", "owed_share": "10.0"}]},
+		{"id": 102, "group_id": 10, "description": "Taxi", "cost": "9.0", "currency_code": "USD",
+			"users": [{"user_id": 3, "paid_share": "9.0", "owed_share": "9.0"}]}
+	]}`
+
+	testNotifications = `{"notifications": [
+		{"id": 7, "type": 0, "created_at": "2023-01-09T14:41:00Z", "source": {"id": 100, "type": "Expense"},
+			"content": "<strong>Ana</strong> added <strong>“Groceries”</strong> in <strong>“Home”</strong>.<br><font color=\"#5bc5a7\">You get back $30.00</font>"}
+	]}`
+)
+
+type callCounter struct {
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+func (c *callCounter) count(path string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.calls[path]
+}
+
+// newTestServer answers from canned responses; the failing paths answer
+// with a 500.
+func newTestServer(t *testing.T, failing ...string) (*Server, *callCounter) {
+	counter := &callCounter{calls: make(map[string]int)}
+
+	routes := map[string]string{
+		"/get_current_user":  testCurrentUser,
+		"/get_groups":        `{"groups": [` + testGroupHome + `,` + testGroupTrip + `]}`,
+		"/get_group/10":      `{"group": ` + testGroupHome + `}`,
+		"/get_group/11":      `{"group": ` + testGroupTrip + `}`,
+		"/get_friends":       testFriends,
+		"/get_friend/3":      `{"friend": {"id": 3, "first_name": "Bob"}}`,
+		"/get_expense/100":   `{"expense": ` + testExpense + `}`,
+		"/get_notifications": testNotifications,
+	}
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Path[strings.Index(r.URL.Path, "/get_"):]
+
+		counter.mu.Lock()
+		counter.calls[path]++
+		counter.mu.Unlock()
+
+		for _, f := range failing {
+			if path == f {
+				w.WriteHeader(http.StatusInternalServerError)
+				w.Write([]byte(`{"errors": {"base": ["unavailable"]}}`))
+				return
+			}
+		}
+
+		if path == "/get_expenses" {
+			if r.URL.Query().Get("offset") != "" {
+				w.Write([]byte(`{"expenses": []}`))
+			} else {
+				w.Write([]byte(testExpenses))
+			}
+			return
+		}
+
+		body, ok := routes[path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors": {"base": ["not found"]}}`))
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(upstream.Close)
+
+	conn := smartsplitwise.OpenWithOptions(
+		smartsplitwise.WithToken("testtoken"),
+		smartsplitwise.WithBaseURL(upstream.URL, ""),
+		smartsplitwise.WithLogger(slog.New(slog.NewTextHandler(testWriter{t}, nil))),
+	)
+	return New(conn), counter
+}
+
+type testWriter struct {
+	t *testing.T
+}
+
+func (w testWriter) Write(p []byte) (int, error) {
+	w.t.Log(string(p))
+	return len(p), nil
+}
+
+func exec(t *testing.T, s *Server, query string, v interface{}) {
+	res := s.Exec(context.Background(), query, "", nil)
+	assert.Empty(t, res.Errors)
+	assert.NoError(t, json.Unmarshal(res.Data, v), string(res.Data))
+}
+
+func TestExpensesDedupLookups(t *testing.T) {
+	s, counter := newTestServer(t)
+
+	var data struct {
+		Expenses []struct {
+			Description string
+			Group       struct{ Name string }
+			Category    struct{ Name string }
+			Shares      []struct {
+				User      struct{ FirstName string }
+				OwedShare string
+			}
+		}
+	}
+	exec(t, s, `{ expenses(group: "10") { description group { name } category { name } shares { user { firstName } owedShare } } }`, &data)
+
+	assert.Len(t, data.Expenses, 3)
+	assert.Equal(t, "Home", data.Expenses[0].Group.Name)
+	assert.Equal(t, "Groceries", data.Expenses[0].Category.Name)
+	assert.Equal(t, "Me", data.Expenses[0].Shares[0].User.FirstName)
+	assert.Equal(t, "Ana", data.Expenses[0].Shares[1].User.FirstName)
+	assert.Equal(t, "Bob", data.Expenses[1].Shares[1].User.FirstName)
+	assert.Equal(t, "Bob", data.Expenses[2].Shares[0].User.FirstName)
+
+	assert.Equal(t, 1, counter.count("/get_group/10"))
+	assert.Equal(t, 1, counter.count("/get_friend/3"))
+}
+
+func TestFriendsBatchGroups(t *testing.T) {
+	s, counter := newTestServer(t)
+
+	var data struct {
+		Friends []struct {
+			FirstName string
+			Balance   []struct{ Currency, Amount string }
+			Groups    []struct{ Name string }
+		}
+	}
+	exec(t, s, `{ friends { firstName balance { currency amount } groups { name } } }`, &data)
+
+	assert.Len(t, data.Friends, 2)
+	assert.Equal(t, "-30.0", data.Friends[0].Balance[0].Amount)
+	assert.Equal(t, "Home", data.Friends[0].Groups[0].Name)
+	assert.Equal(t, "Trip", data.Friends[1].Groups[0].Name)
+
+	assert.Equal(t, 1, counter.count("/get_groups"))
+	assert.Equal(t, 0, counter.count("/get_group/10")+counter.count("/get_group/11"))
+}
+
+func TestGroupDebtsAndNotifications(t *testing.T) {
+	s, _ := newTestServer(t)
+
+	var data struct {
+		Group struct {
+			SimplifiedDebts []struct {
+				From, To struct{ FirstName string }
+				Amount   string
+			}
+		}
+		Notifications []struct {
+			Type, Direction string
+			Expense         struct{ Description string }
+		}
+	}
+	exec(t, s, `{
+		group(id: "10") { simplifiedDebts { from { firstName } to { firstName } amount } }
+		notifications { type direction expense { description } }
+	}`, &data)
+
+	assert.Len(t, data.Group.SimplifiedDebts, 1)
+	assert.Equal(t, "Ana", data.Group.SimplifiedDebts[0].From.FirstName)
+	assert.Equal(t, "Me", data.Group.SimplifiedDebts[0].To.FirstName)
+	assert.Equal(t, "expense_added", data.Notifications[0].Type)
+	assert.Equal(t, "you_are_owed", data.Notifications[0].Direction)
+	assert.Equal(t, "Groceries", data.Notifications[0].Expense.Description)
+}
+
+func TestServeHTTP(t *testing.T) {
+	s, _ := newTestServer(t)
+
+	body := bytes.NewBufferString(`{"query": "query($id: ID!) { group(id: $id) { name } }", "variables": {"id": "11"}}`)
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/graphql", body))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"data": {"group": {"name": "Trip"}}}`, rec.Body.String())
+}
+
+func TestUpstreamFailuresAreErrors(t *testing.T) {
+	s, _ := newTestServer(t, "/get_groups", "/get_friends", "/get_expenses", "/get_notifications", "/get_categories", "/get_currencies")
+
+	for _, query := range []string{
+		`{ groups { name } }`,
+		`{ friends { firstName } }`,
+		`{ expenses { description } }`,
+		`{ notifications { type } }`,
+		`{ categories { name } }`,
+		`{ currencies { code } }`,
+		`{ currency(code: "USD") { code } }`,
+	} {
+		res := s.Exec(context.Background(), query, "", nil)
+		assert.NotEmpty(t, res.Errors, query)
+	}
+}
+
+func TestBatchFailuresAreErrors(t *testing.T) {
+	s, _ := newTestServer(t, "/get_groups", "/get_friends")
+
+	res := s.Exec(context.Background(), `{
+		home: group(id: "10") { name } trip: group(id: "11") { name }
+		ana: friend(id: "2") { firstName } bob: friend(id: "3") { firstName }
+	}`, "", nil)
+	assert.Len(t, res.Errors, 4)
+	for _, err := range res.Errors {
+		assert.Contains(t, err.Message, "status code 500")
+	}
+}
@@ -0,0 +1,153 @@
+package graphql
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/aanzolaavila/splitwise.go/resources"
+	"github.com/dcerbino-golib/smartsplitwise"
+	"github.com/graph-gophers/dataloader"
+)
+
+type loadersKey struct{}
+
+// loaders batch and memoize the lookups of a single request, so a query
+// touching the same friend or group from many expenses fetches it once.
+type loaders struct {
+	friends  *dataloader.Loader
+	groups   *dataloader.Loader
+	expenses *dataloader.Loader
+}
+
+func newLoaders(conn smartsplitwise.SwConnection) *loaders {
+	return &loaders{
+		friends:  dataloader.NewBatchedLoader(friendsBatch(conn)),
+		groups:   dataloader.NewBatchedLoader(groupsBatch(conn)),
+		expenses: dataloader.NewBatchedLoader(expensesBatch(conn)),
+	}
+}
+
+func loadersFrom(ctx context.Context) *loaders {
+	l, _ := ctx.Value(loadersKey{}).(*loaders)
+	return l
+}
+
+func idKey(id uint64) dataloader.Key {
+	return dataloader.StringKey(strconv.FormatUint(id, 10))
+}
+
+func keyIDs(keys dataloader.Keys) []int {
+	ids := make([]int, len(keys))
+	for i, k := range keys {
+		ids[i], _ = strconv.Atoi(k.String())
+	}
+	return ids
+}
+
+// friendsBatch fetches a single friend directly and lists all the friends
+// once when more than one is requested.
+func friendsBatch(conn smartsplitwise.SwConnection) dataloader.BatchFunc {
+	return func(ctx context.Context, keys dataloader.Keys) []*dataloader.Result {
+		ids := keyIDs(keys)
+		results := make([]*dataloader.Result, len(ids))
+
+		if len(ids) == 1 {
+			f, err := conn.GetFriend(ids[0])
+			results[0] = &dataloader.Result{Data: f, Error: err}
+			return results
+		}
+
+		list, err := smartsplitwise.Collect(conn.GetFriends())
+		if err != nil {
+			for i := range results {
+				results[i] = &dataloader.Result{Error: err}
+			}
+			return results
+		}
+
+		byID := make(map[int]resources.Friend)
+		for _, f := range list {
+			byID[int(f.ID)] = f
+		}
+
+		for i, id := range ids {
+			if f, ok := byID[id]; ok {
+				results[i] = &dataloader.Result{Data: f}
+			} else {
+				results[i] = &dataloader.Result{Error: &smartsplitwise.ElementNotFound{}}
+			}
+		}
+		return results
+	}
+}
+
+func groupsBatch(conn smartsplitwise.SwConnection) dataloader.BatchFunc {
+	return func(ctx context.Context, keys dataloader.Keys) []*dataloader.Result {
+		ids := keyIDs(keys)
+		results := make([]*dataloader.Result, len(ids))
+
+		if len(ids) == 1 {
+			g, err := conn.GetGroup(ids[0])
+			results[0] = &dataloader.Result{Data: g, Error: err}
+			return results
+		}
+
+		list, err := smartsplitwise.Collect(conn.GetGroups())
+		if err != nil {
+			for i := range results {
+				results[i] = &dataloader.Result{Error: err}
+			}
+			return results
+		}
+
+		byID := make(map[int]resources.Group)
+		for _, g := range list {
+			byID[int(g.ID)] = g
+		}
+
+		for i, id := range ids {
+			if g, ok := byID[id]; ok {
+				results[i] = &dataloader.Result{Data: g}
+			} else {
+				results[i] = &dataloader.Result{Error: &smartsplitwise.ElementNotFound{}}
+			}
+		}
+		return results
+	}
+}
+
+// expensesBatch has no list endpoint to batch on, it only dedups the IDs.
+func expensesBatch(conn smartsplitwise.SwConnection) dataloader.BatchFunc {
+	return func(ctx context.Context, keys dataloader.Keys) []*dataloader.Result {
+		results := make([]*dataloader.Result, len(keys))
+		for i, id := range keyIDs(keys) {
+			e, err := conn.GetExpense(id)
+			results[i] = &dataloader.Result{Data: e, Error: err}
+		}
+		return results
+	}
+}
+
+func (l *loaders) friend(ctx context.Context, id uint64) (resources.Friend, error) {
+	v, err := l.friends.Load(ctx, idKey(id))()
+	if err != nil {
+		return resources.Friend{}, err
+	}
+	return v.(resources.Friend), nil
+}
+
+func (l *loaders) group(ctx context.Context, id uint64) (resources.Group, error) {
+	v, err := l.groups.Load(ctx, idKey(id))()
+	if err != nil {
+		return resources.Group{}, err
+	}
+	return v.(resources.Group), nil
+}
+
+func (l *loaders) expense(ctx context.Context, id uint64) (resources.Expense, error) {
+	v, err := l.expenses.Load(ctx, idKey(id))()
+	if err != nil {
+		return resources.Expense{}, err
+	}
+	return v.(resources.Expense), nil
+}
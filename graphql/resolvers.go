@@ -0,0 +1,521 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/aanzolaavila/splitwise.go"
+	"github.com/aanzolaavila/splitwise.go/resources"
+	"github.com/dcerbino-golib/smartsplitwise"
+	graphqlgo "github.com/graph-gophers/graphql-go"
+)
+
+type rootResolver struct {
+	conn smartsplitwise.SwConnection
+}
+
+func toID(id uint64) graphqlgo.ID {
+	return graphqlgo.ID(strconv.FormatUint(id, 10))
+}
+
+func fromID(id graphqlgo.ID) (int, error) {
+	return strconv.Atoi(string(id))
+}
+
+func (r *rootResolver) Me() (*userResolver, error) {
+	user, err := r.conn.GetCurrentUser()
+	if err != nil {
+		return nil, err
+	}
+	return &userResolver{user}, nil
+}
+
+func (r *rootResolver) Groups() ([]*groupResolver, error) {
+	list, err := smartsplitwise.Collect(r.conn.GetGroups())
+	if err != nil {
+		return nil, err
+	}
+
+	groups := []*groupResolver{}
+	for _, g := range list {
+		groups = append(groups, &groupResolver{r, g})
+	}
+	return groups, nil
+}
+
+func (r *rootResolver) Group(ctx context.Context, args struct{ ID graphqlgo.ID }) (*groupResolver, error) {
+	id, err := fromID(args.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	g, err := loadersFrom(ctx).group(ctx, uint64(id))
+	if err != nil {
+		return nil, err
+	}
+	return &groupResolver{r, g}, nil
+}
+
+func (r *rootResolver) Friends() ([]*friendResolver, error) {
+	list, err := smartsplitwise.Collect(r.conn.GetFriends())
+	if err != nil {
+		return nil, err
+	}
+
+	friends := []*friendResolver{}
+	for _, f := range list {
+		friends = append(friends, &friendResolver{r, f})
+	}
+	return friends, nil
+}
+
+func (r *rootResolver) Friend(ctx context.Context, args struct{ ID graphqlgo.ID }) (*friendResolver, error) {
+	id, err := fromID(args.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := loadersFrom(ctx).friend(ctx, uint64(id))
+	if err != nil {
+		return nil, err
+	}
+	return &friendResolver{r, f}, nil
+}
+
+type expensesArgs struct {
+	Group        *graphqlgo.ID
+	Friend       *graphqlgo.ID
+	DatedAfter   *string
+	DatedBefore  *string
+	UpdatedAfter *string
+	Limit        *int32
+}
+
+func (r *rootResolver) Expenses(args expensesArgs) ([]*expenseResolver, error) {
+	params := splitwise.ExpensesParams{}
+
+	ids := []struct {
+		value *graphqlgo.ID
+		apply func(id int)
+	}{
+		{args.Group, func(id int) { params[splitwise.ExpensesGroupId] = id }},
+		{args.Friend, func(id int) { params[splitwise.ExpensesFriendId] = id }},
+	}
+	for _, p := range ids {
+		if p.value == nil {
+			continue
+		}
+		id, err := fromID(*p.value)
+		if err != nil {
+			return nil, err
+		}
+		p.apply(id)
+	}
+
+	dates := []struct {
+		value *string
+		apply func(t time.Time)
+	}{
+		{args.DatedAfter, func(t time.Time) { params[splitwise.ExpensesDatedAfter] = t }},
+		{args.DatedBefore, func(t time.Time) { params[splitwise.ExpensesDatedBefore] = t }},
+		{args.UpdatedAfter, func(t time.Time) { params[splitwise.ExpensesUpdatedAfter] = t }},
+	}
+	for _, p := range dates {
+		if p.value == nil {
+			continue
+		}
+		t, err := parseDate(*p.value)
+		if err != nil {
+			return nil, err
+		}
+		p.apply(t)
+	}
+
+	limit := 0
+	if args.Limit != nil {
+		limit = int(*args.Limit)
+	}
+	return r.expenses(params, limit)
+}
+
+func (r *rootResolver) expenses(params splitwise.ExpensesParams, limit int) ([]*expenseResolver, error) {
+	expenses := []*expenseResolver{}
+
+	executor := r.conn.GetExpenses(params)
+	for e := range executor.GetChan() {
+		expenses = append(expenses, &expenseResolver{r, e})
+		if limit > 0 && len(expenses) >= limit {
+			executor.Close()
+		}
+	}
+	if err := executor.Err(); err != nil {
+		return nil, err
+	}
+	return expenses, nil
+}
+
+func (r *rootResolver) Expense(ctx context.Context, args struct{ ID graphqlgo.ID }) (*expenseResolver, error) {
+	id, err := fromID(args.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	e, err := loadersFrom(ctx).expense(ctx, uint64(id))
+	if err != nil {
+		return nil, err
+	}
+	return &expenseResolver{r, e}, nil
+}
+
+func (r *rootResolver) Categories() ([]*categoryResolver, error) {
+	list, err := smartsplitwise.Collect(r.conn.GetMainCategories())
+	if err != nil {
+		return nil, err
+	}
+
+	categories := []*categoryResolver{}
+	for _, c := range list {
+		categories = append(categories, &categoryResolver{c.Category, c.Subcategories})
+	}
+	return categories, nil
+}
+
+func (r *rootResolver) Currencies() ([]*currencyResolver, error) {
+	list, err := smartsplitwise.Collect(r.conn.GetCurecies())
+	if err != nil {
+		return nil, err
+	}
+
+	currencies := []*currencyResolver{}
+	for _, c := range list {
+		currencies = append(currencies, &currencyResolver{c})
+	}
+	return currencies, nil
+}
+
+// Currency is null for an unknown code and fails if the currencies cannot
+// be loaded.
+func (r *rootResolver) Currency(args struct{ Code string }) (*currencyResolver, error) {
+	c, err := r.conn.GetCurency(args.Code)
+	if isNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &currencyResolver{*c}, nil
+}
+
+func (r *rootResolver) Notifications(args struct {
+	UpdatedAfter *string
+	Limit        *int32
+}) ([]*notificationResolver, error) {
+	params := splitwise.NotificationsParams{}
+	if args.UpdatedAfter != nil {
+		t, err := parseDate(*args.UpdatedAfter)
+		if err != nil {
+			return nil, err
+		}
+		params[splitwise.NotificationsUpdatedAfter] = t.Format(time.RFC3339)
+	}
+	if args.Limit != nil {
+		params[splitwise.NotificationsLimit] = int(*args.Limit)
+	}
+
+	list, err := smartsplitwise.Collect(r.conn.GetNotifications(params))
+	if err != nil {
+		return nil, err
+	}
+
+	notifications := []*notificationResolver{}
+	for _, n := range list {
+		notifications = append(notifications, &notificationResolver{r, smartsplitwise.ParseNotification(n)})
+	}
+	return notifications, nil
+}
+
+// user resolves a user ID referenced by a share or a debt: the current user,
+// a member of the group the reference belongs to, or a friend.
+func (r *rootResolver) user(ctx context.Context, id uint64, groupID uint64) *userResolver {
+	if me, err := r.conn.GetCurrentUser(); err == nil && uint64(me.ID) == id {
+		return &userResolver{me}
+	}
+
+	l := loadersFrom(ctx)
+	if groupID != 0 {
+		if g, err := l.group(ctx, groupID); err == nil {
+			for _, m := range g.Members {
+				if uint64(m.ID) == id {
+					return &userResolver{m}
+				}
+			}
+		}
+	}
+
+	f, err := l.friend(ctx, id)
+	if err != nil {
+		return nil
+	}
+	return &userResolver{resources.User{
+		ID:        resources.UserID(f.ID),
+		FirstName: f.FirstName,
+		LastName:  f.LastName,
+		Email:     f.Email,
+	}}
+}
+
+type userResolver struct {
+	user resources.User
+}
+
+func (u *userResolver) ID() graphqlgo.ID        { return toID(uint64(u.user.ID)) }
+func (u *userResolver) FirstName() string       { return u.user.FirstName }
+func (u *userResolver) LastName() string        { return u.user.LastName }
+func (u *userResolver) Email() string           { return u.user.Email }
+func (u *userResolver) DefaultCurrency() string { return u.user.DefaultCurrency }
+
+type balanceResolver struct {
+	currency string
+	amount   string
+}
+
+func (b *balanceResolver) Currency() string { return b.currency }
+func (b *balanceResolver) Amount() string   { return b.amount }
+
+type friendResolver struct {
+	root   *rootResolver
+	friend resources.Friend
+}
+
+func (f *friendResolver) ID() graphqlgo.ID  { return toID(uint64(f.friend.ID)) }
+func (f *friendResolver) FirstName() string { return f.friend.FirstName }
+func (f *friendResolver) LastName() string  { return f.friend.LastName }
+func (f *friendResolver) Email() string     { return f.friend.Email }
+
+func (f *friendResolver) Balance() []*balanceResolver {
+	balances := []*balanceResolver{}
+	for _, b := range f.friend.Balance {
+		balances = append(balances, &balanceResolver{b.CurrencyCode, b.Amount})
+	}
+	return balances
+}
+
+func (f *friendResolver) Groups(ctx context.Context) ([]*groupResolver, error) {
+	groups := []*groupResolver{}
+	for _, fg := range f.friend.Groups {
+		// 0 is the pseudo group of the expenses outside any group.
+		if fg.GroupId == 0 {
+			continue
+		}
+		g, err := loadersFrom(ctx).group(ctx, uint64(fg.GroupId))
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, &groupResolver{f.root, g})
+	}
+	return groups, nil
+}
+
+type groupResolver struct {
+	root  *rootResolver
+	group resources.Group
+}
+
+func (g *groupResolver) ID() graphqlgo.ID  { return toID(uint64(g.group.ID)) }
+func (g *groupResolver) Name() string      { return g.group.Name }
+func (g *groupResolver) Type() string      { return g.group.Type }
+func (g *groupResolver) UpdatedAt() string { return g.group.UpdatedAt.Format(time.RFC3339) }
+
+func (g *groupResolver) Members() []*userResolver {
+	members := []*userResolver{}
+	for _, m := range g.group.Members {
+		members = append(members, &userResolver{m})
+	}
+	return members
+}
+
+func (g *groupResolver) SimplifiedDebts() []*debtResolver {
+	debts := []*debtResolver{}
+	for _, d := range g.group.SimplifiedDebts {
+		debts = append(debts, &debtResolver{g.root, uint64(g.group.ID), d})
+	}
+	return debts
+}
+
+func (g *groupResolver) Expenses(args struct{ Limit *int32 }) ([]*expenseResolver, error) {
+	limit := 0
+	if args.Limit != nil {
+		limit = int(*args.Limit)
+	}
+	return g.root.expenses(splitwise.ExpensesParams{splitwise.ExpensesGroupId: int(g.group.ID)}, limit)
+}
+
+type debtResolver struct {
+	root    *rootResolver
+	groupID uint64
+	debt    resources.Debt
+}
+
+func (d *debtResolver) From(ctx context.Context) *userResolver {
+	return d.root.user(ctx, uint64(d.debt.From), d.groupID)
+}
+
+func (d *debtResolver) To(ctx context.Context) *userResolver {
+	return d.root.user(ctx, uint64(d.debt.To), d.groupID)
+}
+
+func (d *debtResolver) Currency() string { return d.debt.CurrencyCode }
+func (d *debtResolver) Amount() string   { return d.debt.Amount }
+
+type expenseResolver struct {
+	root    *rootResolver
+	expense resources.Expense
+}
+
+func (e *expenseResolver) ID() graphqlgo.ID    { return toID(uint64(e.expense.ID)) }
+func (e *expenseResolver) Description() string { return e.expense.Description }
+func (e *expenseResolver) Details() string     { return e.expense.Details }
+func (e *expenseResolver) Cost() string        { return e.expense.Cost }
+func (e *expenseResolver) Date() string        { return e.expense.Date }
+func (e *expenseResolver) Payment() bool       { return e.expense.Payment }
+func (e *expenseResolver) Deleted() bool       { return e.expense.DeletedAt != "" }
+
+// Currency falls back to the bare code when the currency is not in the
+// cache loaded at startup.
+func (e *expenseResolver) Currency() (*currencyResolver, error) {
+	c, err := e.root.conn.GetCurency(e.expense.CurrencyCode)
+	if isNotFound(err) {
+		return &currencyResolver{resources.Currency{CurrencyCode: e.expense.CurrencyCode}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &currencyResolver{*c}, nil
+}
+
+func (e *expenseResolver) CreatedBy(ctx context.Context) *userResolver {
+	if e.expense.CreatedBy.ID == 0 {
+		return nil
+	}
+	return &userResolver{e.expense.CreatedBy}
+}
+
+func (e *expenseResolver) Group(ctx context.Context) (*groupResolver, error) {
+	if e.expense.GroupId == 0 {
+		return nil, nil
+	}
+
+	g, err := loadersFrom(ctx).group(ctx, uint64(e.expense.GroupId))
+	if err != nil {
+		return nil, err
+	}
+	return &groupResolver{e.root, g}, nil
+}
+
+func (e *expenseResolver) Category() *categoryResolver {
+	return &categoryResolver{category: resources.Category{
+		ID:   e.expense.Category.ID,
+		Name: e.expense.Category.Name,
+	}}
+}
+
+func (e *expenseResolver) Shares() []*shareResolver {
+	shares := []*shareResolver{}
+	for _, u := range e.expense.Users {
+		shares = append(shares, &shareResolver{
+			root:       e.root,
+			groupID:    uint64(e.expense.GroupId),
+			userID:     u.UserId,
+			paidShare:  u.PaidShare,
+			owedShare:  u.OwedShare,
+			netBalance: u.NetBalance,
+		})
+	}
+	return shares
+}
+
+type shareResolver struct {
+	root       *rootResolver
+	groupID    uint64
+	userID     uint64
+	paidShare  string
+	owedShare  string
+	netBalance string
+}
+
+func (s *shareResolver) User(ctx context.Context) *userResolver {
+	return s.root.user(ctx, s.userID, s.groupID)
+}
+
+func (s *shareResolver) PaidShare() string  { return s.paidShare }
+func (s *shareResolver) OwedShare() string  { return s.owedShare }
+func (s *shareResolver) NetBalance() string { return s.netBalance }
+
+type categoryResolver struct {
+	category      resources.Category
+	subcategories []resources.Category
+}
+
+func (c *categoryResolver) ID() graphqlgo.ID { return toID(uint64(c.category.ID)) }
+func (c *categoryResolver) Name() string     { return c.category.Name }
+
+func (c *categoryResolver) Subcategories() []*categoryResolver {
+	subcategories := []*categoryResolver{}
+	for _, s := range c.subcategories {
+		subcategories = append(subcategories, &categoryResolver{category: s})
+	}
+	return subcategories
+}
+
+type currencyResolver struct {
+	currency resources.Currency
+}
+
+func (c *currencyResolver) Code() string { return c.currency.CurrencyCode }
+func (c *currencyResolver) Unit() string { return c.currency.Unit }
+
+type notificationResolver struct {
+	root  *rootResolver
+	event smartsplitwise.NotificationEvent
+}
+
+func (n *notificationResolver) ID() graphqlgo.ID  { return toID(uint64(n.event.Notification.ID)) }
+func (n *notificationResolver) Type() string      { return n.event.Type.String() }
+func (n *notificationResolver) Content() string   { return n.event.Notification.Content }
+func (n *notificationResolver) Actor() string     { return n.event.Actor }
+func (n *notificationResolver) Subject() string   { return n.event.Subject }
+func (n *notificationResolver) GroupName() string { return n.event.GroupName }
+func (n *notificationResolver) Amount() string    { return n.event.Amount }
+func (n *notificationResolver) Currency() string  { return n.event.Currency }
+func (n *notificationResolver) Direction() string { return n.event.Direction.String() }
+
+func (n *notificationResolver) CreatedAt() string {
+	return n.event.Notification.CreatedAt.Format(time.RFC3339)
+}
+
+func (n *notificationResolver) Expense(ctx context.Context) *expenseResolver {
+	source := n.event.Notification.Source
+	if source.Type != "Expense" || source.ID == 0 {
+		return nil
+	}
+
+	e, err := loadersFrom(ctx).expense(ctx, uint64(source.ID))
+	if err != nil {
+		return nil
+	}
+	return &expenseResolver{n.root, e}
+}
+
+func isNotFound(err error) bool {
+	var notFound *smartsplitwise.ElementNotFound
+	return errors.As(err, &notFound)
+}
+
+func parseDate(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
@@ -0,0 +1,107 @@
+package graphql
+
+const schema = `
+schema {
+	query: Query
+}
+
+type Query {
+	me: User!
+	groups: [Group!]!
+	group(id: ID!): Group
+	friends: [Friend!]!
+	friend(id: ID!): Friend
+	expenses(group: ID, friend: ID, datedAfter: String, datedBefore: String, updatedAfter: String, limit: Int): [Expense!]!
+	expense(id: ID!): Expense
+	categories: [Category!]!
+	currencies: [Currency!]!
+	currency(code: String!): Currency
+	notifications(updatedAfter: String, limit: Int): [Notification!]!
+}
+
+type User {
+	id: ID!
+	firstName: String!
+	lastName: String!
+	email: String!
+	defaultCurrency: String!
+}
+
+type Balance {
+	currency: String!
+	amount: String!
+}
+
+type Friend {
+	id: ID!
+	firstName: String!
+	lastName: String!
+	email: String!
+	balance: [Balance!]!
+	groups: [Group!]!
+}
+
+type Debt {
+	from: User
+	to: User
+	currency: String!
+	amount: String!
+}
+
+type Group {
+	id: ID!
+	name: String!
+	type: String!
+	updatedAt: String!
+	members: [User!]!
+	simplifiedDebts: [Debt!]!
+	expenses(limit: Int): [Expense!]!
+}
+
+type Share {
+	user: User
+	paidShare: String!
+	owedShare: String!
+	netBalance: String!
+}
+
+type Category {
+	id: ID!
+	name: String!
+	subcategories: [Category!]!
+}
+
+type Currency {
+	code: String!
+	unit: String!
+}
+
+type Expense {
+	id: ID!
+	description: String!
+	details: String!
+	cost: String!
+	currency: Currency!
+	date: String!
+	payment: Boolean!
+	deleted: Boolean!
+	createdBy: User
+	group: Group
+	category: Category!
+	shares: [Share!]!
+}
+
+type Notification {
+	id: ID!
+	type: String!
+	content: String!
+	createdAt: String!
+	actor: String!
+	subject: String!
+	groupName: String!
+	amount: String!
+	currency: String!
+	direction: String!
+	expense: Expense
+}
+`
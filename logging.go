@@ -0,0 +1,98 @@
+package smartsplitwise
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// discardHandler drops every record, so connections are silent unless a
+// logger is given.
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
+
+func discardLogger() *slog.Logger {
+	return slog.New(discardHandler{})
+}
+
+// LevelTrace is below debug and only enabled explicitly.
+const LevelTrace = slog.LevelDebug - 4
+
+// printfLogger adapts the Printf logger expected by splitwise.Client. Its
+// request lines repeat what loggingClient logs, so they go to LevelTrace.
+type printfLogger struct {
+	logger *slog.Logger
+}
+
+func (l printfLogger) Printf(format string, v ...interface{}) {
+	msg := strings.TrimSpace(fmt.Sprintf(format, v...))
+
+	level := LevelTrace
+	if strings.HasPrefix(msg, "Warning") {
+		level = slog.LevelWarn
+	}
+	l.logger.Log(context.Background(), level, msg, slog.String("source", "splitwise.Client"))
+}
+
+// loggingClient logs every request with its ID, status and duration.
+type loggingClient struct {
+	logger *slog.Logger
+	next   HTTPClient
+}
+
+func (c loggingClient) Do(req *http.Request) (*http.Response, error) {
+	if !c.logger.Enabled(req.Context(), slog.LevelWarn) {
+		return c.next.Do(req)
+	}
+
+	logger := c.logger.With(
+		slog.String("request_id", newRequestID()),
+		slog.String("method", req.Method),
+		slog.String("path", req.URL.Path),
+	)
+	if query := req.URL.RawQuery; query != "" {
+		logger = logger.With(slog.String("query", query))
+	}
+
+	start := time.Now()
+	res, err := c.next.Do(req)
+	duration := slog.Duration("duration", time.Since(start))
+
+	if err != nil {
+		logger.Warn("request failed", duration, slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	level := slog.LevelDebug
+	if res.StatusCode >= 400 {
+		level = slog.LevelWarn
+	}
+	logger.Log(req.Context(), level, "request", duration, slog.Int("status", res.StatusCode))
+	return res, nil
+}
+
+func newRequestID() string {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// connLogger returns the logger of conn, or a discarding one for the
+// connections implemented outside this package.
+func connLogger(conn SwConnection) *slog.Logger {
+	if c, ok := conn.(*swConnectionStruct); ok {
+		return c.logger
+	}
+	return discardLogger()
+}
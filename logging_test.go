@@ -0,0 +1,97 @@
+package smartsplitwise
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aanzolaavila/splitwise.go"
+	"github.com/stretchr/testify/assert"
+)
+
+func getLogRecords(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	var records []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		record := map[string]interface{}{}
+		assert.NoError(t, json.Unmarshal([]byte(line), &record), line)
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestRequestLogging(t *testing.T) {
+	doFunc := func(r *http.Request) (*http.Response, error) {
+		resposne := http.Response{}
+		resposne.Body = io.NopCloser(strings.NewReader(testFriend))
+		resposne.StatusCode = 200
+		if strings.HasSuffix(r.URL.Path, "/1") {
+			resposne.StatusCode = 404
+			resposne.Body = io.NopCloser(strings.NewReader(`{"errors": {"base": ["not found"]}}`))
+		}
+		return &resposne, nil
+	}
+
+	buf := &bytes.Buffer{}
+	logger := slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	conn := OpenWithOptions(WithHTTPClient(httpClientStub{DoFunc: doFunc}), WithLogger(logger))
+
+	_, err := conn.GetFriend(12345)
+	assert.NoError(t, err)
+	_, err = conn.GetFriend(1)
+	assert.Error(t, err)
+
+	records := getLogRecords(t, buf)
+	assert.Len(t, records, 2)
+
+	assert.Equal(t, "DEBUG", records[0]["level"])
+	assert.Equal(t, "request", records[0]["msg"])
+	assert.Equal(t, "GET", records[0]["method"])
+	assert.Equal(t, "/api/v3.0/get_friend/12345", records[0]["path"])
+	assert.Equal(t, float64(200), records[0]["status"])
+	assert.NotEmpty(t, records[0]["request_id"])
+	assert.Contains(t, records[0], "duration")
+
+	assert.Equal(t, "WARN", records[1]["level"])
+	assert.Equal(t, float64(404), records[1]["status"])
+	assert.NotEqual(t, records[0]["request_id"], records[1]["request_id"])
+}
+
+func TestExecutorLogging(t *testing.T) {
+	doFunc := func(r *http.Request) (*http.Response, error) {
+		resposne := http.Response{}
+		resposne.Body = io.NopCloser(strings.NewReader(`{"expenses": []}`))
+		resposne.StatusCode = 200
+		if strings.HasSuffix(r.URL.Path, "/get_groups") {
+			resposne.StatusCode = 401
+			resposne.Body = io.NopCloser(strings.NewReader(`{"error": "Invalid API request: you are not logged in"}`))
+		}
+		return &resposne, nil
+	}
+
+	buf := &bytes.Buffer{}
+	logger := slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	conn := OpenWithOptions(WithHTTPClient(httpClientStub{DoFunc: doFunc}), WithLogger(logger))
+
+	for range conn.GetExpenses(splitwise.ExpensesParams{}).GetChan() {
+	}
+	assert.Empty(t, buf.String())
+
+	for range conn.GetGroups().GetChan() {
+	}
+
+	records := getLogRecords(t, buf)
+	assert.Len(t, records, 2)
+	assert.Equal(t, "WARN", records[0]["level"])
+	assert.Equal(t, float64(401), records[0]["status"])
+	assert.Equal(t, "ERROR", records[1]["level"])
+	assert.Equal(t, "groups", records[1]["resource"])
+}
+
+func TestSilentByDefault(t *testing.T) {
+	conn := OpenWithOptions()
+	assert.False(t, connLogger(conn).Enabled(nil, slog.LevelError))
+}
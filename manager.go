@@ -0,0 +1,196 @@
+package smartsplitwise
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/aanzolaavila/splitwise.go"
+	"github.com/aanzolaavila/splitwise.go/resources"
+)
+
+// Manager holds the connections of several Splitwise accounts by name and
+// runs queries across them. Every connection keeps its own token, cache and
+// reference data.
+type Manager struct {
+	mu       sync.RWMutex
+	accounts map[string]SwConnection
+}
+
+// AccountExpense is an expense seen from one or more accounts.
+type AccountExpense struct {
+	resources.Expense
+	Accounts []string
+}
+
+// AccountGroup is a group seen from one or more accounts.
+type AccountGroup struct {
+	resources.Group
+	Accounts []string
+}
+
+func NewManager() *Manager {
+	return &Manager{accounts: map[string]SwConnection{}}
+}
+
+// Add registers conn under name, replacing the account with the same name.
+func (m *Manager) Add(name string, conn SwConnection) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.accounts[name] = conn
+}
+
+// Open opens a connection with opts and registers it under name.
+func (m *Manager) Open(name string, opts ...Option) SwConnection {
+	conn := OpenWithOptions(opts...)
+	m.Add(name, conn)
+	return conn
+}
+
+func (m *Manager) Remove(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.accounts, name)
+}
+
+func (m *Manager) Account(name string) (SwConnection, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	conn, ok := m.accounts[name]
+	return conn, ok
+}
+
+// Names returns the names of the accounts, sorted.
+func (m *Manager) Names() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	names := make([]string, 0, len(m.accounts))
+	for name := range m.accounts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Each calls fn for every account in parallel and returns the errors by
+// account name. The map is empty when every call succeeded.
+func (m *Manager) Each(fn func(name string, conn SwConnection) error) map[string]error {
+	m.mu.RLock()
+	accounts := make(map[string]SwConnection, len(m.accounts))
+	for name, conn := range m.accounts {
+		accounts[name] = conn
+	}
+	m.mu.RUnlock()
+
+	var (
+		wg       sync.WaitGroup
+		errMu    sync.Mutex
+		failures = map[string]error{}
+	)
+	for name, conn := range accounts {
+		wg.Add(1)
+		go func(name string, conn SwConnection) {
+			defer wg.Done()
+			if err := fn(name, conn); err != nil {
+				errMu.Lock()
+				failures[name] = err
+				errMu.Unlock()
+			}
+		}(name, conn)
+	}
+	wg.Wait()
+
+	return failures
+}
+
+// GetExpenses runs the query on every account and merges the results. An
+// expense visible from several accounts is returned once, in the version
+// updated last, with all of them in Accounts. Expenses are sorted by date,
+// newest first. The accounts that cannot be read are left out and returned
+// with their errors.
+func (m *Manager) GetExpenses(params splitwise.ExpensesParams) ([]AccountExpense, map[string]error) {
+	var mu sync.Mutex
+	merged := map[resources.ExpenseID]*AccountExpense{}
+
+	failures := m.Each(func(name string, conn SwConnection) error {
+		// executors move the offset of params while paging
+		accountParams := splitwise.ExpensesParams{}
+		for k, v := range params {
+			accountParams[k] = v
+		}
+
+		// a partial read would look like expenses the account cannot see
+		expenses, err := Collect(conn.GetExpenses(accountParams))
+		if err != nil {
+			return err
+		}
+
+		for _, e := range expenses {
+			mu.Lock()
+			if seen, ok := merged[e.ID]; ok {
+				if e.UpdatedAt > seen.UpdatedAt {
+					seen.Expense = e
+				}
+				seen.Accounts = append(seen.Accounts, name)
+			} else {
+				merged[e.ID] = &AccountExpense{Expense: e, Accounts: []string{name}}
+			}
+			mu.Unlock()
+		}
+		return nil
+	})
+
+	expenses := make([]AccountExpense, 0, len(merged))
+	for _, e := range merged {
+		sort.Strings(e.Accounts)
+		expenses = append(expenses, *e)
+	}
+	sort.Slice(expenses, func(i, j int) bool {
+		if expenses[i].Date != expenses[j].Date {
+			return expenses[i].Date > expenses[j].Date
+		}
+		return expenses[i].ID > expenses[j].ID
+	})
+	return expenses, failures
+}
+
+// GetGroups merges the groups of every account by ID, sorted by ID. The
+// balances are those of the first account in name order. The accounts that
+// cannot be read are left out and returned with their errors.
+func (m *Manager) GetGroups() ([]AccountGroup, map[string]error) {
+	var mu sync.Mutex
+	byAccount := map[string][]resources.Group{}
+
+	failures := m.Each(func(name string, conn SwConnection) error {
+		groups, err := Collect(conn.GetGroups())
+		if err != nil {
+			return err
+		}
+
+		mu.Lock()
+		byAccount[name] = groups
+		mu.Unlock()
+		return nil
+	})
+
+	merged := map[resources.GroupID]*AccountGroup{}
+	for _, name := range m.Names() {
+		for _, g := range byAccount[name] {
+			if seen, ok := merged[g.ID]; ok {
+				seen.Accounts = append(seen.Accounts, name)
+			} else {
+				merged[g.ID] = &AccountGroup{Group: g, Accounts: []string{name}}
+			}
+		}
+	}
+
+	groups := make([]AccountGroup, 0, len(merged))
+	for _, g := range merged {
+		groups = append(groups, *g)
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].ID < groups[j].ID })
+	return groups, failures
+}
@@ -0,0 +1,127 @@
+package smartsplitwise
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aanzolaavila/splitwise.go"
+	"github.com/dcerbino-golib/smartsplitwise/splitwisetest"
+	"github.com/stretchr/testify/assert"
+)
+
+// newFamiliaServer seeds a server with the same Familia group and rent
+// expense, so both servers act as two sides of one account pair.
+func newFamiliaServer(t *testing.T, rent string, now time.Time) *splitwisetest.Server {
+	server := splitwisetest.NewServer()
+	server.Now = func() time.Time { return now }
+	t.Cleanup(server.Close)
+
+	me := server.CurrentUser()
+	other := server.AddUser("Ana", "B", "ana@example.com")
+	familia := server.AddGroup("Familia", other.ID)
+
+	_, err := server.AddExpense(int(familia.ID), rent, "100", now,
+		splitwisetest.Share{UserID: me.ID, Paid: "100", Owed: "50"},
+		splitwisetest.Share{UserID: other.ID, Paid: "0", Owed: "50"},
+	)
+	assert.NoError(t, err)
+	return server
+}
+
+func TestManagerMergesAccounts(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	diego := newFamiliaServer(t, "Rent", now)
+	ana := newFamiliaServer(t, "Rent March", now.Add(time.Hour))
+
+	_, err := diego.AddExpense(3, "Dinner", "10", now.Add(24*time.Hour),
+		splitwisetest.Share{UserID: diego.CurrentUser().ID, Paid: "10", Owed: "10"},
+	)
+	assert.NoError(t, err)
+
+	manager := NewManager()
+	manager.Open("diego", WithBaseURL(diego.URL, ""))
+	manager.Open("ana", WithBaseURL(ana.URL, ""))
+
+	assert.Equal(t, []string{"ana", "diego"}, manager.Names())
+
+	expenses, failures := manager.GetExpenses(splitwise.ExpensesParams{splitwise.ExpensesGroupId: 3})
+	assert.Empty(t, failures)
+	assert.Len(t, expenses, 2)
+	assert.Equal(t, "Dinner", expenses[0].Description)
+	assert.Equal(t, []string{"diego"}, expenses[0].Accounts)
+	assert.Equal(t, "Rent March", expenses[1].Description, "the version updated last should win")
+	assert.Equal(t, []string{"ana", "diego"}, expenses[1].Accounts)
+
+	groups, failures := manager.GetGroups()
+	assert.Empty(t, failures)
+	assert.Len(t, groups, 1)
+	assert.Equal(t, "Familia", groups[0].Name)
+	assert.Equal(t, []string{"ana", "diego"}, groups[0].Accounts)
+
+	failures = manager.Each(func(name string, conn SwConnection) error {
+		if name == "ana" {
+			return errors.New("unavailable")
+		}
+		return nil
+	})
+	assert.Len(t, failures, 1)
+	assert.EqualError(t, failures["ana"], "unavailable")
+
+	manager.Remove("ana")
+	_, ok := manager.Account("ana")
+	assert.False(t, ok)
+}
+
+func TestManagerReportsFailedAccounts(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	diego := newFamiliaServer(t, "Rent", now)
+	ana := newFamiliaServer(t, "Rent", now)
+	for _, path := range []string{"/get_expenses", "/get_groups"} {
+		fault := splitwisetest.ServerError()
+		fault.Path = path
+		ana.Inject(fault)
+	}
+
+	manager := NewManager()
+	manager.Open("diego", WithBaseURL(diego.URL, ""))
+	manager.Open("ana", WithBaseURL(ana.URL, ""))
+
+	expenses, failures := manager.GetExpenses(splitwise.ExpensesParams{})
+	assert.Len(t, expenses, 1)
+	assert.Equal(t, []string{"diego"}, expenses[0].Accounts)
+	assert.Len(t, failures, 1)
+	assert.Error(t, failures["ana"])
+
+	groups, failures := manager.GetGroups()
+	assert.Len(t, groups, 1)
+	assert.Equal(t, []string{"diego"}, groups[0].Accounts)
+	assert.Len(t, failures, 1)
+	assert.Error(t, failures["ana"])
+
+	_, err := manager.Reconcile(3)
+	assert.ErrorContains(t, err, "reconcile account ana", "a failed read is no visibility discrepancy")
+}
+
+func TestReferenceDataPerConnection(t *testing.T) {
+	server := splitwisetest.NewServer()
+	t.Cleanup(server.Close)
+
+	closed := splitwisetest.NewServer()
+	closed.Close()
+
+	manager := NewManager()
+	online := manager.Open("online", WithBaseURL(server.URL, ""))
+	offline := manager.Open("offline", WithBaseURL(closed.URL, ""))
+
+	currency, err := online.GetCurency("EUR")
+	assert.NoError(t, err)
+	assert.Equal(t, "€", currency.Unit)
+
+	_, err = offline.GetCurency("EUR")
+	assert.Error(t, err, "reference data should not be shared between connections")
+
+	category, err := online.GetMainCategory(2)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, category.Name)
+}
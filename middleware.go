@@ -0,0 +1,339 @@
+package smartsplitwise
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RetryPolicy retries idempotent requests failing with a network error, a
+// 429 or a 5xx, waiting a jittered exponential backoff between attempts or
+// the delay asked by Retry-After. Both are capped at MaxDelay.
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 4,
+	BaseDelay:   500 * time.Millisecond,
+	MaxDelay:    30 * time.Second,
+}
+
+func WithRetry(policy RetryPolicy) Option {
+	return func(o *connOptions) {
+		o.retry = &policy
+	}
+}
+
+// WithRateLimiter makes every request wait for a token of limiter. The same
+// limiter can be shared by several connections using the same account.
+func WithRateLimiter(limiter *RateLimiter) Option {
+	return func(o *connOptions) {
+		o.limiter = limiter
+	}
+}
+
+func WithCircuitBreaker(breaker *CircuitBreaker) Option {
+	return func(o *connOptions) {
+		o.breaker = breaker
+	}
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+func isRetryableStatus(status int) bool {
+	return status == http.StatusTooManyRequests || status >= 500
+}
+
+type retryClient struct {
+	policy RetryPolicy
+	logger *slog.Logger
+	next   HTTPClient
+}
+
+func (c retryClient) Do(req *http.Request) (*http.Response, error) {
+	if !isIdempotent(req.Method) || c.policy.MaxAttempts <= 1 {
+		return c.next.Do(req)
+	}
+
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+	}
+
+	for attempt := 1; ; attempt++ {
+		if body != nil {
+			req.Body = io.NopCloser(bytes.NewReader(body))
+		}
+
+		res, err := c.next.Do(req)
+		if attempt >= c.policy.MaxAttempts || req.Context().Err() != nil {
+			return res, err
+		}
+		if err == nil && !isRetryableStatus(res.StatusCode) {
+			return res, nil
+		}
+
+		delay := c.policy.Backoff(attempt)
+		if err == nil {
+			if after, ok := retryAfter(res.Header.Get("Retry-After")); ok {
+				delay = after
+				if c.policy.MaxDelay > 0 && delay > c.policy.MaxDelay {
+					delay = c.policy.MaxDelay
+				}
+			}
+			io.Copy(io.Discard, res.Body)
+			res.Body.Close()
+		}
+
+		attrs := []any{slog.String("path", req.URL.Path), slog.Int("attempt", attempt), slog.Duration("delay", delay)}
+		if err != nil {
+			attrs = append(attrs, slog.String("error", err.Error()))
+		} else {
+			attrs = append(attrs, slog.Int("status", res.StatusCode))
+		}
+		c.logger.Info("retrying request", attrs...)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Backoff returns a random delay up to BaseDelay doubled for every previous
+// attempt, capped at MaxDelay.
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	ceiling := p.BaseDelay << (attempt - 1)
+	if ceiling <= 0 || (p.MaxDelay > 0 && ceiling > p.MaxDelay) {
+		ceiling = p.MaxDelay
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(ceiling)) + 1)
+}
+
+// retryAfter parses both forms of the Retry-After header.
+func retryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if t, err := http.ParseTime(value); err == nil {
+		delay := time.Until(t)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+
+	return 0, false
+}
+
+// RateLimiter is a token bucket refilled at Rate tokens per second up to
+// Burst tokens.
+type RateLimiter struct {
+	rate  float64
+	burst float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Wait blocks until a token is available or ctx is done.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	for {
+		delay := l.reserve()
+		if delay == 0 {
+			return nil
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// reserve takes a token and returns zero, or returns how long to wait for
+// the next one.
+func (l *RateLimiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+
+	if l.tokens >= 1 {
+		l.tokens--
+		return 0
+	}
+	if l.rate <= 0 {
+		return time.Second
+	}
+	return time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+}
+
+type rateLimitClient struct {
+	limiter *RateLimiter
+	next    HTTPClient
+}
+
+func (c rateLimitClient) Do(req *http.Request) (*http.Response, error) {
+	if err := c.limiter.Wait(req.Context()); err != nil {
+		return nil, err
+	}
+	return c.next.Do(req)
+}
+
+var ErrCircuitOpen = errors.New("splitwise API unavailable: circuit breaker open")
+
+// CircuitBreaker opens after Threshold consecutive network errors or 5xx
+// responses and fails every request with ErrCircuitOpen for Cooldown. Then
+// a single trial request decides whether it closes again.
+type CircuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	failures int
+	openedAt time.Time
+	trial    bool
+}
+
+// NewCircuitBreaker returns a breaker opening after threshold failures; a
+// threshold below 1 opens it on the first one.
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	if threshold < 1 {
+		threshold = 1
+	}
+	return &CircuitBreaker{threshold: threshold, cooldown: cooldown}
+}
+
+// allow reports whether a request may go through and whether it is the
+// trial request of an open breaker, which only one request owns at a time.
+func (b *CircuitBreaker) allow() (ok bool, trial bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < b.threshold {
+		return true, false
+	}
+	if b.trial || time.Since(b.openedAt) < b.cooldown {
+		return false, false
+	}
+
+	b.trial = true
+	return true, true
+}
+
+// record counts the outcome of a request. While the breaker is open only
+// the trial request closes it; requests let through before it opened and
+// finishing later do not.
+func (b *CircuitBreaker) record(trial bool, failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if trial {
+		b.trial = false
+		if failed {
+			b.openedAt = time.Now()
+		} else {
+			b.failures = 0
+		}
+		return
+	}
+
+	if !failed {
+		if b.failures < b.threshold {
+			b.failures = 0
+		}
+		return
+	}
+
+	b.failures++
+	if b.failures == b.threshold {
+		b.openedAt = time.Now()
+	}
+}
+
+// release gives the trial up without an outcome, so the next request
+// tries again.
+func (b *CircuitBreaker) release(trial bool) {
+	if !trial {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+}
+
+type circuitBreakerClient struct {
+	breaker *CircuitBreaker
+	next    HTTPClient
+}
+
+func (c circuitBreakerClient) Do(req *http.Request) (*http.Response, error) {
+	ok, trial := c.breaker.allow()
+	if !ok {
+		return nil, ErrCircuitOpen
+	}
+
+	res, err := c.next.Do(req)
+	if err != nil && req.Context().Err() != nil {
+		// a cancelled caller says nothing about the API health
+		c.breaker.release(trial)
+		return res, err
+	}
+
+	c.breaker.record(trial, err != nil || res.StatusCode >= 500)
+	return res, err
+}
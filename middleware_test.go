@@ -0,0 +1,210 @@
+package smartsplitwise
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func getStatusSequenceDoFunc(calls *int32, statuses ...int) func(r *http.Request) (*http.Response, error) {
+	return func(r *http.Request) (*http.Response, error) {
+		n := int(atomic.AddInt32(calls, 1)) - 1
+		status := statuses[len(statuses)-1]
+		if n < len(statuses) {
+			status = statuses[n]
+		}
+
+		resposne := http.Response{}
+		resposne.Header = make(map[string][]string)
+		resposne.StatusCode = status
+		resposne.Body = io.NopCloser(strings.NewReader(testFriend))
+		if status == http.StatusTooManyRequests {
+			resposne.Header.Set("Retry-After", "0")
+		}
+		if status >= 400 {
+			resposne.Body = io.NopCloser(strings.NewReader(`{"errors": {"base": ["unavailable"]}}`))
+		}
+		return &resposne, nil
+	}
+}
+
+func TestRetryIdempotentRequests(t *testing.T) {
+	var calls int32
+	conn := OpenWithOptions(
+		WithHTTPClient(httpClientStub{DoFunc: getStatusSequenceDoFunc(&calls, 503, 429, 200)}),
+		WithRetry(RetryPolicy{MaxAttempts: 4, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}),
+		WithLogger(getTestLogger(t)),
+	)
+
+	friend, err := conn.GetFriend(12345)
+	assert.NoError(t, err)
+	assert.NotZero(t, friend.ID)
+	assert.Equal(t, int32(3), calls)
+
+	atomic.StoreInt32(&calls, 0)
+	conn = OpenWithOptions(
+		WithHTTPClient(httpClientStub{DoFunc: getStatusSequenceDoFunc(&calls, 500)}),
+		WithRetry(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}),
+	)
+	_, err = conn.GetFriend(12345)
+	assert.Error(t, err)
+	assert.Equal(t, int32(3), calls)
+
+	atomic.StoreInt32(&calls, 0)
+	err = conn.DeleteExpense(1)
+	assert.Error(t, err)
+	assert.Equal(t, int32(1), calls)
+}
+
+func TestRetryAfter(t *testing.T) {
+	delay, ok := retryAfter("3")
+	assert.True(t, ok)
+	assert.Equal(t, 3*time.Second, delay)
+
+	delay, ok = retryAfter(time.Now().Add(time.Minute).UTC().Format(http.TimeFormat))
+	assert.True(t, ok)
+	assert.InDelta(t, float64(time.Minute), float64(delay), float64(2*time.Second))
+
+	_, ok = retryAfter("soon")
+	assert.False(t, ok)
+
+	policy := RetryPolicy{BaseDelay: 10 * time.Millisecond, MaxDelay: 25 * time.Millisecond}
+	for attempt := 1; attempt < 70; attempt++ {
+		delay := policy.Backoff(attempt)
+		assert.True(t, delay > 0 && delay <= 25*time.Millisecond, delay)
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	limiter := NewRateLimiter(100, 2)
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, limiter.Wait(context.Background()))
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 25*time.Millisecond)
+
+	slow := NewRateLimiter(0.001, 1)
+	assert.NoError(t, slow.Wait(context.Background()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, slow.Wait(ctx), context.DeadlineExceeded)
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	var calls int32
+	breaker := NewCircuitBreaker(2, 20*time.Millisecond)
+	conn := OpenWithOptions(
+		WithHTTPClient(httpClientStub{DoFunc: getStatusSequenceDoFunc(&calls, 500, 500, 200)}),
+		WithCircuitBreaker(breaker),
+	)
+
+	_, err := conn.GetFriend(12345)
+	assert.Error(t, err)
+	_, err = conn.GetFriend(12345)
+	assert.Error(t, err)
+
+	_, err = conn.GetFriend(12345)
+	assert.True(t, errors.Is(err, ErrCircuitOpen), err)
+	assert.Equal(t, int32(2), calls)
+
+	time.Sleep(30 * time.Millisecond)
+	_, err = conn.GetFriend(12345)
+	assert.NoError(t, err)
+	_, err = conn.GetFriend(12345)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(4), calls)
+}
+
+func TestRetryAfterIsCappedByMaxDelay(t *testing.T) {
+	var calls int32
+	doFunc := func(r *http.Request) (*http.Response, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			resposne := http.Response{}
+			resposne.Header = make(map[string][]string)
+			resposne.Header.Set("Retry-After", "3600")
+			resposne.StatusCode = http.StatusTooManyRequests
+			resposne.Body = io.NopCloser(strings.NewReader(`{"errors": {"base": ["slow down"]}}`))
+			return &resposne, nil
+		}
+		return getStatusSequenceDoFunc(new(int32), 200)(r)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn := OpenWithOptions(
+		WithContext(ctx),
+		WithHTTPClient(httpClientStub{DoFunc: doFunc}),
+		WithRetry(RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}),
+		WithLogger(getTestLogger(t)),
+	)
+
+	start := time.Now()
+	_, err := conn.GetFriend(12345)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), calls)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestCircuitBreakerThresholdBelowOne(t *testing.T) {
+	var calls int32
+	conn := OpenWithOptions(
+		WithHTTPClient(httpClientStub{DoFunc: getStatusSequenceDoFunc(&calls, 500)}),
+		WithCircuitBreaker(NewCircuitBreaker(0, time.Minute)),
+	)
+
+	_, err := conn.GetFriend(12345)
+	assert.Error(t, err)
+	assert.False(t, errors.Is(err, ErrCircuitOpen))
+
+	_, err = conn.GetFriend(12345)
+	assert.True(t, errors.Is(err, ErrCircuitOpen), err)
+	assert.Equal(t, int32(1), calls)
+}
+
+func TestCircuitBreakerSingleTrial(t *testing.T) {
+	breaker := NewCircuitBreaker(2, 10*time.Millisecond)
+
+	// both requests were let through before the breaker opened
+	first, _ := breaker.allow()
+	second, _ := breaker.allow()
+	assert.True(t, first && second)
+	breaker.record(false, true)
+	breaker.record(false, true)
+
+	ok, _ := breaker.allow()
+	assert.False(t, ok)
+
+	time.Sleep(20 * time.Millisecond)
+	ok, trial := breaker.allow()
+	assert.True(t, ok)
+	assert.True(t, trial)
+
+	ok, _ = breaker.allow()
+	assert.False(t, ok, "only one request owns the trial")
+
+	// a late success of a request sent before the breaker opened does not
+	// close it nor free the trial
+	breaker.record(false, false)
+	ok, _ = breaker.allow()
+	assert.False(t, ok)
+
+	// a cancelled trial lets the next request try
+	breaker.release(trial)
+	ok, trial = breaker.allow()
+	assert.True(t, ok)
+	assert.True(t, trial)
+
+	breaker.record(trial, false)
+	ok, trial = breaker.allow()
+	assert.True(t, ok)
+	assert.False(t, trial)
+}
@@ -0,0 +1,494 @@
+package smartsplitwise
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aanzolaavila/splitwise.go"
+	"github.com/aanzolaavila/splitwise.go/resources"
+	"github.com/dcerbino-golib/smartsplitwise/backup"
+)
+
+// MigrationSource is the expenses to migrate and the users they involve.
+type MigrationSource struct {
+	// Name tells the source apart in the checkpoint, such as "group 3".
+	Name     string
+	Users    []resources.User
+	Expenses []resources.Expense
+}
+
+// ArchiveSource takes the expenses of a group of a backup archive, the
+// deleted ones excluded. Group 0 takes the expenses outside of groups.
+func ArchiveSource(a *backup.Archive, groupID int) MigrationSource {
+	users := newUserIndex()
+	users.add(a.CurrentUser)
+	for _, g := range a.Groups {
+		if int(g.ID) == groupID {
+			users.add(g.Members...)
+		}
+	}
+	for _, f := range a.Friends {
+		users.add(resources.User{ID: resources.UserID(f.ID), FirstName: f.FirstName, LastName: f.LastName, Email: f.Email})
+	}
+
+	source := newMigrationSource(users, a.Expenses, groupID)
+	source.Name = fmt.Sprintf("archive of user %d at %s, group %d", a.CurrentUser.ID, a.CreatedAt.UTC().Format(time.RFC3339), groupID)
+	return source
+}
+
+// GroupSource reads the expenses of a live group, the deleted ones excluded.
+func GroupSource(conn SwConnection, groupID int) (MigrationSource, error) {
+	group, err := conn.GetGroup(groupID)
+	if err != nil {
+		return MigrationSource{}, fmt.Errorf("migration source group %d: %w", groupID, err)
+	}
+
+	params, err := NewExpensesQuery().Group(groupID).Params()
+	if err != nil {
+		return MigrationSource{}, err
+	}
+
+	expenses, err := Collect(conn.GetExpenses(params))
+	if err != nil {
+		return MigrationSource{}, fmt.Errorf("migration source group %d: %w", groupID, err)
+	}
+
+	users := newUserIndex()
+	users.add(group.Members...)
+	source := newMigrationSource(users, expenses, groupID)
+	source.Name = fmt.Sprintf("group %d", groupID)
+	return source, nil
+}
+
+func newMigrationSource(users *userIndex, expenses []resources.Expense, groupID int) MigrationSource {
+	source := MigrationSource{}
+	for _, e := range expenses {
+		if int(e.GroupId) != groupID || e.DeletedAt != "" {
+			continue
+		}
+		for _, u := range e.Users {
+			user := u.User
+			user.ID = resources.UserID(u.UserId)
+			users.add(user)
+		}
+		source.Expenses = append(source.Expenses, e)
+	}
+
+	// oldest first, so that the target lists them like the source
+	sort.Slice(source.Expenses, func(i, j int) bool {
+		ei, ej := source.Expenses[i], source.Expenses[j]
+		if ei.Date != ej.Date {
+			return ei.Date < ej.Date
+		}
+		return ei.ID < ej.ID
+	})
+
+	source.Users = users.list
+	return source
+}
+
+// userIndex keeps the first record of each user, completing its email from
+// the later ones.
+type userIndex struct {
+	list []resources.User
+	byID map[resources.UserID]int
+}
+
+func newUserIndex() *userIndex {
+	return &userIndex{byID: map[resources.UserID]int{}}
+}
+
+func (idx *userIndex) add(users ...resources.User) {
+	for _, u := range users {
+		if u.ID == 0 {
+			continue
+		}
+		i, ok := idx.byID[u.ID]
+		if !ok {
+			idx.byID[u.ID] = len(idx.list)
+			idx.list = append(idx.list, u)
+			continue
+		}
+		if idx.list[i].Email == "" {
+			idx.list[i].Email = u.Email
+		}
+	}
+}
+
+// MigrationCheckpoint is the persisted progress of a Migration from Source
+// to Group. Pending is the source expense being created when the checkpoint
+// was saved, whose copy may or may not exist in the target.
+type MigrationCheckpoint struct {
+	Source   string                                      `json:"source"`
+	Group    int                                         `json:"group"`
+	Migrated map[resources.ExpenseID]resources.ExpenseID `json:"migrated"`
+	Pending  resources.ExpenseID                         `json:"pending,omitempty"`
+}
+
+// ErrCheckpointMismatch is returned resuming from the checkpoint of a
+// migration with another source or target group.
+var ErrCheckpointMismatch = errors.New("migration checkpoint belongs to another migration")
+
+type CheckpointStore interface {
+	Load() (MigrationCheckpoint, error)
+	Save(checkpoint MigrationCheckpoint) error
+}
+
+type FileCheckpointStore struct {
+	Path string
+}
+
+func (s FileCheckpointStore) Load() (MigrationCheckpoint, error) {
+	checkpoint := MigrationCheckpoint{}
+
+	content, err := os.ReadFile(s.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return checkpoint, nil
+	}
+	if err != nil {
+		return checkpoint, err
+	}
+
+	err = json.Unmarshal(content, &checkpoint)
+	return checkpoint, err
+}
+
+func (s FileCheckpointStore) Save(checkpoint MigrationCheckpoint) error {
+	content, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.Path), filepath.Base(s.Path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.Path)
+}
+
+// LoadUserMap reads a JSON object mapping source user IDs to target ones,
+// such as {"21702157": 4519231}.
+func LoadUserMap(path string) (map[resources.UserID]resources.UserID, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	users := map[resources.UserID]resources.UserID{}
+	if err := json.Unmarshal(content, &users); err != nil {
+		return nil, fmt.Errorf("user map %s: %w", path, err)
+	}
+	return users, nil
+}
+
+// Migration recreates expenses in a group of the target account, keeping
+// their dates, categories, currencies and shares.
+type Migration struct {
+	// Users maps source user IDs to target ones. The users missing are
+	// matched by email with the members of the target group.
+	Users map[resources.UserID]resources.UserID
+	// Store, when set, keeps the progress so that an interrupted run
+	// resumes without duplicating expenses.
+	Store CheckpointStore
+
+	target  SwConnection
+	groupID int
+}
+
+func NewMigration(target SwConnection, groupID int) *Migration {
+	return &Migration{target: target, groupID: groupID}
+}
+
+// MigratedExpense is a source expense and its copy in the target.
+type MigratedExpense struct {
+	SourceID resources.ExpenseID `json:"source_id"`
+	TargetID resources.ExpenseID `json:"target_id"`
+	// Resumed is set when the copy was created by an interrupted run.
+	Resumed bool `json:"resumed,omitempty"`
+}
+
+type MigrationReport struct {
+	GroupID  int                                   `json:"group_id"`
+	Users    map[resources.UserID]resources.UserID `json:"users"`
+	Migrated []MigratedExpense                     `json:"migrated"`
+	// Skipped counts the expenses migrated by previous runs.
+	Skipped int `json:"skipped"`
+}
+
+// MapUsers returns the target user of each source user of the expenses.
+// It fails listing every user it cannot map, before anything is written.
+func (m *Migration) MapUsers(source MigrationSource) (map[resources.UserID]resources.UserID, error) {
+	group, err := m.target.GetGroup(m.groupID)
+	if err != nil {
+		return nil, fmt.Errorf("migration target group %d: %w", m.groupID, err)
+	}
+
+	members := map[resources.UserID]bool{}
+	byEmail := map[string]resources.UserID{}
+	for _, member := range group.Members {
+		members[member.ID] = true
+		if member.Email != "" {
+			byEmail[strings.ToLower(member.Email)] = member.ID
+		}
+	}
+
+	emails := map[resources.UserID]string{}
+	for _, u := range source.Users {
+		emails[u.ID] = u.Email
+	}
+
+	involved := map[resources.UserID]bool{}
+	for _, e := range source.Expenses {
+		for _, u := range e.Users {
+			involved[resources.UserID(u.UserId)] = true
+		}
+	}
+	ids := make([]resources.UserID, 0, len(involved))
+	for id := range involved {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	mapping := map[resources.UserID]resources.UserID{}
+	var errs []error
+	for _, id := range ids {
+		target, ok := m.Users[id]
+		if !ok {
+			target, ok = byEmail[strings.ToLower(emails[id])]
+		}
+		switch {
+		case !ok:
+			errs = append(errs, fmt.Errorf("%w: source user %d has no mapping nor a member of group %d with the same email", splitwise.ErrInvalidParameter, id, m.groupID))
+		case !members[target]:
+			errs = append(errs, fmt.Errorf("%w: source user %d maps to user %d, who is not a member of group %d", splitwise.ErrInvalidParameter, id, target, m.groupID))
+		default:
+			mapping[id] = target
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return mapping, nil
+}
+
+// Run migrates the expenses of source not migrated yet according to the
+// checkpoint, saving it after each one.
+func (m *Migration) Run(source MigrationSource) (MigrationReport, error) {
+	report := MigrationReport{GroupID: m.groupID, Migrated: []MigratedExpense{}}
+
+	mapping, err := m.MapUsers(source)
+	if err != nil {
+		return report, err
+	}
+	report.Users = mapping
+
+	checkpoint := MigrationCheckpoint{}
+	if m.Store != nil {
+		if checkpoint, err = m.Store.Load(); err != nil {
+			return report, fmt.Errorf("load migration checkpoint: %w", err)
+		}
+	}
+	if len(checkpoint.Migrated) == 0 && checkpoint.Pending == 0 {
+		checkpoint.Source, checkpoint.Group = source.Name, m.groupID
+	}
+	if checkpoint.Source != source.Name || checkpoint.Group != m.groupID {
+		return report, fmt.Errorf("%w: it migrates %q to group %d, not %q to group %d",
+			ErrCheckpointMismatch, checkpoint.Source, checkpoint.Group, source.Name, m.groupID)
+	}
+	if checkpoint.Migrated == nil {
+		checkpoint.Migrated = map[resources.ExpenseID]resources.ExpenseID{}
+	}
+
+	for _, e := range source.Expenses {
+		if _, ok := checkpoint.Migrated[e.ID]; ok {
+			report.Skipped++
+			continue
+		}
+
+		migrated := MigratedExpense{SourceID: e.ID}
+		if checkpoint.Pending == e.ID {
+			migrated.TargetID, err = m.findCopy(e, checkpoint)
+			if err != nil {
+				return report, err
+			}
+			migrated.Resumed = migrated.TargetID != 0
+		}
+
+		if migrated.TargetID == 0 {
+			checkpoint.Pending = e.ID
+			if err := m.save(checkpoint); err != nil {
+				return report, err
+			}
+
+			migrated.TargetID, err = m.create(e, mapping)
+			if err != nil {
+				return report, fmt.Errorf("migrate expense %d: %w", e.ID, err)
+			}
+		}
+
+		checkpoint.Migrated[e.ID] = migrated.TargetID
+		checkpoint.Pending = 0
+		if err := m.save(checkpoint); err != nil {
+			return report, err
+		}
+		report.Migrated = append(report.Migrated, migrated)
+	}
+
+	return report, nil
+}
+
+func (m *Migration) save(checkpoint MigrationCheckpoint) error {
+	if m.Store == nil {
+		return nil
+	}
+	if err := m.Store.Save(checkpoint); err != nil {
+		return fmt.Errorf("save migration checkpoint: %w", err)
+	}
+	return nil
+}
+
+func (m *Migration) create(e resources.Expense, mapping map[resources.UserID]resources.UserID) (resources.ExpenseID, error) {
+	cost, err := ExpenseCost(e)
+	if err != nil {
+		return 0, err
+	}
+	shares, err := ExpenseShares(e)
+	if err != nil {
+		return 0, err
+	}
+
+	// two source users mapped to the same target user add up their shares
+	type share struct{ paid, owed Money }
+	merged := map[resources.UserID]*share{}
+	var order []resources.UserID
+	for _, s := range shares {
+		target := mapping[resources.UserID(s.UserID)]
+		if merged[target] == nil {
+			merged[target] = &share{}
+			order = append(order, target)
+		}
+		if merged[target].paid, err = merged[target].paid.Add(s.Paid); err != nil {
+			return 0, err
+		}
+		if merged[target].owed, err = merged[target].owed.Add(s.Owed); err != nil {
+			return 0, err
+		}
+	}
+
+	users := make([]splitwise.ExpenseUser, 0, len(order))
+	for _, id := range order {
+		paid, err := apiAmount(merged[id].paid)
+		if err != nil {
+			return 0, err
+		}
+		owed, err := apiAmount(merged[id].owed)
+		if err != nil {
+			return 0, err
+		}
+		users = append(users, splitwise.ExpenseUser{Id: id, PaidShare: paid, OwedShare: owed})
+	}
+	total, err := apiAmount(cost)
+	if err != nil {
+		return 0, err
+	}
+
+	params := splitwise.CreateExpenseParams{
+		splitwise.CreateExpenseDate:         e.Date,
+		splitwise.CreateExpenseCurrencyCode: e.CurrencyCode,
+		"payment":                           e.Payment,
+	}
+	if category := categoryID(e); category != 0 {
+		params[splitwise.CreateExpenseCategoryId] = category
+	}
+	if e.Details != "" {
+		params[splitwise.CreateExpenseDetails] = e.Details
+	}
+
+	created, err := m.target.CreateExpenseByShares(total, e.Description, m.groupID, params, users)
+	if err != nil {
+		return 0, err
+	}
+	if len(created) == 0 {
+		return 0, errors.New("no expense created")
+	}
+	return created[0].ID, nil
+}
+
+// apiAmount converts an amount for the API client, which sends floats with
+// two decimals, failing rather than rounding the amounts of currencies such
+// as KWD.
+func apiAmount(m Money) (float64, error) {
+	if !m.roundTo(2).Equal(m) {
+		return 0, fmt.Errorf("%w: %s cannot be sent with two decimals", splitwise.ErrInvalidParameter, m)
+	}
+	return m.Float64(), nil
+}
+
+func categoryID(e resources.Expense) int {
+	if e.Category.ID != 0 {
+		return int(e.Category.ID)
+	}
+	return int(e.CategoryId)
+}
+
+// findCopy looks in the target group for the copy of e an interrupted run
+// may have created: same description, date and cost, and not the copy of
+// another expense.
+func (m *Migration) findCopy(e resources.Expense, checkpoint MigrationCheckpoint) (resources.ExpenseID, error) {
+	params, err := NewExpensesQuery().Group(m.groupID).Params()
+	if err != nil {
+		return 0, err
+	}
+
+	claimed := map[resources.ExpenseID]bool{}
+	for _, id := range checkpoint.Migrated {
+		claimed[id] = true
+	}
+
+	candidates, err := Collect(m.target.GetExpenses(params))
+	if err != nil {
+		return 0, fmt.Errorf("look for the copy of expense %d: %w", e.ID, err)
+	}
+
+	for _, candidate := range candidates {
+		if candidate.DeletedAt != "" || claimed[candidate.ID] || candidate.Description != e.Description {
+			continue
+		}
+		if !sameInstant(candidate.Date, e.Date) {
+			continue
+		}
+		a, errA := ExpenseCost(candidate)
+		b, errB := ExpenseCost(e)
+		if errA != nil || errB != nil || !a.Equal(b) {
+			continue
+		}
+		return candidate.ID, nil
+	}
+	return 0, nil
+}
+
+func sameInstant(a string, b string) bool {
+	ta, errA := time.Parse(time.RFC3339, a)
+	tb, errB := time.Parse(time.RFC3339, b)
+	if errA != nil || errB != nil {
+		return a == b
+	}
+	return ta.Equal(tb)
+}
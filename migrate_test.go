@@ -0,0 +1,303 @@
+package smartsplitwise
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aanzolaavila/splitwise.go"
+	"github.com/aanzolaavila/splitwise.go/resources"
+	"github.com/dcerbino-golib/smartsplitwise/backup"
+	"github.com/dcerbino-golib/smartsplitwise/splitwisetest"
+	"github.com/stretchr/testify/assert"
+)
+
+// newFamiliaArchive backs up the Familia server with a second, older
+// expense in the Food category.
+func newFamiliaArchive(t *testing.T, now time.Time) *backup.Archive {
+	server := newFamiliaServer(t, "Rent", now)
+	_, err := server.AddExpense(3, "Groceries", "30.50", now.AddDate(0, 0, -7),
+		splitwisetest.Share{UserID: 2, Paid: "30.50", Owed: "15.25"},
+		splitwisetest.Share{UserID: 1, Paid: "0", Owed: "15.25"},
+	)
+	assert.NoError(t, err)
+
+	var buf bytes.Buffer
+	_, err = Backup(OpenWithOptions(WithBaseURL(server.URL, "")), &buf, false)
+	assert.NoError(t, err)
+
+	archive, err := backup.Load(&buf)
+	assert.NoError(t, err)
+	for i := range archive.Expenses {
+		if archive.Expenses[i].Description == "Groceries" {
+			archive.Expenses[i].Category.ID = 12
+		}
+	}
+	return archive
+}
+
+// newMigrationTarget has Zoe as user 2 and Ana as user 3, so the IDs differ
+// from the source, and returns the new group with the given members.
+func newMigrationTarget(t *testing.T, members ...string) (*splitwisetest.Server, SwConnection, int) {
+	server := splitwisetest.NewServer()
+	t.Cleanup(server.Close)
+
+	users := map[string]resources.UserID{
+		"zoe": server.AddUser("Zoe", "Z", "zoe@example.com").ID,
+		"ana": server.AddUser("Ana", "B", "ANA@example.com").ID,
+	}
+	var ids []resources.UserID
+	for _, m := range members {
+		ids = append(ids, users[m])
+	}
+	group := server.AddGroup("Familia 2024", ids...)
+
+	return server, OpenWithOptions(WithBaseURL(server.URL, "")), int(group.ID)
+}
+
+func TestMigrateArchiveMatchingEmails(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	source := ArchiveSource(newFamiliaArchive(t, now), 3)
+	assert.Len(t, source.Expenses, 2)
+	assert.Regexp(t, `^archive of user 1 at .+, group 3$`, source.Name)
+
+	server, conn, groupID := newMigrationTarget(t, "zoe", "ana")
+
+	migration := NewMigration(conn, groupID)
+	migration.Store = FileCheckpointStore{Path: filepath.Join(t.TempDir(), "checkpoint.json")}
+
+	report, err := migration.Run(source)
+	assert.NoError(t, err)
+	assert.Equal(t, map[resources.UserID]resources.UserID{1: 1, 2: 3}, report.Users)
+	assert.Equal(t, []MigratedExpense{{SourceID: 5, TargetID: 5}, {SourceID: 4, TargetID: 7}}, report.Migrated)
+
+	groceries, _ := server.Expense(5)
+	assert.Equal(t, "Groceries", groceries.Description)
+	assert.Equal(t, "2024-02-23T12:00:00Z", groceries.Date)
+	assert.Equal(t, resources.CategoryID(12), groceries.Category.ID)
+	assert.Equal(t, "30.50", groceries.Cost)
+	assert.Equal(t, uint64(3), groceries.Users[0].UserId)
+	assert.Equal(t, "30.50", groceries.Users[0].PaidShare)
+
+	verification, err := VerifyGroup(conn, groupID)
+	assert.NoError(t, err)
+	assert.Equal(t, []MemberNet{
+		{UserID: 1, CurrencyCode: "USD", Amount: "34.75"},
+		{UserID: 3, CurrencyCode: "USD", Amount: "-34.75"},
+	}, verification.Computed)
+
+	// a second run finds everything migrated
+	report, err = migration.Run(source)
+	assert.NoError(t, err)
+	assert.Empty(t, report.Migrated)
+	assert.Equal(t, 2, report.Skipped)
+	expenses, err := Collect(conn.GetExpenses(splitwise.ExpensesParams{}))
+	assert.NoError(t, err)
+	assert.Len(t, expenses, 2)
+}
+
+func TestMigrateUserMapping(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	source := ArchiveSource(newFamiliaArchive(t, now), 3)
+
+	server, conn, groupID := newMigrationTarget(t, "zoe")
+
+	migration := NewMigration(conn, groupID)
+	_, err := migration.Run(source)
+	assert.ErrorIs(t, err, splitwise.ErrInvalidParameter)
+	assert.ErrorContains(t, err, "source user 2 has no mapping")
+	for _, r := range server.Requests() {
+		assert.False(t, strings.HasPrefix(r, "POST /create_expense"), "nothing should be written: %s", r)
+	}
+
+	migration.Users = map[resources.UserID]resources.UserID{2: 3}
+	_, err = migration.Run(source)
+	assert.ErrorContains(t, err, "source user 2 maps to user 3, who is not a member of group 4")
+
+	path := filepath.Join(t.TempDir(), "users.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`{"2": 2}`), 0o600))
+	migration.Users, err = LoadUserMap(path)
+	assert.NoError(t, err)
+
+	report, err := migration.Run(source)
+	assert.NoError(t, err)
+	assert.Len(t, report.Migrated, 2)
+	assert.Equal(t, resources.UserID(2), report.Users[2])
+}
+
+type memoryCheckpoints struct {
+	checkpoint MigrationCheckpoint
+	saved      []MigrationCheckpoint
+}
+
+func (s *memoryCheckpoints) Load() (MigrationCheckpoint, error) {
+	return s.checkpoint, nil
+}
+
+func (s *memoryCheckpoints) Save(checkpoint MigrationCheckpoint) error {
+	s.checkpoint = checkpoint
+	s.saved = append(s.saved, checkpoint)
+	return nil
+}
+
+func TestMigrateResumesPendingExpense(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	source := ArchiveSource(newFamiliaArchive(t, now), 3)
+
+	server, conn, groupID := newMigrationTarget(t, "ana")
+
+	// the previous run created the groceries and stopped before saving
+	copied, err := server.AddExpense(groupID, "Groceries", "30.50", now.AddDate(0, 0, -7),
+		splitwisetest.Share{UserID: 3, Paid: "30.50", Owed: "15.25"},
+		splitwisetest.Share{UserID: 1, Paid: "0", Owed: "15.25"},
+	)
+	assert.NoError(t, err)
+
+	store := &memoryCheckpoints{checkpoint: MigrationCheckpoint{Source: source.Name, Group: groupID, Pending: 5}}
+	migration := NewMigration(conn, groupID)
+	migration.Store = store
+
+	report, err := migration.Run(source)
+	assert.NoError(t, err)
+	assert.Equal(t, MigratedExpense{SourceID: 5, TargetID: copied.ID, Resumed: true}, report.Migrated[0])
+	assert.False(t, report.Migrated[1].Resumed)
+	expenses, err := Collect(conn.GetExpenses(splitwise.ExpensesParams{}))
+	assert.NoError(t, err)
+	assert.Len(t, expenses, 2)
+
+	// the rent is marked pending before it is created
+	assert.Equal(t, resources.ExpenseID(4), store.saved[1].Pending)
+	assert.Equal(t, MigrationCheckpoint{
+		Source:   source.Name,
+		Group:    groupID,
+		Migrated: map[resources.ExpenseID]resources.ExpenseID{5: copied.ID, 4: report.Migrated[1].TargetID},
+	}, store.checkpoint)
+}
+
+func TestMigrateRefusesAnotherCheckpoint(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	source := ArchiveSource(newFamiliaArchive(t, now), 3)
+	server, conn, groupID := newMigrationTarget(t, "ana")
+
+	for _, checkpoint := range []MigrationCheckpoint{
+		{Source: "group 3", Group: groupID, Pending: 5},
+		{Source: source.Name, Group: groupID + 1, Migrated: map[resources.ExpenseID]resources.ExpenseID{5: 9}},
+		{Migrated: map[resources.ExpenseID]resources.ExpenseID{5: 9}},
+	} {
+		migration := NewMigration(conn, groupID)
+		migration.Store = &memoryCheckpoints{checkpoint: checkpoint}
+
+		_, err := migration.Run(source)
+		assert.ErrorIs(t, err, ErrCheckpointMismatch, checkpoint.Source)
+	}
+	for _, r := range server.Requests() {
+		assert.False(t, strings.HasPrefix(r, "POST /create_expense"), "nothing should be written: %s", r)
+	}
+}
+
+func TestMigrateFailsOnUnreadableExpenses(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	server := newFamiliaServer(t, "Rent", now)
+	conn := OpenWithOptions(WithBaseURL(server.URL, ""))
+
+	fault := splitwisetest.ServerError()
+	fault.Path = "/get_expenses"
+	server.Inject(fault)
+	_, err := GroupSource(conn, 3)
+	assert.ErrorContains(t, err, "migration source group 3")
+
+	// the copy of the pending expense cannot be looked for
+	source := ArchiveSource(newFamiliaArchive(t, now), 3)
+	target, targetConn, groupID := newMigrationTarget(t, "ana")
+	target.Inject(fault)
+
+	migration := NewMigration(targetConn, groupID)
+	migration.Store = &memoryCheckpoints{checkpoint: MigrationCheckpoint{Source: source.Name, Group: groupID, Pending: 5}}
+	report, err := migration.Run(source)
+	assert.ErrorContains(t, err, "look for the copy of expense 5")
+	assert.Empty(t, report.Migrated)
+	for _, r := range target.Requests() {
+		assert.False(t, strings.HasPrefix(r, "POST /create_expense"), "nothing should be written: %s", r)
+	}
+}
+
+func TestMigrateLiveGroup(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	server := newFamiliaServer(t, "Rent", now)
+	conn := OpenWithOptions(WithBaseURL(server.URL, ""))
+	restarted := server.AddGroup("Familia again", 2)
+
+	_, err := server.AddExpense(3, "Wrong", "10", now, splitwisetest.Share{UserID: 1, Paid: "10", Owed: "10"})
+	assert.NoError(t, err)
+	assert.NoError(t, conn.DeleteExpense(6))
+
+	source, err := GroupSource(conn, 3)
+	assert.NoError(t, err)
+	assert.Len(t, source.Expenses, 1, "deleted expenses are not migrated")
+	assert.Equal(t, "group 3", source.Name)
+
+	report, err := NewMigration(conn, int(restarted.ID)).Run(source)
+	assert.NoError(t, err)
+	assert.Len(t, report.Migrated, 1)
+
+	old, err := conn.GetGroup(3)
+	assert.NoError(t, err)
+	group, err := conn.GetGroup(int(restarted.ID))
+	assert.NoError(t, err)
+	assert.Equal(t, old.OriginalDebts, group.OriginalDebts)
+
+	_, err = GroupSource(conn, 99)
+	assert.ErrorContains(t, err, "migration source group 99")
+}
+
+func TestMigrateKeepsPayments(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	server := newFamiliaServer(t, "Rent", now)
+	_, err := server.AddExpense(3, "Payment", "50", now,
+		splitwisetest.Share{UserID: 2, Paid: "50", Owed: "0"},
+		splitwisetest.Share{UserID: 1, Paid: "0", Owed: "50"},
+	)
+	assert.NoError(t, err)
+
+	var buf bytes.Buffer
+	_, err = Backup(OpenWithOptions(WithBaseURL(server.URL, "")), &buf, false)
+	assert.NoError(t, err)
+	archive, err := backup.Load(&buf)
+	assert.NoError(t, err)
+	for i := range archive.Expenses {
+		archive.Expenses[i].Payment = archive.Expenses[i].Description == "Payment"
+	}
+
+	target, conn, groupID := newMigrationTarget(t, "ana")
+	report, err := NewMigration(conn, groupID).Run(ArchiveSource(archive, 3))
+	assert.NoError(t, err)
+	assert.Len(t, report.Migrated, 2)
+
+	for _, m := range report.Migrated {
+		copied, ok := target.Expense(int(m.TargetID))
+		assert.True(t, ok)
+		assert.Equal(t, copied.Description == "Payment", copied.Payment, copied.Description)
+	}
+}
+
+func TestMigrateRefusesAmountsTheClientWouldRound(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	archive := newFamiliaArchive(t, now)
+	for i := range archive.Expenses {
+		if archive.Expenses[i].Description == "Groceries" {
+			archive.Expenses[i].CurrencyCode = "KWD"
+			archive.Expenses[i].Cost = "30.505"
+		}
+	}
+
+	server, conn, groupID := newMigrationTarget(t, "ana")
+	_, err := NewMigration(conn, groupID).Run(ArchiveSource(archive, 3))
+	assert.ErrorIs(t, err, splitwise.ErrInvalidParameter)
+	assert.ErrorContains(t, err, "30.505 KWD cannot be sent with two decimals")
+	for _, r := range server.Requests() {
+		assert.False(t, strings.HasPrefix(r, "POST /create_expense"), "nothing should be written: %s", r)
+	}
+}
@@ -0,0 +1,440 @@
+package smartsplitwise
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"unicode"
+
+	"github.com/aanzolaavila/splitwise.go/resources"
+)
+
+// Money is an exact decimal amount in a currency. Values are immutable and
+// the zero value is zero in no currency, which adopts the currency of the
+// first amount added to it.
+type Money struct {
+	// the amount is units / 10^scale
+	units    *big.Int
+	scale    int
+	currency string
+}
+
+type CurrencyMismatchError struct {
+	A string
+	B string
+}
+
+func (e *CurrencyMismatchError) Error() string {
+	return fmt.Sprintf("currency mismatch: %s and %s", e.A, e.B)
+}
+
+// minorUnits are the ISO 4217 decimals of the currencies not using 2.
+var minorUnits = map[string]int{
+	"BHD": 3, "BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "IQD": 3, "ISK": 0,
+	"JOD": 3, "JPY": 0, "KMF": 0, "KRW": 0, "KWD": 3, "LYD": 3, "OMR": 3,
+	"PYG": 0, "RWF": 0, "TND": 3, "UGX": 0, "UYI": 0, "VND": 0, "VUV": 0,
+	"XAF": 0, "XOF": 0, "XPF": 0,
+}
+
+// MinorUnits returns the decimals amounts in currency are rounded to. It
+// only reads the CurrencyCode, looked up in a built-in ISO 4217 table, as
+// resources.Currency has no minor units field.
+func MinorUnits(currency resources.Currency) int {
+	return minorUnitsOf(currency.CurrencyCode)
+}
+
+func minorUnitsOf(code string) int {
+	if units, ok := minorUnits[strings.ToUpper(code)]; ok {
+		return units
+	}
+	return 2
+}
+
+// ParseMoney parses a plain decimal amount as sent by the API, such as
+// "1024.25" or "-3".
+func ParseMoney(amount string, currency string) (Money, error) {
+	s := strings.TrimSpace(amount)
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "-"), "+")
+
+	whole, fraction, _ := strings.Cut(s, ".")
+	if whole == "" && fraction == "" || !isDigits(whole) || !isDigits(fraction) {
+		return Money{}, fmt.Errorf("invalid amount %q", amount)
+	}
+
+	units, _ := new(big.Int).SetString("0"+whole+fraction, 10)
+	if negative {
+		units.Neg(units)
+	}
+	return Money{units: units, scale: len(fraction), currency: currency}, nil
+}
+
+// NewMoney returns units minor units of currency, such as cents.
+func NewMoney(units int64, currency string) Money {
+	return Money{units: big.NewInt(units), scale: minorUnitsOf(currency), currency: currency}
+}
+
+func (m Money) Currency() string {
+	return m.currency
+}
+
+func (m Money) value() *big.Int {
+	if m.units == nil {
+		return new(big.Int)
+	}
+	return m.units
+}
+
+// rescale returns the units of m at a scale not lower than its own.
+func (m Money) rescale(scale int) *big.Int {
+	factor := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale-m.scale)), nil)
+	return factor.Mul(factor, m.value())
+}
+
+func (m Money) sameCurrency(other Money) (string, error) {
+	switch {
+	case m.currency == other.currency:
+		return m.currency, nil
+	case m.currency == "" && m.IsZero():
+		return other.currency, nil
+	case other.currency == "" && other.IsZero():
+		return m.currency, nil
+	default:
+		return "", &CurrencyMismatchError{A: m.currency, B: other.currency}
+	}
+}
+
+func (m Money) Add(other Money) (Money, error) {
+	currency, err := m.sameCurrency(other)
+	if err != nil {
+		return Money{}, err
+	}
+
+	scale := max(m.scale, other.scale)
+	units := m.rescale(scale)
+	units.Add(units, other.rescale(scale))
+	return Money{units: units, scale: scale, currency: currency}, nil
+}
+
+func (m Money) Sub(other Money) (Money, error) {
+	return m.Add(other.Neg())
+}
+
+func (m Money) Mul(factor int64) Money {
+	return Money{units: new(big.Int).Mul(m.value(), big.NewInt(factor)), scale: m.scale, currency: m.currency}
+}
+
+func (m Money) Neg() Money {
+	return Money{units: new(big.Int).Neg(m.value()), scale: m.scale, currency: m.currency}
+}
+
+func (m Money) Sign() int {
+	return m.value().Sign()
+}
+
+func (m Money) IsZero() bool {
+	return m.Sign() == 0
+}
+
+// Cmp compares two amounts of the same currency like big.Int.Cmp.
+func (m Money) Cmp(other Money) (int, error) {
+	if _, err := m.sameCurrency(other); err != nil {
+		return 0, err
+	}
+
+	scale := max(m.scale, other.scale)
+	return m.rescale(scale).Cmp(other.rescale(scale)), nil
+}
+
+// Equal reports whether both amounts are the same in the same currency, so
+// "10.5" equals "10.50".
+func (m Money) Equal(other Money) bool {
+	c, err := m.Cmp(other)
+	return err == nil && c == 0
+}
+
+// Round rounds to the minor units of the currency, half away from zero.
+func (m Money) Round() Money {
+	return m.roundTo(minorUnitsOf(m.currency))
+}
+
+func (m Money) roundTo(scale int) Money {
+	if m.scale <= scale {
+		return Money{units: m.rescale(scale), scale: scale, currency: m.currency}
+	}
+
+	divisor := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(m.scale-scale)), nil)
+	quotient, remainder := new(big.Int).QuoRem(m.value(), divisor, new(big.Int))
+	if new(big.Int).Mul(new(big.Int).Abs(remainder), big.NewInt(2)).Cmp(divisor) >= 0 {
+		quotient.Add(quotient, big.NewInt(int64(m.Sign())))
+	}
+	return Money{units: quotient, scale: scale, currency: m.currency}
+}
+
+// Split divides the rounded amount in n parts differing by at most one minor
+// unit, the larger ones first, so they add up to the rounded amount.
+func (m Money) Split(n int) []Money {
+	if n < 1 {
+		return nil
+	}
+
+	rounded := m.Round()
+	quotient, remainder := new(big.Int).QuoRem(rounded.value(), big.NewInt(int64(n)), new(big.Int))
+
+	step := big.NewInt(int64(rounded.Sign()))
+	parts := make([]Money, n)
+	for i := range parts {
+		units := new(big.Int).Set(quotient)
+		if int64(i) < new(big.Int).Abs(remainder).Int64() {
+			units.Add(units, step)
+		}
+		parts[i] = Money{units: units, scale: rounded.scale, currency: m.currency}
+	}
+	return parts
+}
+
+// Amount returns the exact amount as a plain decimal with at least the
+// decimals of the currency, as the API expects.
+func (m Money) Amount() string {
+	scale := max(m.scale, minorUnitsOf(m.currency))
+	digits := new(big.Int).Abs(m.rescale(scale)).String()
+	if len(digits) <= scale {
+		digits = strings.Repeat("0", scale-len(digits)+1) + digits
+	}
+
+	sign := ""
+	if m.Sign() < 0 {
+		sign = "-"
+	}
+	if scale == 0 {
+		return sign + digits
+	}
+	return sign + digits[:len(digits)-scale] + "." + digits[len(digits)-scale:]
+}
+
+// Float64 is for the API calls taking float amounts; it may not be exact.
+func (m Money) Float64() float64 {
+	f, _ := new(big.Rat).SetFrac(m.value(), new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(m.scale)), nil)).Float64()
+	return f
+}
+
+func (m Money) String() string {
+	return strings.TrimSpace(m.Amount() + " " + m.currency)
+}
+
+// Locale describes how amounts are written.
+type Locale struct {
+	Decimal string
+	Group   string
+	// UnitFirst writes the unit before the amount, as in "$1,024.25".
+	UnitFirst bool
+}
+
+var (
+	LocaleEnglish = Locale{Decimal: ".", Group: ",", UnitFirst: true}
+	// LocaleSpanish is also used for Portuguese, German, Italian and Dutch.
+	LocaleSpanish = Locale{Decimal: ",", Group: "."}
+	// LocaleFrench groups thousands with a narrow no-break space.
+	LocaleFrench = Locale{Decimal: ",", Group: "\u202f"}
+)
+
+// LocaleFor returns the locale of a language tag such as the locale of a
+// resources.User, defaulting to English.
+func LocaleFor(tag string) Locale {
+	language, _, _ := strings.Cut(strings.ToLower(strings.ReplaceAll(tag, "_", "-")), "-")
+	switch language {
+	case "es", "pt", "de", "it", "nl":
+		return LocaleSpanish
+	case "fr":
+		return LocaleFrench
+	default:
+		return LocaleEnglish
+	}
+}
+
+// Format writes the amount rounded to the currency in locale, with unit,
+// such as the Unit of a resources.Currency, or the currency code if empty.
+func (m Money) Format(locale Locale, unit string) string {
+	if unit == "" {
+		unit = m.currency
+	}
+
+	amount := m.Round().Amount()
+	sign := ""
+	if strings.HasPrefix(amount, "-") {
+		sign, amount = "-", amount[1:]
+	}
+
+	whole, fraction, _ := strings.Cut(amount, ".")
+	var grouped strings.Builder
+	for i, r := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			grouped.WriteString(locale.Group)
+		}
+		grouped.WriteRune(r)
+	}
+
+	number := grouped.String()
+	if fraction != "" {
+		number += locale.Decimal + fraction
+	}
+
+	if locale.UnitFirst {
+		if isLetters(unit) {
+			unit += " "
+		}
+		return sign + unit + number
+	}
+	return sign + number + " " + unit
+}
+
+func isLetters(s string) bool {
+	for _, r := range s {
+		if !unicode.IsLetter(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseLocalized parses an amount written in locale, such as "1.024,25 $".
+// A unit may lead or trail the number, with the sign before the number or
+// the unit; anything else but digits and separators in the number fails.
+func ParseLocalized(text string, currency string, locale Locale) (Money, error) {
+	invalid := fmt.Errorf("invalid amount %q", text)
+
+	first := strings.IndexFunc(text, isDigit)
+	last := strings.LastIndexFunc(text, isDigit)
+	if first < 0 {
+		return Money{}, invalid
+	}
+	number := text[first : last+1]
+	prefix := strings.TrimSpace(text[:first])
+	suffix := strings.TrimSpace(text[last+1:])
+
+	negative := false
+	if p, ok := strings.CutPrefix(prefix, "-"); ok {
+		negative, prefix = true, strings.TrimSpace(p)
+	} else if p, ok := strings.CutSuffix(prefix, "-"); ok {
+		negative, prefix = true, strings.TrimSpace(p)
+	}
+	if prefix != "" && suffix != "" || strings.ContainsAny(prefix+suffix, "-+") {
+		return Money{}, invalid
+	}
+
+	var plain strings.Builder
+	if negative {
+		plain.WriteByte('-')
+	}
+	for rest := number; rest != ""; {
+		switch {
+		case locale.Group != "" && strings.HasPrefix(rest, locale.Group):
+			rest = rest[len(locale.Group):]
+		case strings.HasPrefix(rest, locale.Decimal):
+			plain.WriteByte('.')
+			rest = rest[len(locale.Decimal):]
+		case isDigit(rune(rest[0])):
+			plain.WriteByte(rest[0])
+			rest = rest[1:]
+		default:
+			return Money{}, invalid
+		}
+	}
+
+	m, err := ParseMoney(plain.String(), currency)
+	if err != nil {
+		return Money{}, invalid
+	}
+	return m, nil
+}
+
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
+
+type moneyJSON struct {
+	Amount       string `json:"amount"`
+	CurrencyCode string `json:"currency_code"`
+}
+
+// MarshalJSON writes the amount like the balances of the API.
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(moneyJSON{Amount: m.Amount(), CurrencyCode: m.currency})
+}
+
+func (m *Money) UnmarshalJSON(data []byte) error {
+	var content moneyJSON
+	if err := json.Unmarshal(data, &content); err != nil {
+		return err
+	}
+
+	parsed, err := ParseMoney(content.Amount, content.CurrencyCode)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// ExpenseCost returns the cost of an expense.
+func ExpenseCost(e resources.Expense) (Money, error) {
+	return ParseMoney(defaultAmount(e.Cost), e.CurrencyCode)
+}
+
+type ShareMoney struct {
+	UserID uint64 `json:"user_id"`
+	Paid   Money  `json:"paid"`
+	Owed   Money  `json:"owed"`
+	// Net is Paid minus Owed.
+	Net Money `json:"net"`
+}
+
+// ExpenseShares returns the shares of every user of an expense.
+func ExpenseShares(e resources.Expense) ([]ShareMoney, error) {
+	shares := make([]ShareMoney, 0, len(e.Users))
+	for _, u := range e.Users {
+		paid, err := ParseMoney(defaultAmount(u.PaidShare), e.CurrencyCode)
+		if err != nil {
+			return nil, fmt.Errorf("expense %d: paid share of user %d: %w", e.ID, u.UserId, err)
+		}
+		owed, err := ParseMoney(defaultAmount(u.OwedShare), e.CurrencyCode)
+		if err != nil {
+			return nil, fmt.Errorf("expense %d: owed share of user %d: %w", e.ID, u.UserId, err)
+		}
+
+		net, _ := paid.Sub(owed)
+		shares = append(shares, ShareMoney{UserID: u.UserId, Paid: paid, Owed: owed, Net: net})
+	}
+	return shares, nil
+}
+
+// UserBalances returns the balances of a user, such as a group member.
+func UserBalances(u resources.User) ([]Money, error) {
+	balances := make([]Money, 0, len(u.Balance))
+	for _, b := range u.Balance {
+		m, err := ParseMoney(b.Amount, b.CurrencyCode)
+		if err != nil {
+			return nil, err
+		}
+		balances = append(balances, m)
+	}
+	return balances, nil
+}
+
+// FriendBalances returns the overall balances with a friend.
+func FriendBalances(f resources.Friend) ([]Money, error) {
+	balances := make([]Money, 0, len(f.Balance))
+	for _, b := range f.Balance {
+		m, err := ParseMoney(b.Amount, b.CurrencyCode)
+		if err != nil {
+			return nil, err
+		}
+		balances = append(balances, m)
+	}
+	return balances, nil
+}
+
+func DebtAmount(d resources.Debt) (Money, error) {
+	return ParseMoney(d.Amount, d.CurrencyCode)
+}
@@ -0,0 +1,158 @@
+package smartsplitwise
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/aanzolaavila/splitwise.go/resources"
+	"github.com/stretchr/testify/assert"
+)
+
+func mustMoney(t *testing.T, amount string, currency string) Money {
+	m, err := ParseMoney(amount, currency)
+	assert.NoError(t, err)
+	return m
+}
+
+func TestParseMoney(t *testing.T) {
+	for amount, want := range map[string]string{
+		"1024.25":  "1024.25",
+		"1185.0":   "1185.00",
+		"-3":       "-3.00",
+		"+0.5":     "0.50",
+		".75":      "0.75",
+		"0.125":    "0.125",
+		"12345678": "12345678.00",
+	} {
+		m, err := ParseMoney(amount, "USD")
+		assert.NoError(t, err, amount)
+		assert.Equal(t, want, m.Amount(), amount)
+	}
+
+	for _, amount := range []string{"", "-", ".", "1,5", "1.2.3", "abc", "1e3"} {
+		_, err := ParseMoney(amount, "USD")
+		assert.Error(t, err, amount)
+	}
+}
+
+func TestMoneyArithmetic(t *testing.T) {
+	// 0.1 + 0.2 is exactly 0.3, unlike with floats
+	sum, err := mustMoney(t, "0.1", "ARS").Add(mustMoney(t, "0.2", "ARS"))
+	assert.NoError(t, err)
+	assert.True(t, sum.Equal(mustMoney(t, "0.3", "ARS")))
+
+	var total Money
+	for _, amount := range []string{"1250000.17", "1250000.16", "-2500000.33"} {
+		total, err = total.Add(mustMoney(t, amount, "ARS"))
+		assert.NoError(t, err)
+	}
+	assert.True(t, total.IsZero())
+	assert.Equal(t, "ARS", total.Currency())
+
+	diff, err := mustMoney(t, "10", "EUR").Sub(mustMoney(t, "12.5", "EUR"))
+	assert.NoError(t, err)
+	assert.Equal(t, "-2.50 EUR", diff.String())
+	assert.Equal(t, -1, diff.Sign())
+	assert.Equal(t, "7.50", mustMoney(t, "2.5", "EUR").Mul(3).Amount())
+
+	_, err = mustMoney(t, "1", "EUR").Add(mustMoney(t, "1", "USD"))
+	assert.ErrorAs(t, err, new(*CurrencyMismatchError))
+	assert.False(t, mustMoney(t, "1", "EUR").Equal(mustMoney(t, "1", "USD")))
+
+	c, err := mustMoney(t, "10.5", "EUR").Cmp(mustMoney(t, "10.50", "EUR"))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, c)
+}
+
+func TestMoneyRound(t *testing.T) {
+	assert.Equal(t, "0.13", mustMoney(t, "0.125", "USD").Round().Amount())
+	assert.Equal(t, "-0.13", mustMoney(t, "-0.125", "USD").Round().Amount())
+	assert.Equal(t, "0.12", mustMoney(t, "0.1249", "USD").Round().Amount())
+	assert.Equal(t, "1025", mustMoney(t, "1024.5", "JPY").Round().Amount())
+	assert.Equal(t, "1.235", mustMoney(t, "1.2345", "KWD").Round().Amount())
+
+	assert.Equal(t, 0, MinorUnits(resources.Currency{CurrencyCode: "CLP"}))
+	assert.Equal(t, 2, MinorUnits(resources.Currency{CurrencyCode: "ARS"}))
+	assert.Equal(t, "15.00", NewMoney(1500, "USD").Amount())
+	assert.Equal(t, "1500", NewMoney(1500, "JPY").Amount())
+}
+
+func TestMoneySplit(t *testing.T) {
+	parts := mustMoney(t, "2500000.33", "ARS").Split(2)
+	assert.Equal(t, "1250000.17", parts[0].Amount())
+	assert.Equal(t, "1250000.16", parts[1].Amount())
+
+	parts = mustMoney(t, "-10", "USD").Split(3)
+	assert.Equal(t, []string{"-3.34", "-3.33", "-3.33"}, []string{parts[0].Amount(), parts[1].Amount(), parts[2].Amount()})
+
+	assert.Nil(t, mustMoney(t, "1", "USD").Split(0))
+}
+
+func TestMoneyFormatAndParseLocalized(t *testing.T) {
+	m := mustMoney(t, "1024.25", "ARS")
+
+	assert.Equal(t, "1.024,25 $", m.Format(LocaleFor("es"), "$"))
+	assert.Equal(t, "$1,024.25", m.Format(LocaleFor("en"), "$"))
+	assert.Equal(t, "ARS 1,024.25", m.Format(LocaleFor("en-US"), ""))
+	assert.Equal(t, "1\u202f024,25 €", mustMoney(t, "1024.25", "EUR").Format(LocaleFor("fr_FR"), "€"))
+	assert.Equal(t, "-1.234.567,89 $", mustMoney(t, "-1234567.891", "ARS").Format(LocaleSpanish, "$"))
+	assert.Equal(t, "¥1,025", mustMoney(t, "1024.5", "JPY").Format(LocaleEnglish, "¥"))
+
+	parsed, err := ParseLocalized("1.024,25 $", "ARS", LocaleFor("es-AR"))
+	assert.NoError(t, err)
+	assert.True(t, parsed.Equal(m))
+
+	parsed, err = ParseLocalized("-$1,024.25", "USD", LocaleEnglish)
+	assert.NoError(t, err)
+	assert.Equal(t, "-1024.25", parsed.Amount())
+
+	parsed, err = ParseLocalized("Rs. 100", "INR", LocaleEnglish)
+	assert.NoError(t, err)
+	assert.Equal(t, "100.00", parsed.Amount())
+
+	parsed, err = ParseLocalized("- 1.024,25 €", "EUR", LocaleSpanish)
+	assert.NoError(t, err)
+	assert.Equal(t, "-1024.25", parsed.Amount())
+
+	for _, text := range []string{"$", "12abc34", "1,024.25.5", "$5 USD", "5-", "1 024.25"} {
+		_, err = ParseLocalized(text, "USD", LocaleEnglish)
+		assert.Error(t, err, text)
+	}
+}
+
+func TestMoneyJSON(t *testing.T) {
+	content, err := json.Marshal(mustMoney(t, "1024.5", "ARS"))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"amount":"1024.50","currency_code":"ARS"}`, string(content))
+
+	var m Money
+	assert.NoError(t, json.Unmarshal([]byte(`{"amount":"-30.0","currency_code":"USD"}`), &m))
+	assert.Equal(t, "-30.00 USD", m.String())
+
+	assert.Error(t, json.Unmarshal([]byte(`{"amount":"x","currency_code":"USD"}`), &m))
+}
+
+func TestMoneyAccessors(t *testing.T) {
+	expenses := getTestExpenses(t)
+	e := expenses[1]
+
+	cost, err := ExpenseCost(e)
+	assert.NoError(t, err)
+	assert.Equal(t, e.CurrencyCode, cost.Currency())
+
+	shares, err := ExpenseShares(e)
+	assert.NoError(t, err)
+	assert.Len(t, shares, len(e.Users))
+
+	var paid, net Money
+	for _, share := range shares {
+		paid, _ = paid.Add(share.Paid)
+		net, _ = net.Add(share.Net)
+	}
+	assert.True(t, paid.Equal(cost), "the paid shares should add up to the cost")
+	assert.True(t, net.IsZero())
+
+	debt, err := DebtAmount(resources.Debt{CurrencyCode: "ARS", Amount: "30.5"})
+	assert.NoError(t, err)
+	assert.Equal(t, "30.50 ARS", debt.String())
+}
@@ -0,0 +1,380 @@
+package smartsplitwise
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/aanzolaavila/splitwise.go/resources"
+)
+
+type NotificationEventType int
+
+const (
+	EventUnknown NotificationEventType = iota
+	ExpenseAdded
+	ExpenseUpdated
+	ExpenseDeleted
+	ExpenseRestored
+	PaymentRecorded
+	CommentAdded
+	GroupJoined
+)
+
+func (t NotificationEventType) String() string {
+	switch t {
+	case ExpenseAdded:
+		return "expense_added"
+	case ExpenseUpdated:
+		return "expense_updated"
+	case ExpenseDeleted:
+		return "expense_deleted"
+	case ExpenseRestored:
+		return "expense_restored"
+	case PaymentRecorded:
+		return "payment_recorded"
+	case CommentAdded:
+		return "comment_added"
+	case GroupJoined:
+		return "group_joined"
+	default:
+		return "unknown"
+	}
+}
+
+type BalanceDirection int
+
+const (
+	DirectionNone BalanceDirection = iota
+	DirectionYouOwe
+	DirectionYouAreOwed
+	DirectionSettled
+)
+
+func (d BalanceDirection) String() string {
+	switch d {
+	case DirectionYouOwe:
+		return "you_owe"
+	case DirectionYouAreOwed:
+		return "you_are_owed"
+	case DirectionSettled:
+		return "settled"
+	default:
+		return "none"
+	}
+}
+
+type NotificationEvent struct {
+	Type         NotificationEventType
+	Notification resources.Notification
+	Language     string
+	Actor        string
+	ActorIsSelf  bool
+	Subject      string
+	GroupName    string
+	// Amount is the normalized decimal amount ("1024.25"), empty when the
+	// notification does not carry one.
+	Amount    string
+	Currency  string
+	Direction BalanceDirection
+}
+
+type notificationVerb struct {
+	event   NotificationEventType
+	phrases []string
+}
+
+type notificationLanguage struct {
+	code       string
+	self       []string
+	verbs      []notificationVerb
+	settled    []string
+	youOwe     []string
+	youAreOwed []string
+}
+
+// Verbs are checked in order, so the more specific phrases (payments,
+// "added you to the group") must come before the generic "added".
+var notificationLanguages = []notificationLanguage{
+	{
+		code: "en",
+		self: []string{"you"},
+		verbs: []notificationVerb{
+			{PaymentRecorded, []string{"recorded a payment", "paid"}},
+			{CommentAdded, []string{"commented on"}},
+			{ExpenseDeleted, []string{"deleted"}},
+			{ExpenseRestored, []string{"restored", "undeleted"}},
+			{ExpenseUpdated, []string{"updated"}},
+			{GroupJoined, []string{"added you to the group", "joined the group", "joined"}},
+			{ExpenseAdded, []string{"added"}},
+		},
+		settled:    []string{"you do not owe anything", "you don't owe anything", "you are all settled up"},
+		youOwe:     []string{"you owe"},
+		youAreOwed: []string{"you get back", "you are owed"},
+	},
+	{
+		code: "es",
+		self: []string{"tú", "tu", "usted"},
+		verbs: []notificationVerb{
+			{PaymentRecorded, []string{"registró un pago", "registraste un pago", "pagó"}},
+			{CommentAdded, []string{"comentó en", "comentó", "comentaste"}},
+			{ExpenseDeleted, []string{"eliminó", "eliminaste", "borró", "borraste"}},
+			{ExpenseRestored, []string{"restauró", "restauraste"}},
+			{ExpenseUpdated, []string{"actualizó", "actualizaste"}},
+			{GroupJoined, []string{"te agregó al grupo", "te añadió al grupo", "se unió al grupo", "te uniste al grupo"}},
+			{ExpenseAdded, []string{"agregó", "agregaste", "añadió", "añadiste"}},
+		},
+		settled:    []string{"no debes nada", "no le debes nada", "estás a mano"},
+		youOwe:     []string{"debes", "le debes"},
+		youAreOwed: []string{"te deben", "recibirás", "te devolverán"},
+	},
+	{
+		code: "it",
+		self: []string{"tu"},
+		verbs: []notificationVerb{
+			{PaymentRecorded, []string{"ha registrato un pagamento", "hai registrato un pagamento", "ha pagato", "hai pagato"}},
+			{CommentAdded, []string{"ha commentato", "hai commentato"}},
+			{ExpenseDeleted, []string{"ha eliminato", "hai eliminato"}},
+			{ExpenseRestored, []string{"ha ripristinato", "hai ripristinato"}},
+			{ExpenseUpdated, []string{"ha aggiornato", "hai aggiornato"}},
+			{GroupJoined, []string{"ti ha aggiunto al gruppo", "si è unito al gruppo", "ti sei unito al gruppo"}},
+			{ExpenseAdded, []string{"ha aggiunto", "hai aggiunto"}},
+		},
+		settled:    []string{"non devi nulla", "non devi niente", "siete in pari"},
+		youOwe:     []string{"devi dare", "devi"},
+		youAreOwed: []string{"ti devono essere restituiti", "ti devono"},
+	},
+}
+
+// notificationTypes maps the numeric Splitwise notification type to an
+// event, used when the content can not be parsed.
+var notificationTypes = map[int]NotificationEventType{
+	0:  ExpenseAdded,
+	1:  ExpenseUpdated,
+	2:  ExpenseDeleted,
+	3:  CommentAdded,
+	4:  GroupJoined,
+	13: ExpenseRestored,
+}
+
+var (
+	strongRegexp = regexp.MustCompile(`(?s)<strong>(.*?)</strong>`)
+	tagRegexp    = regexp.MustCompile(`<[^>]*>`)
+	amountRegexp = regexp.MustCompile(`\d[\d.,]*`)
+	breakRegexp  = regexp.MustCompile(`(?i)<br\s*/?>`)
+)
+
+func ParseNotification(n resources.Notification) NotificationEvent {
+	ev := NotificationEvent{
+		Notification: n,
+		Type:         EventUnknown,
+	}
+
+	headline, balance := splitNotificationContent(n.Content)
+
+	strongs := strongRegexp.FindAllStringSubmatch(headline, -1)
+	values := make([]string, 0, len(strongs))
+	for _, s := range strongs {
+		values = append(values, cleanNotificationText(s[1]))
+	}
+
+	verbText := strings.ToLower(cleanNotificationText(strongRegexp.ReplaceAllString(headline, " | ")))
+
+	lang, event := matchNotificationVerb(verbText)
+	if lang != nil {
+		ev.Language = lang.code
+		ev.Type = event
+	}
+
+	if ev.Type == EventUnknown {
+		if t, ok := notificationTypes[n.Type]; ok {
+			ev.Type = t
+		} else if n.Source.Type == "Expense" {
+			ev.Type = ExpenseUpdated
+		}
+	}
+
+	if len(values) > 0 {
+		ev.Actor = values[0]
+		ev.ActorIsSelf = isSelfActor(lang, ev.Actor)
+	}
+
+	if ev.Type == GroupJoined && len(values) == 2 {
+		ev.GroupName = values[1]
+	} else {
+		if len(values) > 1 {
+			ev.Subject = values[1]
+		}
+		if len(values) > 2 {
+			ev.GroupName = values[2]
+		}
+	}
+
+	if balance != "" {
+		parseNotificationBalance(&ev, lang, cleanNotificationText(balance))
+	}
+
+	return ev
+}
+
+func splitNotificationContent(content string) (string, string) {
+	parts := breakRegexp.Split(content, 2)
+	if len(parts) == 1 {
+		return parts[0], ""
+	}
+	return parts[0], parts[1]
+}
+
+func cleanNotificationText(s string) string {
+	s = html.UnescapeString(tagRegexp.ReplaceAllString(s, ""))
+	s = strings.Trim(strings.TrimSpace(s), "“”\"'")
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func matchNotificationVerb(text string) (*notificationLanguage, NotificationEventType) {
+	for i := range notificationLanguages {
+		lang := &notificationLanguages[i]
+		for _, verb := range lang.verbs {
+			for _, phrase := range verb.phrases {
+				if containsPhrase(text, phrase) {
+					return lang, verb.event
+				}
+			}
+		}
+	}
+	return nil, EventUnknown
+}
+
+// containsPhrase matches whole words only, so "added" does not match
+// inside "aggiunto" and "paid" does not match inside "unpaid".
+func containsPhrase(text string, phrase string) bool {
+	for start := 0; ; {
+		idx := strings.Index(text[start:], phrase)
+		if idx < 0 {
+			return false
+		}
+		idx += start
+		end := idx + len(phrase)
+		if isWordBoundary(text, idx-1) && isWordBoundary(text, end) {
+			return true
+		}
+		start = idx + 1
+	}
+}
+
+func isWordBoundary(text string, pos int) bool {
+	if pos < 0 || pos >= len(text) {
+		return true
+	}
+	c := text[pos]
+	return c == ' ' || c == '|' || c == '.' || c == ',' || c == ':' || c == '!'
+}
+
+func isSelfActor(lang *notificationLanguage, actor string) bool {
+	actor = strings.ToLower(actor)
+	candidates := []*notificationLanguage{lang}
+	if lang == nil {
+		candidates = nil
+		for i := range notificationLanguages {
+			candidates = append(candidates, &notificationLanguages[i])
+		}
+	}
+	for _, l := range candidates {
+		for _, s := range l.self {
+			if actor == s {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func parseNotificationBalance(ev *NotificationEvent, lang *notificationLanguage, text string) {
+	lower := strings.ToLower(text)
+
+	languages := notificationLanguages
+	if lang != nil {
+		languages = []notificationLanguage{*lang}
+	}
+
+	for _, l := range languages {
+		if matchesAny(lower, l.settled) {
+			ev.Direction = DirectionSettled
+			break
+		}
+		if matchesAny(lower, l.youAreOwed) {
+			ev.Direction = DirectionYouAreOwed
+			break
+		}
+		if matchesAny(lower, l.youOwe) {
+			ev.Direction = DirectionYouOwe
+			break
+		}
+	}
+
+	loc := amountRegexp.FindStringIndex(text)
+	if loc == nil {
+		return
+	}
+
+	ev.Amount = normalizeAmount(text[loc[0]:loc[1]])
+	ev.Currency = extractCurrency(text, loc)
+}
+
+func matchesAny(text string, phrases []string) bool {
+	for _, p := range phrases {
+		if containsPhrase(text, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeAmount converts a localized amount ("1.024,25", "1,024.25") to a
+// plain decimal string ("1024.25"). A separator followed by one or two
+// trailing digits is the decimal separator, any other is a thousands
+// separator.
+func normalizeAmount(raw string) string {
+	raw = strings.TrimRight(raw, ".,")
+
+	decimalIdx := strings.LastIndexAny(raw, ".,")
+	if decimalIdx >= 0 {
+		if digits := len(raw) - decimalIdx - 1; digits == 0 || digits > 2 {
+			decimalIdx = -1
+		}
+	}
+
+	var b strings.Builder
+	for i, c := range raw {
+		switch {
+		case i == decimalIdx:
+			b.WriteByte('.')
+		case c == '.' || c == ',':
+		default:
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
+
+// extractCurrency returns the currency symbol or code adjacent to the amount,
+// looking first after it ("921,50 $") and then before it ("$921.50").
+func extractCurrency(text string, loc []int) string {
+	if after := strings.Fields(text[loc[1]:]); len(after) > 0 && isCurrencyToken(after[0]) {
+		return strings.TrimRight(after[0], ".")
+	}
+	if before := strings.Fields(text[:loc[0]]); len(before) > 0 && isCurrencyToken(before[len(before)-1]) {
+		return before[len(before)-1]
+	}
+	return ""
+}
+
+// isCurrencyToken accepts symbols ("$", "US$", "€") and ISO codes ("ARS").
+func isCurrencyToken(s string) bool {
+	s = strings.TrimRight(s, ".")
+	if len(s) == 3 && strings.ToUpper(s) == s && strings.IndexFunc(s, unicode.IsLetter) == 0 {
+		return true
+	}
+	return strings.IndexFunc(s, func(r rune) bool { return !unicode.IsLetter(r) }) >= 0
+}
@@ -0,0 +1,143 @@
+package smartsplitwise
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/aanzolaavila/splitwise.go/resources"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseNotificationItalian(t *testing.T) {
+	type responseStruct struct {
+		Notifications []resources.Notification
+	}
+	wantedRespounce := responseStruct{}
+	err := json.Unmarshal([]byte(cassetteBody(t, "notifications", "/api/v3.0/get_notifications")), &wantedRespounce)
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		Type        NotificationEventType
+		Actor       string
+		ActorIsSelf bool
+		Subject     string
+		GroupName   string
+		Amount      string
+		Currency    string
+		Direction   BalanceDirection
+	}{
+		{ExpenseUpdated, "Miguel A.", false, "arreglo porton", "Fundación", "", "", DirectionSettled},
+		{ExpenseAdded, "testf A.", false, "TelViso", "Fundación", "1024.25", "$", DirectionYouOwe},
+		{ExpenseAdded, "Tu", true, "Pedidoya", "Familia Cerbino Rosso", "921.50", "$", DirectionYouAreOwed},
+	}
+
+	assert.Len(t, wantedRespounce.Notifications, len(testCases))
+
+	for i, n := range wantedRespounce.Notifications {
+		ev := ParseNotification(n)
+		want := testCases[i]
+
+		assert.Equal(t, "it", ev.Language)
+		assert.Equal(t, want.Type, ev.Type)
+		assert.Equal(t, want.Actor, ev.Actor)
+		assert.Equal(t, want.ActorIsSelf, ev.ActorIsSelf)
+		assert.Equal(t, want.Subject, ev.Subject)
+		assert.Equal(t, want.GroupName, ev.GroupName)
+		assert.Equal(t, want.Amount, ev.Amount)
+		assert.Equal(t, want.Currency, ev.Currency)
+		assert.Equal(t, want.Direction, ev.Direction)
+		assert.Equal(t, n.ID, ev.Notification.ID)
+	}
+}
+
+func TestParseNotificationLanguages(t *testing.T) {
+	testCases := []struct {
+		Content   string
+		Language  string
+		Type      NotificationEventType
+		Subject   string
+		GroupName string
+		Amount    string
+		Currency  string
+		Direction BalanceDirection
+	}{
+		{
+			Content:   `<strong>Ana B.</strong> added <strong>“Groceries”</strong> in <strong>“Home”</strong>.<br><font color="#ff652f">You owe $1,024.25</font>`,
+			Language:  "en",
+			Type:      ExpenseAdded,
+			Subject:   "Groceries",
+			GroupName: "Home",
+			Amount:    "1024.25",
+			Currency:  "$",
+			Direction: DirectionYouOwe,
+		},
+		{
+			Content:   `<strong>Ana B.</strong> recorded a payment <strong>“Payment”</strong> in <strong>“Home”</strong>.<br><font color="#5bc5a7">You get back €20.00</font>`,
+			Language:  "en",
+			Type:      PaymentRecorded,
+			Subject:   "Payment",
+			GroupName: "Home",
+			Amount:    "20.00",
+			Currency:  "€",
+			Direction: DirectionYouAreOwed,
+		},
+		{
+			Content:   `<strong>Ana B.</strong> added you to the group <strong>“Home”</strong>.`,
+			Language:  "en",
+			Type:      GroupJoined,
+			GroupName: "Home",
+		},
+		{
+			Content:   `<strong>Ana B.</strong> eliminó <strong>“Super”</strong> en el grupo <strong>“Casa”</strong>.<br><font color="#999999">No debes nada</font>`,
+			Language:  "es",
+			Type:      ExpenseDeleted,
+			Subject:   "Super",
+			GroupName: "Casa",
+			Direction: DirectionSettled,
+		},
+		{
+			Content:   `<strong>Ana B.</strong> comentó en <strong>“Super”</strong> en el grupo <strong>“Casa”</strong>.<br><font color="#5bc5a7">Te deben 2.500 ARS</font>`,
+			Language:  "es",
+			Type:      CommentAdded,
+			Subject:   "Super",
+			GroupName: "Casa",
+			Amount:    "2500",
+			Currency:  "ARS",
+			Direction: DirectionYouAreOwed,
+		},
+	}
+
+	for _, tc := range testCases {
+		ev := ParseNotification(resources.Notification{Content: tc.Content})
+
+		assert.Equal(t, tc.Language, ev.Language, tc.Content)
+		assert.Equal(t, tc.Type, ev.Type, tc.Content)
+		assert.Equal(t, "Ana B.", ev.Actor, tc.Content)
+		assert.Equal(t, tc.Subject, ev.Subject, tc.Content)
+		assert.Equal(t, tc.GroupName, ev.GroupName, tc.Content)
+		assert.Equal(t, tc.Amount, ev.Amount, tc.Content)
+		assert.Equal(t, tc.Currency, ev.Currency, tc.Content)
+		assert.Equal(t, tc.Direction, ev.Direction, tc.Content)
+	}
+}
+
+func TestParseNotificationFallback(t *testing.T) {
+	n := resources.Notification{
+		Type:    2,
+		Content: `<strong>Anna</strong> hat <strong>“Pizza”</strong> gelöscht.`,
+	}
+	n.Source.Type = "Expense"
+
+	ev := ParseNotification(n)
+	assert.Equal(t, ExpenseDeleted, ev.Type)
+	assert.Equal(t, "", ev.Language)
+	assert.Equal(t, "Pizza", ev.Subject)
+
+	n.Type = 99
+	ev = ParseNotification(n)
+	assert.Equal(t, ExpenseUpdated, ev.Type)
+
+	n.Source.Type = "Friendship"
+	ev = ParseNotification(n)
+	assert.Equal(t, EventUnknown, ev.Type)
+}
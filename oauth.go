@@ -0,0 +1,167 @@
+package smartsplitwise
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/aanzolaavila/splitwise.go"
+	"golang.org/x/oauth2"
+)
+
+var SplitwiseEndpoint = oauth2.Endpoint{
+	AuthURL:  splitwise.DefaultBaseUrl + "/oauth/authorize",
+	TokenURL: splitwise.DefaultBaseUrl + "/oauth/token",
+}
+
+// NewOAuthConfig returns the authorization-code flow configuration of a
+// Splitwise app. Send the user to AuthCodeURL(state) and pass the code
+// received on redirectURL to ExchangeCode.
+func NewOAuthConfig(clientID string, clientSecret string, redirectURL string) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		RedirectURL:  redirectURL,
+		Endpoint:     SplitwiseEndpoint,
+	}
+}
+
+// TokenStore persists the OAuth token of a single user.
+type TokenStore interface {
+	Load() (*oauth2.Token, error)
+	Save(token *oauth2.Token) error
+}
+
+type NoTokenError struct{}
+
+func (e *NoTokenError) Error() string {
+	return "no OAuth token stored, complete the authorization first"
+}
+
+type FileTokenStore struct {
+	Path string
+}
+
+func (s FileTokenStore) Load() (*oauth2.Token, error) {
+	content, err := os.ReadFile(s.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, &NoTokenError{}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	token := &oauth2.Token{}
+	if err := json.Unmarshal(content, token); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+func (s FileTokenStore) Save(token *oauth2.Token) error {
+	content, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.Path), filepath.Base(s.Path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.Path)
+}
+
+// ExchangeCode trades the authorization code for a token and saves it.
+func ExchangeCode(ctx context.Context, config *oauth2.Config, code string, store TokenStore) (*oauth2.Token, error) {
+	token, err := config.Exchange(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := store.Save(token); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// StoredTokenSource returns a TokenSource starting from the token in store.
+// Whenever the token is refreshed the new one is saved back, so the next
+// run does not need a new authorization. Splitwise tokens currently never
+// expire, in which case the stored token is used as is.
+func StoredTokenSource(ctx context.Context, config *oauth2.Config, store TokenStore) (oauth2.TokenSource, error) {
+	token, err := store.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	return &storedTokenSource{
+		source: config.TokenSource(ctx, token),
+		store:  store,
+		last:   token.AccessToken,
+	}, nil
+}
+
+type storedTokenSource struct {
+	source oauth2.TokenSource
+	store  TokenStore
+
+	mu   sync.Mutex
+	last string
+}
+
+func (s *storedTokenSource) Token() (*oauth2.Token, error) {
+	token, err := s.source.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if token.AccessToken != s.last {
+		if err := s.store.Save(token); err != nil {
+			return nil, err
+		}
+		s.last = token.AccessToken
+	}
+	return token, nil
+}
+
+// tokenSourceClient sets the Authorization header of every request from a
+// TokenSource, so refreshed tokens are picked up without reopening the
+// connection.
+type tokenSourceClient struct {
+	source oauth2.TokenSource
+	next   HTTPClient
+}
+
+func (c tokenSourceClient) Do(req *http.Request) (*http.Response, error) {
+	token, err := c.source.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	token.SetAuthHeader(req)
+	return c.next.Do(req)
+}
+
+func defaultHttpClient() *http.Client {
+	return &http.Client{
+		Timeout: 10 * time.Second,
+	}
+}
@@ -0,0 +1,112 @@
+package smartsplitwise
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func getOAuthStubServer(t *testing.T, refreshes *int32) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/oauth/token", r.URL.Path)
+		assert.NoError(t, r.ParseForm())
+
+		w.Header().Set("Content-Type", "application/json")
+		switch r.Form.Get("grant_type") {
+		case "authorization_code":
+			assert.Equal(t, "the-code", r.Form.Get("code"))
+			w.Write([]byte(`{"access_token": "first", "token_type": "bearer", "refresh_token": "refresh", "expires_in": 3600}`))
+		case "refresh_token":
+			atomic.AddInt32(refreshes, 1)
+			assert.Equal(t, "refresh", r.Form.Get("refresh_token"))
+			w.Write([]byte(`{"access_token": "second", "token_type": "bearer", "refresh_token": "refresh", "expires_in": 3600}`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func getTestOAuthConfig(server *httptest.Server) *oauth2.Config {
+	config := NewOAuthConfig("client", "secret", "http://localhost/callback")
+	config.Endpoint = oauth2.Endpoint{
+		AuthURL:  server.URL + "/oauth/authorize",
+		TokenURL: server.URL + "/oauth/token",
+	}
+	return config
+}
+
+func TestOAuthAuthorizationCodeFlow(t *testing.T) {
+	var refreshes int32
+	server := getOAuthStubServer(t, &refreshes)
+	config := getTestOAuthConfig(server)
+	store := FileTokenStore{Path: filepath.Join(t.TempDir(), "token.json")}
+
+	authURL := config.AuthCodeURL("state")
+	assert.True(t, strings.HasPrefix(authURL, server.URL+"/oauth/authorize?"))
+	assert.Contains(t, authURL, "client_id=client")
+
+	_, err := StoredTokenSource(context.Background(), config, store)
+	assert.IsType(t, &NoTokenError{}, err)
+
+	token, err := ExchangeCode(context.Background(), config, "the-code", store)
+	assert.NoError(t, err)
+	assert.Equal(t, "first", token.AccessToken)
+
+	stored, err := store.Load()
+	assert.NoError(t, err)
+	assert.Equal(t, "first", stored.AccessToken)
+
+	source, err := StoredTokenSource(context.Background(), config, store)
+	assert.NoError(t, err)
+	token, err = source.Token()
+	assert.NoError(t, err)
+	assert.Equal(t, "first", token.AccessToken)
+	assert.Equal(t, int32(0), refreshes)
+
+	stored.Expiry = time.Now().Add(-time.Minute)
+	assert.NoError(t, store.Save(stored))
+
+	source, err = StoredTokenSource(context.Background(), config, store)
+	assert.NoError(t, err)
+	token, err = source.Token()
+	assert.NoError(t, err)
+	assert.Equal(t, "second", token.AccessToken)
+	assert.Equal(t, int32(1), refreshes)
+
+	stored, err = store.Load()
+	assert.NoError(t, err)
+	assert.Equal(t, "second", stored.AccessToken)
+}
+
+func TestOpenWithTokenSource(t *testing.T) {
+	var authorization string
+	doFunc := func(r *http.Request) (*http.Response, error) {
+		authorization = r.Header.Get("Authorization")
+
+		resposne := http.Response{}
+		resposne.Body = io.NopCloser(strings.NewReader(testFriend))
+		resposne.Header = make(map[string][]string)
+		resposne.Header["Content-Type"] = []string{"application/json", "charset=utf-8"}
+		resposne.Status = "200"
+		resposne.StatusCode = 200
+		return &resposne, nil
+	}
+
+	source := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "oauth-token", TokenType: "bearer"})
+	conn := OpenWithOptions(WithTokenSource(source), WithHTTPClient(httpClientStub{DoFunc: doFunc}), WithLogger(getTestLogger(t)))
+
+	_, err := conn.GetFriend(12345)
+	assert.NoError(t, err)
+	assert.Equal(t, "Bearer oauth-token", authorization)
+}
@@ -0,0 +1,202 @@
+package smartsplitwise
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/aanzolaavila/splitwise.go"
+	"golang.org/x/oauth2"
+)
+
+type HTTPClient interface {
+	Do(*http.Request) (*http.Response, error)
+}
+
+type connOptions struct {
+	token       string
+	tokenSource oauth2.TokenSource
+	ctx         context.Context
+	logger      *slog.Logger
+	httpClient  HTTPClient
+	baseURL     string
+	apiPath     string
+	timeout     time.Duration
+	userAgent   string
+	auditor     Auditor
+	cache       Cache
+	ttls        CacheTTLs
+	retry       *RetryPolicy
+	limiter     *RateLimiter
+	breaker     *CircuitBreaker
+	concurrency int
+}
+
+type Option func(o *connOptions)
+
+func WithToken(token string) Option {
+	return func(o *connOptions) {
+		o.token = token
+	}
+}
+
+func WithTokenSource(source oauth2.TokenSource) Option {
+	return func(o *connOptions) {
+		o.tokenSource = source
+	}
+}
+
+func WithContext(ctx context.Context) Option {
+	return func(o *connOptions) {
+		o.ctx = ctx
+	}
+}
+
+// WithLogger sets the logger of the requests and of the background
+// executors. Requests are logged at debug level and failures at warn or
+// error level. Connections log nothing by default.
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *connOptions) {
+		o.logger = logger
+	}
+}
+
+func WithHTTPClient(client HTTPClient) Option {
+	return func(o *connOptions) {
+		o.httpClient = client
+	}
+}
+
+// WithBaseURL points the connection at another Splitwise deployment, such as
+// a local fake. apiPath defaults to splitwise.DefaultApiVersionPath when
+// empty.
+func WithBaseURL(baseURL string, apiPath string) Option {
+	return func(o *connOptions) {
+		o.baseURL = baseURL
+		o.apiPath = apiPath
+	}
+}
+
+// WithTimeout bounds every single request, including reading its body.
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *connOptions) {
+		o.timeout = timeout
+	}
+}
+
+func WithUserAgent(userAgent string) Option {
+	return func(o *connOptions) {
+		o.userAgent = userAgent
+	}
+}
+
+func WithAuditor(auditor Auditor) Option {
+	return func(o *connOptions) {
+		o.auditor = auditor
+	}
+}
+
+func OpenWithOptions(opts ...Option) SwConnection {
+	o := connOptions{
+		ctx:         context.Background(),
+		concurrency: DefaultConcurrency,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.logger == nil {
+		o.logger = discardLogger()
+	}
+
+	var client HTTPClient = defaultHttpClient()
+	if o.httpClient != nil {
+		client = o.httpClient
+	}
+	if o.timeout > 0 {
+		client = timeoutClient{timeout: o.timeout, next: client}
+	}
+	if o.userAgent != "" {
+		client = userAgentClient{userAgent: o.userAgent, next: client}
+	}
+	if o.cache != nil {
+		client = etagClient{cache: o.cache, next: client}
+	}
+	var tokenSource oauth2.TokenSource
+	if o.tokenSource != nil {
+		tokenSource = oauth2.ReuseTokenSource(nil, o.tokenSource)
+		client = tokenSourceClient{source: tokenSource, next: client}
+	}
+	if o.limiter != nil {
+		client = rateLimitClient{limiter: o.limiter, next: client}
+	}
+	if o.retry != nil {
+		client = retryClient{policy: *o.retry, logger: o.logger, next: client}
+	}
+	if o.breaker != nil {
+		client = circuitBreakerClient{breaker: o.breaker, next: client}
+	}
+	client = loggingClient{logger: o.logger, next: client}
+
+	conn := &swConnectionStruct{
+		ctx:    o.ctx,
+		logger: o.logger,
+		client: splitwise.Client{
+			Token:          o.token,
+			Logger:         printfLogger{o.logger},
+			HttpClient:     client,
+			BaseUrl:        o.baseURL,
+			ApiVersionPath: o.apiPath,
+		},
+		auditor:     o.auditor,
+		tokenSource: tokenSource,
+		cache:       o.cache,
+		ttls:        o.ttls,
+		userTTL:     DefaultCacheTTLs.CurrentUser,
+		concurrency: o.concurrency,
+	}
+	if o.cache != nil {
+		conn.userTTL = o.ttls.CurrentUser
+	}
+	return conn
+}
+
+type userAgentClient struct {
+	userAgent string
+	next      HTTPClient
+}
+
+func (c userAgentClient) Do(req *http.Request) (*http.Response, error) {
+	req.Header.Set("User-Agent", c.userAgent)
+	return c.next.Do(req)
+}
+
+type timeoutClient struct {
+	timeout time.Duration
+	next    HTTPClient
+}
+
+func (c timeoutClient) Do(req *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithTimeout(req.Context(), c.timeout)
+
+	res, err := c.next.Do(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	res.Body = cancelOnClose{ReadCloser: res.Body, cancel: cancel}
+	return res, nil
+}
+
+// cancelOnClose releases the timeout of a request once its body is read.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b cancelOnClose) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
@@ -0,0 +1,111 @@
+package smartsplitwise
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenWithOptions(t *testing.T) {
+	var userAgent, authorization, path string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent = r.Header.Get("User-Agent")
+		authorization = r.Header.Get("Authorization")
+		path = r.URL.Path
+		w.Write([]byte(testFriend))
+	}))
+	defer server.Close()
+
+	conn := OpenWithOptions(
+		WithToken("testtoken"),
+		WithBaseURL(server.URL, "/api/v9"),
+		WithUserAgent("smartsplitwise-test"),
+		WithTimeout(time.Second),
+		WithLogger(getTestLogger(t)),
+	)
+
+	friend, err := conn.GetFriend(12345)
+	assert.NoError(t, err)
+	assert.NotZero(t, friend.ID)
+	assert.Equal(t, "/api/v9/get_friend/12345", path)
+	assert.Equal(t, "smartsplitwise-test", userAgent)
+	assert.Equal(t, "Bearer testtoken", authorization)
+}
+
+func TestOpenWithOptionsTimeout(t *testing.T) {
+	doFunc := func(r *http.Request) (*http.Response, error) {
+		<-r.Context().Done()
+		return nil, r.Context().Err()
+	}
+
+	conn := OpenWithOptions(
+		WithHTTPClient(httpClientStub{DoFunc: doFunc}),
+		WithTimeout(10*time.Millisecond),
+	)
+
+	_, err := conn.GetFriend(12345)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), err)
+}
+
+func TestOpenWithOptionsCache(t *testing.T) {
+	var calls int32
+	doFunc := func(r *http.Request) (*http.Response, error) {
+		atomic.AddInt32(&calls, 1)
+
+		body := testExpence
+		if r.Method == http.MethodPost {
+			body = `{"success": true}`
+		}
+
+		resposne := http.Response{}
+		resposne.Body = io.NopCloser(strings.NewReader(body))
+		resposne.Header = make(map[string][]string)
+		resposne.Header["Content-Type"] = []string{"application/json", "charset=utf-8"}
+		resposne.Status = "200"
+		resposne.StatusCode = 200
+		return &resposne, nil
+	}
+
+	conn := OpenWithOptions(
+		WithHTTPClient(httpClientStub{DoFunc: doFunc}),
+		WithCache(NewMemoryCache(0), DefaultCacheTTLs),
+	)
+
+	first, err := conn.GetExpense(2123851796)
+	assert.NoError(t, err)
+	second, err := conn.GetExpense(2123851796)
+	assert.NoError(t, err)
+	assert.Equal(t, first, second)
+	assert.Equal(t, int32(1), calls)
+
+	assert.NoError(t, conn.DeleteExpense(2123851796))
+	_, err = conn.GetExpense(2123851796)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(3), calls)
+}
+
+func TestMemoryCacheExpires(t *testing.T) {
+	cache := NewMemoryCache(0)
+
+	cache.Set("a", []byte("1"), time.Millisecond)
+	cache.Set("b", []byte("2"), 0)
+	time.Sleep(5 * time.Millisecond)
+
+	_, ok := cache.Get("a")
+	assert.False(t, ok)
+	value, ok := cache.Get("b")
+	assert.True(t, ok)
+	assert.Equal(t, []byte("2"), value)
+
+	cache.Delete("b")
+	_, ok = cache.Get("b")
+	assert.False(t, ok)
+}
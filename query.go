@@ -0,0 +1,189 @@
+package smartsplitwise
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aanzolaavila/splitwise.go"
+)
+
+// ExpensesQuery builds validated splitwise.ExpensesParams:
+//
+//	params, err := NewExpensesQuery().Group(12345).ThisMonth().Params()
+//
+// Setters return the query so they chain; later calls override earlier
+// ones. Errors are reported by Params.
+type ExpensesQuery struct {
+	groupID       *int
+	friendID      *int
+	datedAfter    time.Time
+	datedBefore   time.Time
+	updatedAfter  time.Time
+	updatedBefore time.Time
+	limit         *int
+	offset        *int
+	now           time.Time
+}
+
+func NewExpensesQuery() *ExpensesQuery {
+	return &ExpensesQuery{}
+}
+
+func (q *ExpensesQuery) Group(id int) *ExpensesQuery {
+	q.groupID = &id
+	return q
+}
+
+func (q *ExpensesQuery) Friend(id int) *ExpensesQuery {
+	q.friendID = &id
+	return q
+}
+
+func (q *ExpensesQuery) DatedAfter(t time.Time) *ExpensesQuery {
+	q.datedAfter = t
+	return q
+}
+
+func (q *ExpensesQuery) DatedBefore(t time.Time) *ExpensesQuery {
+	q.datedBefore = t
+	return q
+}
+
+// DatedBetween keeps the expenses dated from from up to to.
+func (q *ExpensesQuery) DatedBetween(from time.Time, to time.Time) *ExpensesQuery {
+	return q.DatedAfter(from).DatedBefore(to)
+}
+
+func (q *ExpensesQuery) UpdatedAfter(t time.Time) *ExpensesQuery {
+	q.updatedAfter = t
+	return q
+}
+
+func (q *ExpensesQuery) UpdatedBefore(t time.Time) *ExpensesQuery {
+	q.updatedBefore = t
+	return q
+}
+
+// Limit sets the page size; 0 asks the API for every expense at once.
+func (q *ExpensesQuery) Limit(n int) *ExpensesQuery {
+	q.limit = &n
+	return q
+}
+
+func (q *ExpensesQuery) Offset(n int) *ExpensesQuery {
+	q.offset = &n
+	return q
+}
+
+// At sets the time ThisMonth, LastNDays and Year are relative to, and whose
+// location they use. It defaults to the current local time.
+func (q *ExpensesQuery) At(now time.Time) *ExpensesQuery {
+	q.now = now
+	return q
+}
+
+func (q *ExpensesQuery) clock() time.Time {
+	if q.now.IsZero() {
+		return time.Now()
+	}
+	return q.now
+}
+
+// ThisMonth keeps the expenses dated in the current calendar month.
+func (q *ExpensesQuery) ThisMonth() *ExpensesQuery {
+	now := q.clock()
+	start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	return q.DatedBetween(start, start.AddDate(0, 1, 0))
+}
+
+// LastNDays keeps the expenses dated today or in the n-1 days before.
+func (q *ExpensesQuery) LastNDays(n int) *ExpensesQuery {
+	now := q.clock()
+	tomorrow := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location())
+	return q.DatedBetween(tomorrow.AddDate(0, 0, -n), tomorrow)
+}
+
+// Year keeps the expenses dated in the calendar year y.
+func (q *ExpensesQuery) Year(y int) *ExpensesQuery {
+	start := time.Date(y, time.January, 1, 0, 0, 0, 0, q.clock().Location())
+	return q.DatedBetween(start, start.AddDate(1, 0, 0))
+}
+
+// Params validates the query and returns its params. Errors wrap
+// splitwise.ErrInvalidParameter.
+func (q *ExpensesQuery) Params() (splitwise.ExpensesParams, error) {
+	var errs []error
+	invalid := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%w: "+format, append([]interface{}{splitwise.ErrInvalidParameter}, args...)...))
+	}
+
+	params := splitwise.ExpensesParams{}
+
+	ids := []struct {
+		name  string
+		value *int
+		set   func(id int)
+	}{
+		{"group", q.groupID, func(id int) { params[splitwise.ExpensesGroupId] = id }},
+		{"friend", q.friendID, func(id int) { params[splitwise.ExpensesFriendId] = id }},
+	}
+	for _, id := range ids {
+		if id.value == nil {
+			continue
+		}
+		if *id.value <= 0 {
+			invalid("%s id must be positive, got %d", id.name, *id.value)
+		}
+		id.set(*id.value)
+	}
+	if q.groupID != nil && q.friendID != nil {
+		invalid("group and friend cannot be combined")
+	}
+
+	if q.limit != nil {
+		if *q.limit < 0 {
+			invalid("limit must not be negative, got %d", *q.limit)
+		}
+		params[splitwise.ExpensesLimit] = *q.limit
+	}
+	if q.offset != nil {
+		if *q.offset < 0 {
+			invalid("offset must not be negative, got %d", *q.offset)
+		}
+		params[splitwise.ExpensesOffset] = *q.offset
+	}
+
+	ranges := []struct {
+		name          string
+		after, before time.Time
+	}{
+		{"dated", q.datedAfter, q.datedBefore},
+		{"updated", q.updatedAfter, q.updatedBefore},
+	}
+	for _, r := range ranges {
+		if !r.after.IsZero() && !r.before.IsZero() && !r.after.Before(r.before) {
+			invalid("%s_after %s must be before %s_before %s", r.name, r.after.Format(time.RFC3339), r.name, r.before.Format(time.RFC3339))
+		}
+	}
+
+	dates := []struct {
+		value time.Time
+		set   func(t time.Time)
+	}{
+		{q.datedAfter, func(t time.Time) { params[splitwise.ExpensesDatedAfter] = t }},
+		{q.datedBefore, func(t time.Time) { params[splitwise.ExpensesDatedBefore] = t }},
+		{q.updatedAfter, func(t time.Time) { params[splitwise.ExpensesUpdatedAfter] = t }},
+		{q.updatedBefore, func(t time.Time) { params[splitwise.ExpensesUpdatedBefore] = t }},
+	}
+	for _, d := range dates {
+		if !d.value.IsZero() {
+			d.set(d.value)
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return params, nil
+}
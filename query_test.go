@@ -0,0 +1,133 @@
+package smartsplitwise
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aanzolaavila/splitwise.go"
+	"github.com/dcerbino-golib/smartsplitwise/splitwisetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpensesQueryParams(t *testing.T) {
+	after := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	before := time.Date(2023, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	params, err := NewExpensesQuery().
+		Group(12345).
+		DatedBetween(after, before).
+		UpdatedAfter(after).
+		Limit(50).
+		Offset(100).
+		Params()
+	assert.NoError(t, err)
+	assert.Equal(t, splitwise.ExpensesParams{
+		splitwise.ExpensesGroupId:      12345,
+		splitwise.ExpensesDatedAfter:   after,
+		splitwise.ExpensesDatedBefore:  before,
+		splitwise.ExpensesUpdatedAfter: after,
+		splitwise.ExpensesLimit:        50,
+		splitwise.ExpensesOffset:       100,
+	}, params)
+
+	params, err = NewExpensesQuery().Params()
+	assert.NoError(t, err)
+	assert.Empty(t, params)
+}
+
+func TestExpensesQueryValidation(t *testing.T) {
+	after := time.Date(2023, 2, 1, 0, 0, 0, 0, time.UTC)
+	before := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	_, err := NewExpensesQuery().Group(1).Friend(2).Params()
+	assert.ErrorIs(t, err, splitwise.ErrInvalidParameter)
+	assert.ErrorContains(t, err, "group and friend cannot be combined")
+
+	_, err = NewExpensesQuery().DatedBetween(after, before).Params()
+	assert.ErrorContains(t, err, "dated_after 2023-02-01T00:00:00Z must be before dated_before 2023-01-01T00:00:00Z")
+
+	_, err = NewExpensesQuery().UpdatedAfter(after).UpdatedBefore(after).Params()
+	assert.ErrorContains(t, err, "updated_after")
+
+	_, err = NewExpensesQuery().Group(0).Limit(-1).Offset(-5).Params()
+	assert.ErrorContains(t, err, "group id must be positive")
+	assert.ErrorContains(t, err, "limit must not be negative")
+	assert.ErrorContains(t, err, "offset must not be negative")
+}
+
+func TestExpensesQueryRanges(t *testing.T) {
+	buenosAires := time.FixedZone("ART", -3*60*60)
+	now := time.Date(2024, 2, 29, 22, 30, 0, 0, buenosAires)
+
+	params, err := NewExpensesQuery().At(now).ThisMonth().Params()
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 2, 1, 0, 0, 0, 0, buenosAires), params[splitwise.ExpensesDatedAfter])
+	assert.Equal(t, time.Date(2024, 3, 1, 0, 0, 0, 0, buenosAires), params[splitwise.ExpensesDatedBefore])
+
+	params, err = NewExpensesQuery().At(now).LastNDays(7).Params()
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 2, 23, 0, 0, 0, 0, buenosAires), params[splitwise.ExpensesDatedAfter])
+	assert.Equal(t, time.Date(2024, 3, 1, 0, 0, 0, 0, buenosAires), params[splitwise.ExpensesDatedBefore])
+
+	params, err = NewExpensesQuery().At(now).Year(2023).Params()
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2023, 1, 1, 0, 0, 0, 0, buenosAires), params[splitwise.ExpensesDatedAfter])
+	assert.Equal(t, time.Date(2024, 1, 1, 0, 0, 0, 0, buenosAires), params[splitwise.ExpensesDatedBefore])
+
+	_, err = NewExpensesQuery().At(now).LastNDays(0).Params()
+	assert.Error(t, err)
+}
+
+func TestExpensesQueryWithFakeServer(t *testing.T) {
+	server := splitwisetest.NewServer()
+	defer server.Close()
+
+	me := server.CurrentUser()
+	home := server.AddGroup("Home")
+
+	start := time.Date(2022, 12, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 14; i++ {
+		_, err := server.AddExpense(int(home.ID), fmt.Sprintf("Expense %d", i), "10", start.AddDate(0, i, 0),
+			splitwisetest.Share{UserID: me.ID, Paid: "10", Owed: "10"},
+		)
+		assert.NoError(t, err)
+	}
+
+	conn := OpenWithOptions(WithBaseURL(server.URL, ""))
+
+	params, err := NewExpensesQuery().At(time.Now().UTC()).Group(int(home.ID)).Year(2023).Limit(5).Params()
+	assert.NoError(t, err)
+
+	expenses, err := Collect(conn.GetExpenses(params))
+	assert.NoError(t, err)
+	assert.Len(t, expenses, 12)
+	assert.Equal(t, "2023-12-01T00:00:00Z", expenses[0].Date)
+	assert.Equal(t, "2023-01-01T00:00:00Z", expenses[11].Date)
+}
+
+func TestExpensesQueryOffsetWithFakeServer(t *testing.T) {
+	server := splitwisetest.NewServer()
+	defer server.Close()
+
+	me := server.CurrentUser()
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 60; i++ {
+		_, err := server.AddExpense(0, fmt.Sprintf("Expense %d", i), "10", start.AddDate(0, 0, i),
+			splitwisetest.Share{UserID: me.ID, Paid: "10", Owed: "10"},
+		)
+		assert.NoError(t, err)
+	}
+
+	conn := OpenWithOptions(WithBaseURL(server.URL, ""))
+
+	params, err := NewExpensesQuery().Offset(40).Limit(20).Params()
+	assert.NoError(t, err)
+
+	// newest first, so skipping 40 leaves the 20 oldest, each once
+	expenses, err := Collect(conn.GetExpenses(params))
+	assert.NoError(t, err)
+	assert.Len(t, expenses, 20)
+	assert.Equal(t, "Expense 19", expenses[0].Description)
+	assert.Equal(t, "Expense 0", expenses[19].Description)
+}
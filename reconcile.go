@@ -0,0 +1,226 @@
+package smartsplitwise
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aanzolaavila/splitwise.go"
+	"github.com/aanzolaavila/splitwise.go/resources"
+)
+
+type DiscrepancyKind string
+
+const (
+	// DiscrepancyVisibility marks an expense some accounts do not see.
+	DiscrepancyVisibility DiscrepancyKind = "visibility"
+	// DiscrepancyUpdatedAt marks an expense with a different updated_at on
+	// some accounts, usually a stale copy.
+	DiscrepancyUpdatedAt DiscrepancyKind = "updated_at"
+	// DiscrepancyContent marks an expense with the same updated_at but
+	// different fields or shares.
+	DiscrepancyContent DiscrepancyKind = "content"
+	// DiscrepancyDeleted marks an expense deleted on some accounts only.
+	DiscrepancyDeleted DiscrepancyKind = "deleted"
+	// DiscrepancyBalance marks a member balance of the group that differs
+	// from the sum of the expenses the account sees.
+	DiscrepancyBalance DiscrepancyKind = "balance"
+)
+
+type Discrepancy struct {
+	Kind      DiscrepancyKind     `json:"kind"`
+	ExpenseID resources.ExpenseID `json:"expense_id,omitempty"`
+	// Accounts are the accounts the discrepancy was found on.
+	Accounts []string     `json:"accounts"`
+	Detail   string       `json:"detail"`
+	Fix      string       `json:"fix"`
+	Diff     *ExpenseDiff `json:"diff,omitempty"`
+}
+
+type ReconciliationReport struct {
+	GroupID       int           `json:"group_id"`
+	Accounts      []string      `json:"accounts"`
+	Expenses      int           `json:"expenses"`
+	Discrepancies []Discrepancy `json:"discrepancies"`
+}
+
+func (r ReconciliationReport) OK() bool {
+	return len(r.Discrepancies) == 0
+}
+
+type accountView struct {
+	name     string
+	group    resources.Group
+	expenses map[resources.ExpenseID]resources.Expense
+}
+
+// Reconcile fetches the group and its expenses from every account and
+// reports where they disagree, with a suggested fix for each discrepancy.
+// It fails if the group or its expenses cannot be fetched from some account.
+func (m *Manager) Reconcile(groupID int) (ReconciliationReport, error) {
+	names := m.Names()
+	views := make(map[string]*accountView, len(names))
+	for _, name := range names {
+		views[name] = &accountView{name: name}
+	}
+
+	failures := m.Each(func(name string, conn SwConnection) error {
+		group, err := conn.GetGroup(groupID)
+		if err != nil {
+			return err
+		}
+
+		// an expense missing from a partial read is no discrepancy
+		list, err := Collect(conn.GetExpenses(splitwise.ExpensesParams{splitwise.ExpensesGroupId: groupID}))
+		if err != nil {
+			return err
+		}
+		expenses := map[resources.ExpenseID]resources.Expense{}
+		for _, e := range list {
+			expenses[e.ID] = e
+		}
+
+		views[name].group = group
+		views[name].expenses = expenses
+		return nil
+	})
+	for _, name := range names {
+		if err, ok := failures[name]; ok {
+			return ReconciliationReport{}, fmt.Errorf("reconcile account %s: %w", name, err)
+		}
+	}
+
+	report := ReconciliationReport{GroupID: groupID, Accounts: names, Discrepancies: []Discrepancy{}}
+
+	ids := map[resources.ExpenseID]bool{}
+	for _, view := range views {
+		for id := range view.expenses {
+			ids[id] = true
+		}
+	}
+	sorted := make([]resources.ExpenseID, 0, len(ids))
+	for id := range ids {
+		sorted = append(sorted, id)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	report.Expenses = len(sorted)
+
+	for _, id := range sorted {
+		report.Discrepancies = append(report.Discrepancies, reconcileExpense(id, names, views)...)
+	}
+	for _, name := range names {
+		discrepancies, err := reconcileBalances(views[name])
+		if err != nil {
+			return ReconciliationReport{}, fmt.Errorf("reconcile account %s: %w", name, err)
+		}
+		report.Discrepancies = append(report.Discrepancies, discrepancies...)
+	}
+
+	return report, nil
+}
+
+func reconcileExpense(id resources.ExpenseID, names []string, views map[string]*accountView) []Discrepancy {
+	var seen, missing, deleted, active []string
+	var latest resources.Expense
+	latestAccount := ""
+	for _, name := range names {
+		e, ok := views[name].expenses[id]
+		if !ok {
+			missing = append(missing, name)
+			continue
+		}
+		seen = append(seen, name)
+		if e.DeletedAt != "" {
+			deleted = append(deleted, name)
+		} else {
+			active = append(active, name)
+		}
+		if latestAccount == "" || e.UpdatedAt > latest.UpdatedAt {
+			latest, latestAccount = e, name
+		}
+	}
+
+	var discrepancies []Discrepancy
+	if len(missing) > 0 {
+		discrepancies = append(discrepancies, Discrepancy{
+			Kind:      DiscrepancyVisibility,
+			ExpenseID: id,
+			Accounts:  missing,
+			Detail:    fmt.Sprintf("%q is visible to %s only", latest.Description, strings.Join(seen, ", ")),
+			Fix:       fmt.Sprintf("check that %s still belong to the group, then save the expense again from %s", strings.Join(missing, ", "), latestAccount),
+		})
+	}
+	if len(deleted) > 0 && len(active) > 0 {
+		discrepancies = append(discrepancies, Discrepancy{
+			Kind:      DiscrepancyDeleted,
+			ExpenseID: id,
+			Accounts:  deleted,
+			Detail:    fmt.Sprintf("%q is deleted on %s but not on %s", latest.Description, strings.Join(deleted, ", "), strings.Join(active, ", ")),
+			Fix:       fmt.Sprintf("restore it from %s or delete it again from %s", deleted[0], active[0]),
+		})
+		return discrepancies
+	}
+
+	for _, name := range seen {
+		e := views[name].expenses[id]
+		if name == latestAccount {
+			continue
+		}
+
+		diff := DiffExpense(e, latest)
+		if e.UpdatedAt != latest.UpdatedAt {
+			discrepancies = append(discrepancies, Discrepancy{
+				Kind:      DiscrepancyUpdatedAt,
+				ExpenseID: id,
+				Accounts:  []string{name},
+				Detail:    fmt.Sprintf("%q was updated at %s on %s and at %s on %s", latest.Description, e.UpdatedAt, name, latest.UpdatedAt, latestAccount),
+				Fix:       fmt.Sprintf("refetch it on %s, bypassing any cache; the version of %s is the latest", name, latestAccount),
+				Diff:      diffOrNil(diff),
+			})
+		} else if diff.HasChanges() {
+			discrepancies = append(discrepancies, Discrepancy{
+				Kind:      DiscrepancyContent,
+				ExpenseID: id,
+				Accounts:  []string{name, latestAccount},
+				Detail:    fmt.Sprintf("%q differs between %s and %s with the same updated_at", latest.Description, name, latestAccount),
+				Fix:       "edit the expense from either account so Splitwise stores a single new version",
+				Diff:      &diff,
+			})
+		}
+	}
+
+	return discrepancies
+}
+
+func diffOrNil(diff ExpenseDiff) *ExpenseDiff {
+	if !diff.HasChanges() {
+		return nil
+	}
+	return &diff
+}
+
+// reconcileBalances verifies the balances of the group against the
+// expenses the account sees.
+func reconcileBalances(view *accountView) ([]Discrepancy, error) {
+	expenses := make([]resources.Expense, 0, len(view.expenses))
+	for _, e := range view.expenses {
+		expenses = append(expenses, e)
+	}
+
+	verification, err := VerifyBalances(view.group, expenses)
+	if err != nil {
+		return nil, err
+	}
+
+	var discrepancies []Discrepancy
+	for _, m := range verification.Mismatches {
+		discrepancies = append(discrepancies, Discrepancy{
+			Kind:     DiscrepancyBalance,
+			Accounts: []string{view.name},
+			Detail: fmt.Sprintf("group %q reports %s %s as the %s of user %d on %s, but its visible expenses add up to %s",
+				view.group.Name, m.Reported, m.CurrencyCode, m.Source, m.UserID, view.name, m.Computed),
+			Fix: fmt.Sprintf("look for expenses %s cannot see or that were deleted on one side, then reconcile again", view.name),
+		})
+	}
+	return discrepancies, nil
+}
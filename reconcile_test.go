@@ -0,0 +1,106 @@
+package smartsplitwise
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aanzolaavila/splitwise.go/resources"
+	"github.com/dcerbino-golib/smartsplitwise/splitwisetest"
+	"github.com/stretchr/testify/assert"
+)
+
+// groupOverride reports a fixed group, like an account whose group balance
+// went out of sync with its expenses.
+type groupOverride struct {
+	SwConnection
+	group resources.Group
+}
+
+func (c groupOverride) GetGroup(id int) (resources.Group, error) {
+	return c.group, nil
+}
+
+func TestReconcileAgreeingAccounts(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	diego := newFamiliaServer(t, "Rent", now)
+	ana := newFamiliaServer(t, "Rent", now)
+
+	manager := NewManager()
+	manager.Open("diego", WithBaseURL(diego.URL, ""))
+	manager.Open("ana", WithBaseURL(ana.URL, ""))
+
+	report, err := manager.Reconcile(3)
+	assert.NoError(t, err)
+	assert.True(t, report.OK(), "%+v", report.Discrepancies)
+	assert.Equal(t, 1, report.Expenses)
+}
+
+func TestReconcileDiscrepancies(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	diego := newFamiliaServer(t, "Rent", now)
+	ana := newFamiliaServer(t, "Rent March", now.Add(time.Hour))
+
+	for _, server := range []*splitwisetest.Server{diego, ana} {
+		_, err := server.AddExpense(3, "Dinner", "10", now,
+			splitwisetest.Share{UserID: 1, Paid: "10", Owed: "5"},
+			splitwisetest.Share{UserID: 2, Paid: "0", Owed: "5"},
+		)
+		assert.NoError(t, err)
+	}
+	_, err := diego.AddExpense(3, "Taxi", "4", now,
+		splitwisetest.Share{UserID: 1, Paid: "4", Owed: "2"},
+		splitwisetest.Share{UserID: 2, Paid: "0", Owed: "2"},
+	)
+	assert.NoError(t, err)
+
+	manager := NewManager()
+	manager.Open("diego", WithBaseURL(diego.URL, ""))
+	anaConn := manager.Open("ana", WithBaseURL(ana.URL, ""))
+	assert.NoError(t, anaConn.DeleteExpense(5))
+
+	group, err := anaConn.GetGroup(3)
+	assert.NoError(t, err)
+	group.OriginalDebts = nil
+	manager.Add("ana", groupOverride{SwConnection: anaConn, group: group})
+
+	report, err := manager.Reconcile(3)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, report.Expenses)
+
+	kinds := map[DiscrepancyKind][]Discrepancy{}
+	for _, d := range report.Discrepancies {
+		kinds[d.Kind] = append(kinds[d.Kind], d)
+	}
+
+	assert.Len(t, kinds[DiscrepancyUpdatedAt], 1)
+	assert.Equal(t, resources.ExpenseID(4), kinds[DiscrepancyUpdatedAt][0].ExpenseID)
+	assert.Equal(t, []string{"diego"}, kinds[DiscrepancyUpdatedAt][0].Accounts)
+	assert.Contains(t, kinds[DiscrepancyUpdatedAt][0].Diff.Fields, FieldChange{Field: "description", Old: "Rent", New: "Rent March"})
+
+	assert.Len(t, kinds[DiscrepancyDeleted], 1)
+	assert.Equal(t, resources.ExpenseID(5), kinds[DiscrepancyDeleted][0].ExpenseID)
+	assert.Equal(t, []string{"ana"}, kinds[DiscrepancyDeleted][0].Accounts)
+
+	assert.Len(t, kinds[DiscrepancyVisibility], 1)
+	assert.Equal(t, resources.ExpenseID(6), kinds[DiscrepancyVisibility][0].ExpenseID)
+	assert.Equal(t, []string{"ana"}, kinds[DiscrepancyVisibility][0].Accounts)
+
+	assert.Len(t, kinds[DiscrepancyBalance], 2, "both members of ana's group should be off")
+	for _, d := range kinds[DiscrepancyBalance] {
+		assert.Equal(t, []string{"ana"}, d.Accounts)
+		assert.NotEmpty(t, d.Fix)
+	}
+
+	assert.Empty(t, kinds[DiscrepancyContent])
+}
+
+func TestReconcileUnknownGroup(t *testing.T) {
+	server := splitwisetest.NewServer()
+	t.Cleanup(server.Close)
+
+	manager := NewManager()
+	manager.Open("diego", WithBaseURL(server.URL, ""))
+
+	_, err := manager.Reconcile(99)
+	assert.ErrorContains(t, err, "reconcile account diego")
+}
@@ -0,0 +1,100 @@
+package smartsplitwise
+
+import (
+	"sort"
+
+	"github.com/aanzolaavila/splitwise.go/resources"
+)
+
+type Transfer struct {
+	From         uint64 `json:"from"`
+	To           uint64 `json:"to"`
+	Amount       string `json:"amount"`
+	CurrencyCode string `json:"currency_code"`
+}
+
+type memberBalance struct {
+	id     uint64
+	amount Money
+}
+
+// SettlePlan proposes the transfers that settle every member balance of
+// group, per currency, matching the largest debtor with the largest
+// creditor until all balances are zero. It uses at most n-1 transfers per
+// currency for n members with a balance.
+func SettlePlan(group resources.Group) []Transfer {
+	creditors := make(map[string][]memberBalance)
+	debtors := make(map[string][]memberBalance)
+
+	for _, m := range group.Members {
+		for _, b := range m.Balance {
+			amount, err := ParseMoney(b.Amount, b.CurrencyCode)
+			if err != nil || amount.IsZero() {
+				continue
+			}
+
+			if amount.Sign() > 0 {
+				creditors[b.CurrencyCode] = append(creditors[b.CurrencyCode], memberBalance{uint64(m.ID), amount})
+			} else {
+				debtors[b.CurrencyCode] = append(debtors[b.CurrencyCode], memberBalance{uint64(m.ID), amount.Neg()})
+			}
+		}
+	}
+
+	currencies := make([]string, 0, len(debtors))
+	for c := range debtors {
+		currencies = append(currencies, c)
+	}
+	sort.Strings(currencies)
+
+	var transfers []Transfer
+	for _, currency := range currencies {
+		transfers = append(transfers, settleCurrency(currency, creditors[currency], debtors[currency])...)
+	}
+
+	return transfers
+}
+
+func settleCurrency(currency string, creditors []memberBalance, debtors []memberBalance) []Transfer {
+	byAmount := func(list []memberBalance) {
+		sort.SliceStable(list, func(i, j int) bool {
+			if c, _ := list[i].amount.Cmp(list[j].amount); c != 0 {
+				return c > 0
+			}
+			return list[i].id < list[j].id
+		})
+	}
+
+	var transfers []Transfer
+	for len(creditors) > 0 && len(debtors) > 0 {
+		byAmount(creditors)
+		byAmount(debtors)
+
+		creditor, debtor := &creditors[0], &debtors[0]
+
+		// balances of one currency always compare
+		amount := debtor.amount
+		if c, _ := creditor.amount.Cmp(amount); c < 0 {
+			amount = creditor.amount
+		}
+
+		transfers = append(transfers, Transfer{
+			From:         debtor.id,
+			To:           creditor.id,
+			Amount:       amount.Round().Amount(),
+			CurrencyCode: currency,
+		})
+
+		creditor.amount, _ = creditor.amount.Sub(amount)
+		debtor.amount, _ = debtor.amount.Sub(amount)
+
+		if creditor.amount.IsZero() {
+			creditors = creditors[1:]
+		}
+		if debtor.amount.IsZero() {
+			debtors = debtors[1:]
+		}
+	}
+
+	return transfers
+}
@@ -0,0 +1,52 @@
+package smartsplitwise
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/aanzolaavila/splitwise.go/resources"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSettlePlanFromGroup(t *testing.T) {
+	type responseStruct struct {
+		Group resources.Group
+	}
+	wantedRespounce := responseStruct{}
+	err := json.Unmarshal([]byte(testGroup), &wantedRespounce)
+	assert.NoError(t, err)
+
+	transfers := SettlePlan(wantedRespounce.Group)
+
+	assert.Equal(t, []Transfer{
+		{From: 21679690, To: 21623741, Amount: "4983304.52", CurrencyCode: "ARS"},
+		{From: 21679690, To: 21623741, Amount: "525.00", CurrencyCode: "USD"},
+	}, transfers)
+}
+
+func TestSettlePlanSeveralMembers(t *testing.T) {
+	member := func(id resources.UserID, amount string) resources.User {
+		u := resources.User{ID: id}
+		u.Balance = append(u.Balance, struct {
+			Amount       string `json:"amount"`
+			CurrencyCode string `json:"currency_code"`
+		}{amount, "EUR"})
+		return u
+	}
+
+	group := resources.Group{Members: []resources.User{
+		member(1, "60.0"),
+		member(2, "-30.0"),
+		member(3, "-20.0"),
+		member(4, "-10.0"),
+		member(5, "0.0"),
+	}}
+
+	transfers := SettlePlan(group)
+
+	assert.Equal(t, []Transfer{
+		{From: 2, To: 1, Amount: "30.00", CurrencyCode: "EUR"},
+		{From: 3, To: 1, Amount: "20.00", CurrencyCode: "EUR"},
+		{From: 4, To: 1, Amount: "10.00", CurrencyCode: "EUR"},
+	}, transfers)
+}
@@ -2,12 +2,17 @@ package smartsplitwise
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
-	"os"
+	"log/slog"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/aanzolaavila/splitwise.go"
 	"github.com/aanzolaavila/splitwise.go/resources"
+	"golang.org/x/oauth2"
 )
 
 type splitwiseResouces interface {
@@ -20,8 +25,34 @@ type splitwiseResouces interface {
 }
 
 type swConnectionStruct struct {
-	ctx    context.Context
-	client splitwise.Client
+	ctx     context.Context
+	client  splitwise.Client
+	logger  *slog.Logger
+	auditor Auditor
+	cache   Cache
+	ttls    CacheTTLs
+
+	userMu      sync.Mutex
+	currentUser *resources.User
+	userExpires time.Time
+	userTTL     time.Duration
+
+	// tokenSource, when set, authenticates the requests instead of the
+	// token of client.
+	tokenSource oauth2.TokenSource
+
+	concurrency int
+
+	reference referenceData
+}
+
+// referenceData holds the categories and currencies of a connection, loaded
+// on first use and again after a failed load.
+type referenceData struct {
+	mu         sync.Mutex
+	loaded     bool
+	categories map[resources.Identifier]resources.MainCategory
+	currencies map[string]resources.Currency
 }
 
 type SwConnection interface {
@@ -36,21 +67,34 @@ type SwConnection interface {
 	GetNotifications(params splitwise.NotificationsParams) CommandExecutor[resources.Notification]
 	GetExpense(id int) (resources.Expense, error)
 	GetExpenses(params splitwise.ExpensesParams) CommandExecutor[resources.Expense]
-	getClient() splitwise.Client
-	getCtx() context.Context
+	GetExpenseComments(expenseId int) ([]resources.Comment, error)
+	GetExpensesByID(ids []int) []BatchResult[resources.Expense]
+	GetGroupsByID(ids []int) []BatchResult[resources.Group]
+	GetFriendsByID(ids []int) []BatchResult[resources.Friend]
+	CreateExpenseEqualGroupSplit(cost float64, description string, groupId int, params splitwise.CreateExpenseParams) ([]resources.Expense, error)
+	CreateExpenseByShares(cost float64, description string, groupId int, params splitwise.CreateExpenseParams, users []splitwise.ExpenseUser) ([]resources.Expense, error)
+	UpdateExpense(id int, cost float64, description string, groupId int, params splitwise.CreateExpenseParams, users []splitwise.ExpenseUser) ([]resources.Expense, error)
+	DeleteExpense(id int) error
+	RestoreExpense(id int) error
+	SetAuditor(auditor Auditor)
 	GetCurrentUser() (resources.User, error)
 }
 
 type commandExecutorStruct[T splitwiseResouces] struct {
-	SwConnection
-	ch    chan T
-	close bool
+	*swConnectionStruct
+	ch chan T
+	// close is set by Close while the producer goroutine reads it.
+	close atomic.Bool
+	err   error
 }
 
+// CommandExecutor streams entities over a channel, closed once they are all
+// sent or the fetch fails. Err reports the failure, after the channel is
+// closed; stopping early with Close is not one.
 type CommandExecutor[T splitwiseResouces] interface {
-	isClose() bool
 	Close()
 	GetChan() <-chan T
+	Err() error
 }
 
 type ElementNotFound struct{}
@@ -59,36 +103,32 @@ func (m *ElementNotFound) Error() string {
 	return "Element Not Found"
 }
 
-func getTokenClient(token string) splitwise.Client {
-	return splitwise.Client{
-		Token: token,
-	}
+// Open is OpenWithOptions with a static token, a context and a logger. A
+// nil logger keeps the connection silent.
+func Open(token string, ctx context.Context, logger *slog.Logger) SwConnection {
+	return OpenWithOptions(WithToken(token), WithContext(ctx), WithLogger(logger))
 }
 
-var mainCategoryCache map[resources.Identifier]resources.MainCategory = make(map[resources.Identifier]resources.MainCategory)
-var curenciesCache map[string]resources.Currency = make(map[string]resources.Currency)
-var currentUser *resources.User
-
-func Open(token string, ctx context.Context, log *log.Logger) SwConnection {
-
-	conn := &swConnectionStruct{}
-
-	conn.client = getTokenClient(token)
-	conn.client.Logger = log
-	conn.ctx = ctx
-	return conn
+// OpenWithTokenSource opens a connection authenticated with the tokens of
+// source, such as the one returned by StoredTokenSource.
+func OpenWithTokenSource(source oauth2.TokenSource, ctx context.Context, logger *slog.Logger) SwConnection {
+	return OpenWithOptions(WithTokenSource(source), WithContext(ctx), WithLogger(logger))
 }
 
 func (cs *swConnectionStruct) getCtx() context.Context {
 	return cs.ctx
 }
 
+func (cs *swConnectionStruct) getLogger() *slog.Logger {
+	return cs.logger
+}
+
 func (ce *commandExecutorStruct[T]) isClose() bool {
-	return ce.close
+	return ce.close.Load()
 }
 
 func (ce *commandExecutorStruct[T]) Close() {
-	ce.close = true
+	ce.close.Store(true)
 	for range ce.ch {
 	}
 }
@@ -97,20 +137,35 @@ func (ce *commandExecutorStruct[T]) GetChan() <-chan T {
 	return ce.ch
 }
 
-func simpleExecutor[T splitwiseResouces](conn SwConnection, method func(ctx context.Context) ([]T, error)) CommandExecutor[T] {
+// Err is safe to call once the channel is closed, which happens after err is
+// set.
+func (ce *commandExecutorStruct[T]) Err() error {
+	return ce.err
+}
+
+// Collect reads every entity of ce, failing when ce does.
+func Collect[T splitwiseResouces](ce CommandExecutor[T]) ([]T, error) {
+	list := []T{}
+	for v := range ce.GetChan() {
+		list = append(list, v)
+	}
+	return list, ce.Err()
+}
+
+func simpleExecutor[T splitwiseResouces](conn *swConnectionStruct, resource string, method func(ctx context.Context) ([]T, error)) CommandExecutor[T] {
 	ch := make(chan T)
 	ce := commandExecutorStruct[T]{}
 	ce.ch = ch
-	ce.SwConnection = conn
-	ce.close = false
+	ce.swConnectionStruct = conn
 
 	go func(ch chan<- T) {
 		defer ce.cleanCe()
-		defer recoverClosedChannel()
+		defer ce.recoverClosedChannel(resource)
 		entities, err := method(ce.getCtx())
 
 		if err != nil {
-			ce.getClient().Logger.Printf(err.Error())
+			ce.getLogger().Error("unable to fetch", slog.String("resource", resource), slog.String("error", err.Error()))
+			ce.err = fmt.Errorf("fetch %s: %w", resource, err)
 			return
 		}
 
@@ -127,7 +182,11 @@ func simpleExecutor[T splitwiseResouces](conn SwConnection, method func(ctx cont
 }
 
 func (conn *swConnectionStruct) GetMainCategory(id resources.Identifier) (*resources.MainCategory, error) {
-	result, ok := mainCategoryCache[id]
+	categories, _, err := conn.loadReference()
+	if err != nil {
+		return nil, err
+	}
+	result, ok := categories[id]
 	if !ok {
 		return nil, &ElementNotFound{}
 	}
@@ -139,13 +198,17 @@ func (conn *swConnectionStruct) GetMainCategories() CommandExecutor[resources.Ma
 	ch := make(chan resources.MainCategory)
 	ce := commandExecutorStruct[resources.MainCategory]{}
 	ce.ch = ch
-	ce.SwConnection = conn
-	ce.close = false
+	ce.swConnectionStruct = conn
 
 	go func(ch chan<- resources.MainCategory) {
 		defer ce.cleanCe()
-		defer recoverClosedChannel()
-		for _, v := range mainCategoryCache {
+		defer ce.recoverClosedChannel("categories")
+		categories, _, err := conn.loadReference()
+		if err != nil {
+			ce.err = err
+			return
+		}
+		for _, v := range categories {
 			ch <- v
 		}
 	}(ch)
@@ -153,7 +216,11 @@ func (conn *swConnectionStruct) GetMainCategories() CommandExecutor[resources.Ma
 }
 
 func (conn *swConnectionStruct) GetCurency(code string) (*resources.Currency, error) {
-	result, ok := curenciesCache[code]
+	_, currencies, err := conn.loadReference()
+	if err != nil {
+		return nil, err
+	}
+	result, ok := currencies[code]
 	if !ok {
 		return nil, &ElementNotFound{}
 	}
@@ -165,13 +232,17 @@ func (conn *swConnectionStruct) GetCurecies() CommandExecutor[resources.Currency
 	ch := make(chan resources.Currency)
 	ce := commandExecutorStruct[resources.Currency]{}
 	ce.ch = ch
-	ce.SwConnection = conn
-	ce.close = false
+	ce.swConnectionStruct = conn
 
 	go func(ch chan<- resources.Currency) {
 		defer ce.cleanCe()
-		defer recoverClosedChannel()
-		for _, v := range curenciesCache {
+		defer ce.recoverClosedChannel("currencies")
+		_, currencies, err := conn.loadReference()
+		if err != nil {
+			ce.err = err
+			return
+		}
+		for _, v := range currencies {
 			ch <- v
 		}
 	}(ch)
@@ -179,43 +250,93 @@ func (conn *swConnectionStruct) GetCurecies() CommandExecutor[resources.Currency
 
 }
 
+// loadReference returns the categories and currencies of the connection,
+// fetching them the first time. The maps must not be modified. On error they
+// hold what could be fetched.
+func (conn *swConnectionStruct) loadReference() (map[resources.Identifier]resources.MainCategory, map[string]resources.Currency, error) {
+	ref := &conn.reference
+	ref.mu.Lock()
+	defer ref.mu.Unlock()
+
+	if ref.loaded {
+		return ref.categories, ref.currencies, nil
+	}
+
+	client := conn.getClient()
+	categories := make(map[resources.Identifier]resources.MainCategory)
+	currencies := make(map[string]resources.Currency)
+
+	mainCategories, errCategories := client.GetCategories(conn.ctx)
+	if errCategories != nil {
+		conn.logger.Error("unable to fetch", slog.String("resource", "categories"), slog.String("error", errCategories.Error()))
+	}
+	for _, v := range mainCategories {
+		categories[resources.Identifier(v.ID)] = v
+	}
+
+	allCurrencies, errCurrencies := client.GetCurrencies(conn.ctx)
+	if errCurrencies != nil {
+		conn.logger.Error("unable to fetch", slog.String("resource", "currencies"), slog.String("error", errCurrencies.Error()))
+	}
+	for _, v := range allCurrencies {
+		currencies[v.CurrencyCode] = v
+	}
+
+	ref.categories = categories
+	ref.currencies = currencies
+	ref.loaded = errCategories == nil && errCurrencies == nil
+
+	var errs []error
+	if errCategories != nil {
+		errs = append(errs, fmt.Errorf("fetch categories: %w", errCategories))
+	}
+	if errCurrencies != nil {
+		errs = append(errs, fmt.Errorf("fetch currencies: %w", errCurrencies))
+	}
+	return categories, currencies, errors.Join(errs...)
+}
+
 func (conn *swConnectionStruct) GetFriends() CommandExecutor[resources.Friend] {
 	client := conn.getClient()
-	return simpleExecutor(conn, client.GetFriends)
+	return simpleExecutor(conn, "friends", client.GetFriends)
 }
 
 func (conn *swConnectionStruct) GetFriend(id int) (resources.Friend, error) {
 	client := conn.getClient()
-	return client.GetFriend(conn.ctx, id)
+	return cached(conn, cacheKey(conn.cacheScope(), "friend", id), conn.ttls.Friend, func() (resources.Friend, error) {
+		return client.GetFriend(conn.ctx, id)
+	})
 }
 
 func (conn *swConnectionStruct) GetGroups() CommandExecutor[resources.Group] {
 	client := conn.getClient()
 
-	return simpleExecutor(conn, client.GetGroups)
+	return simpleExecutor(conn, "groups", client.GetGroups)
 }
 
 func (conn *swConnectionStruct) GetGroup(id int) (resources.Group, error) {
 	client := conn.getClient()
 
-	return client.GetGroup(conn.ctx, id)
+	return cached(conn, cacheKey(conn.cacheScope(), "group", id), conn.ttls.Group, func() (resources.Group, error) {
+		return client.GetGroup(conn.ctx, id)
+	})
 }
 
 func (conn *swConnectionStruct) GetNotifications(params splitwise.NotificationsParams) CommandExecutor[resources.Notification] {
 	ch := make(chan resources.Notification)
 	ce := commandExecutorStruct[resources.Notification]{}
 	ce.ch = ch
-	ce.SwConnection = conn
-	ce.close = false
+	ce.swConnectionStruct = conn
 
 	go func(ch chan<- resources.Notification) {
 		defer ce.cleanCe()
-		defer recoverClosedChannel()
+		defer ce.recoverClosedChannel("notifications")
 		client := conn.getClient()
 
 		notifications, err := client.GetNotifications(ce.getCtx(), params)
 		if err != nil {
-			ce.getClient().Logger.Printf(err.Error())
+			ce.getLogger().Error("unable to fetch", slog.String("resource", "notifications"), slog.String("error", err.Error()))
+			ce.err = fmt.Errorf("fetch notifications: %w", err)
 			return
 		}
 
@@ -233,12 +354,109 @@ func (conn *swConnectionStruct) GetNotifications(params splitwise.NotificationsP
 
 func (conn *swConnectionStruct) GetExpense(id int) (resources.Expense, error) {
 	client := conn.getClient()
-	return client.GetExpense(conn.ctx, id)
+	return cached(conn, cacheKey(conn.cacheScope(), "expense", id), conn.ttls.Expense, func() (resources.Expense, error) {
+		return client.GetExpense(conn.ctx, id)
+	})
+}
+
+func (conn *swConnectionStruct) GetExpenseComments(expenseId int) ([]resources.Comment, error) {
+	client := conn.getClient()
+	return client.GetExpenseComments(conn.ctx, expenseId)
+}
+
+func (conn *swConnectionStruct) CreateExpenseEqualGroupSplit(cost float64, description string, groupId int, params splitwise.CreateExpenseParams) ([]resources.Expense, error) {
+	client := conn.getClient()
+
+	record := conn.beginAudit("create_expense_equal_group_split", 0, map[string]interface{}{
+		"cost":        cost,
+		"description": description,
+		"group_id":    groupId,
+		"params":      params,
+	})
+
+	expenses, err := client.CreateExpenseEqualGroupSplit(conn.ctx, cost, description, groupId, params)
+	conn.invalidate(conn.writeKeys(0, groupId, nil, expenses)...)
+	record.finish(expenses, err)
+
+	return expenses, err
+}
+
+func (conn *swConnectionStruct) CreateExpenseByShares(cost float64, description string, groupId int, params splitwise.CreateExpenseParams, users []splitwise.ExpenseUser) ([]resources.Expense, error) {
+	client := conn.getClient()
+
+	record := conn.beginAudit("create_expense_by_shares", 0, map[string]interface{}{
+		"cost":        cost,
+		"description": description,
+		"group_id":    groupId,
+		"params":      params,
+		"users":       users,
+	})
+
+	expenses, err := client.CreateExpenseByShares(conn.ctx, cost, description, groupId, params, users)
+	conn.invalidate(conn.writeKeys(0, groupId, users, expenses)...)
+	record.finish(expenses, err)
+
+	return expenses, err
+}
+
+func (conn *swConnectionStruct) UpdateExpense(id int, cost float64, description string, groupId int, params splitwise.CreateExpenseParams, users []splitwise.ExpenseUser) ([]resources.Expense, error) {
+	client := conn.getClient()
+
+	record := conn.beginAudit("update_expense", id, map[string]interface{}{
+		"id":          id,
+		"cost":        cost,
+		"description": description,
+		"group_id":    groupId,
+		"params":      params,
+		"users":       users,
+	})
+
+	keys := conn.writeKeys(id, groupId, users, nil)
+	expenses, err := client.UpdateExpense(conn.ctx, id, cost, description, groupId, params, users)
+	conn.invalidate(append(keys, conn.writeKeys(0, 0, nil, expenses)...)...)
+	record.finish(expenses, err)
+
+	return expenses, err
+}
+
+func (conn *swConnectionStruct) DeleteExpense(id int) error {
+	client := conn.getClient()
+
+	record := conn.beginAudit("delete_expense", id, map[string]interface{}{"id": id})
+
+	keys := conn.writeKeys(id, 0, nil, nil)
+	err := client.DeleteExpense(conn.ctx, id)
+	conn.invalidate(keys...)
+	record.finish(nil, err)
+
+	return err
+}
+
+func (conn *swConnectionStruct) RestoreExpense(id int) error {
+	client := conn.getClient()
+
+	record := conn.beginAudit("restore_expense", id, map[string]interface{}{"id": id})
+
+	keys := conn.writeKeys(id, 0, nil, nil)
+	err := client.RestoreExpense(conn.ctx, id)
+	conn.invalidate(keys...)
+	record.finish(nil, err)
+
+	return err
 }
 
+func (conn *swConnectionStruct) SetAuditor(auditor Auditor) {
+	conn.auditor = auditor
+}
+
+// GetCurrentUser returns the user of the connection token, kept for the
+// current user TTL of the connection.
 func (conn *swConnectionStruct) GetCurrentUser() (resources.User, error) {
-	if currentUser != nil {
-		return *currentUser, nil
+	conn.userMu.Lock()
+	defer conn.userMu.Unlock()
+
+	if conn.currentUser != nil && time.Now().Before(conn.userExpires) {
+		return *conn.currentUser, nil
 	}
 
 	client := conn.getClient()
@@ -246,11 +464,12 @@ func (conn *swConnectionStruct) GetCurrentUser() (resources.User, error) {
 	user, err := client.GetCurrentUser(conn.ctx)
 
 	if err != nil {
-		conn.client.Logger.Printf("Unable to get current user ", err)
+		conn.logger.Error("unable to get current user", slog.String("error", err.Error()))
 		return resources.User{}, err
 	}
 
-	currentUser = &user
+	conn.currentUser = &user
+	conn.userExpires = time.Now().Add(conn.userTTL)
 
 	return user, nil
 }
@@ -260,23 +479,24 @@ func (conn *swConnectionStruct) GetExpenses(params splitwise.ExpensesParams) Com
 	ce := commandExecutorStruct[resources.Expense]{}
 
 	ce.ch = ch
-	ce.SwConnection = conn
-	ce.close = false
+	ce.swConnectionStruct = conn
 
 	go func(ch chan<- resources.Expense) {
 		defer ce.cleanCe()
-		defer recoverClosedChannel()
+		defer ce.recoverClosedChannel("expenses")
 		client := conn.getClient()
+		logger := ce.getLogger().With(slog.String("resource", "expenses"))
 
-		var (
-			cont int
-		)
-		for !ce.close {
+		var page int
+		for !ce.isClose() {
+			start := time.Now()
 			expenses, err := client.GetExpenses(ce.getCtx(), params)
 			if err != nil {
-				ce.getClient().Logger.Printf(err.Error())
+				logger.Error("unable to fetch", slog.Int("page", page), slog.String("error", err.Error()))
+				ce.err = fmt.Errorf("fetch expenses page %d: %w", page, err)
 				break
 			}
+			logger.Debug("page fetched", slog.Int("page", page), slog.Int("count", len(expenses)), slog.Duration("duration", time.Since(start)))
 
 			if len(expenses) == 0 {
 				break
@@ -287,11 +507,10 @@ func (conn *swConnectionStruct) GetExpenses(params splitwise.ExpensesParams) Com
 					return
 				}
 				ch <- e
-				cont++
 			}
-			incOffset(params, cont)
+			incOffset(params, len(expenses))
+			page++
 		}
-		fmt.Println("Exit background function")
 	}(ch)
 
 	return &ce
@@ -301,40 +520,28 @@ func (conn *swConnectionStruct) getClient() splitwise.Client {
 	return conn.client
 }
 
+// incOffset moves the offset of params past inc more expenses, so paging
+// starts at the offset the caller asked for.
 func incOffset(params splitwise.ExpensesParams, inc int) {
-	params[splitwise.ExpensesOffset] = inc
+	offset := 0
+	switch v := params[splitwise.ExpensesOffset].(type) {
+	case int:
+		offset = v
+	case string:
+		offset, _ = strconv.Atoi(v)
+	}
+	params[splitwise.ExpensesOffset] = offset + inc
 }
 
-func recoverClosedChannel() {
+func (ce *commandExecutorStruct[T]) recoverClosedChannel(resource string) {
 	// recover from panic caused by writing to a closed channel
 	if r := recover(); r != nil {
-		err := fmt.Errorf("%v", r)
-		fmt.Printf("write: error writing on channel: %v\n", err)
+		ce.getLogger().Warn("error writing on channel", slog.String("resource", resource), slog.Any("panic", r))
 		return
 	}
 }
 
 func (ce *commandExecutorStruct[T]) cleanCe() {
-	ce.close = true
+	ce.close.Store(true)
 	close(ce.ch)
 }
-
-func init() {
-	conn := Open("", context.Background(), log.New(os.Stdout, "Splitwise Init LOG: ", log.Lshortfile))
-
-	client := conn.getClient()
-
-	ceCurrencies := simpleExecutor(conn, client.GetCurrencies)
-	for v := range ceCurrencies.GetChan() {
-		curenciesCache[v.CurrencyCode] = v
-	}
-
-	ceMainCategory := simpleExecutor(conn, client.GetCategories)
-
-	for v := range ceMainCategory.GetChan() {
-		mainCategoryCache[resources.Identifier(v.ID)] = v
-	}
-
-	fmt.Printf("Currency cache loaded with %d values\n", len(curenciesCache))
-	fmt.Printf("Main Category cache loaded with %d values\n", len(mainCategoryCache))
-}
@@ -4,14 +4,12 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
-	"math"
+	"log/slog"
 	"net/http"
-	"net/url"
 	"os"
-	"strconv"
 	"strings"
 	"sync"
 	"testing"
@@ -19,13 +17,10 @@ import (
 
 	"github.com/aanzolaavila/splitwise.go"
 	"github.com/aanzolaavila/splitwise.go/resources"
+	"github.com/dcerbino-golib/smartsplitwise/splitwisetest"
 	"github.com/stretchr/testify/assert"
 )
 
-const testExpences = `{"expenses":[{"id":2123851796,"group_id":11741221,"friendship_id":null,"expense_bundle_id":null,"description":"Jumbo","repeats":false,"repeat_interval":null,"email_reminder":false,"email_reminder_in_advance":-1,"next_repeat":null,"details":null,"comments_count":1,"payment":false,"creation_method":null,"transaction_method":"offline","transaction_confirmed":false,"transaction_id":null,"transaction_status":null,"cost":"1083.92","currency_code":"ARS","repayments":[{"from":21679690,"to":21623741,"amount":"541.96"}],"date":"2023-01-09T14:41:00Z","created_at":"2023-01-11T14:42:02Z","created_by":{"id":21623741,"first_name":"test1","last_name":"test","picture":{"medium":"https://splitwise.s3.amazonaws.com/uploads/user/avatar/21623741/medium_5b87c7d4-d6f5-4ef1-9a7f-0b8fbd0b806d.jpeg"},"custom_picture":true},"updated_at":"2023-01-11T17:18:08Z","updated_by":{"id":21623741,"first_name":"test1","last_name":"test","picture":{"medium":"https://splitwise.s3.amazonaws.com/uploads/user/avatar/21623741/medium_5b87c7d4-d6f5-4ef1-9a7f-0b8fbd0b806d.jpeg"},"custom_picture":true},"deleted_at":null,"deleted_by":null,"category":{"id":12,"name":"Alimentari"},"receipt":{"large":"https://splitwise.s3.amazonaws.com/uploads/expense/receipt/2123851796/large_f6af2889-3255-429a-b6ba-915d8d4b3376.png","original":"https://splitwise.s3.amazonaws.com/uploads/expense/receipt/2123851796/f6af2889-3255-429a-b6ba-915d8d4b3376.pdf"},"users":[{"user":{"id":21679690,"first_name":"test2","last_name":"test","picture":{"medium":"https://s3.amazonaws.com/splitwise/uploads/user/default_avatars/avatar-orange1-100px.png"}},"user_id":21679690,"paid_share":"0.0","owed_share":"541.96","net_balance":"-541.96"},{"user":{"id":21623741,"first_name":"test1","last_name":"test","picture":{"medium":"https://splitwise.s3.amazonaws.com/uploads/user/avatar/21623741/medium_5b87c7d4-d6f5-4ef1-9a7f-0b8fbd0b806d.jpeg"}},"user_id":21623741,"paid_share":"1083.92","owed_share":"541.96","net_balance":"541.96"}]},{"id":2115167389,"group_id":11741221,"friendship_id":null,"expense_bundle_id":null,"description":"Fiambre","repeats":false,"repeat_interval":null,"email_reminder":false,"email_reminder_in_advance":-1,"next_repeat":null,"details":null,"comments_count":0,"payment":false,"creation_method":"equal","transaction_method":"offline","transaction_confirmed":false,"transaction_id":null,"transaction_status":null,"cost":"1185.0","currency_code":"ARS","repayments":[{"from":21679690,"to":21623741,"amount":"592.5"}],"date":"2023-01-06T21:44:18Z","created_at":"2023-01-06T21:44:52Z","created_by":{"id":21623741,"first_name":"test1","last_name":"test","picture":{"medium":"https://splitwise.s3.amazonaws.com/uploads/user/avatar/21623741/medium_5b87c7d4-d6f5-4ef1-9a7f-0b8fbd0b806d.jpeg"},"custom_picture":true},"updated_at":"2023-01-06T21:44:52Z","updated_by":null,"deleted_at":null,"deleted_by":null,"category":{"id":12,"name":"Alimentari"},"receipt":{"large":null,"original":null},"users":[{"user":{"id":21623741,"first_name":"test1","last_name":"test","picture":{"medium":"https://splitwise.s3.amazonaws.com/uploads/user/avatar/21623741/medium_5b87c7d4-d6f5-4ef1-9a7f-0b8fbd0b806d.jpeg"}},"user_id":21623741,"paid_share":"1185.0","owed_share":"592.5","net_balance":"592.5"},{"user":{"id":21679690,"first_name":"test2","last_name":"test","picture":{"medium":"https://s3.amazonaws.com/splitwise/uploads/user/default_avatars/avatar-orange1-100px.png"}},"user_id":21679690,"paid_share":"0.0","owed_share":"592.5","net_balance":"-592.5"}]},{"id":2115163070,"group_id":11741221,"friendship_id":null,"expense_bundle_id":null,"description":"Jumbo","repeats":false,"repeat_interval":null,"email_reminder":false,"email_reminder_in_advance":-1,"next_repeat":null,"details":null,"comments_count":0,"payment":false,"creation_method":null,"transaction_method":"offline","transaction_confirmed":false,"transaction_id":null,"transaction_status":null,"cost":"23134.09","currency_code":"ARS","repayments":[{"from":21679690,"to":21623741,"amount":"11567.05"}],"date":"2023-01-06T21:40:55Z","created_at":"2023-01-06T21:41:23Z","created_by":{"id":21623741,"first_name":"test1","last_name":"test","picture":{"medium":"https://splitwise.s3.amazonaws.com/uploads/user/avatar/21623741/medium_5b87c7d4-d6f5-4ef1-9a7f-0b8fbd0b806d.jpeg"},"custom_picture":true},"updated_at":"2023-01-06T21:41:24Z","updated_by":{"id":21623741,"first_name":"test1","last_name":"test","picture":{"medium":"https://splitwise.s3.amazonaws.com/uploads/user/avatar/21623741/medium_5b87c7d4-d6f5-4ef1-9a7f-0b8fbd0b806d.jpeg"},"custom_picture":true},"deleted_at":null,"deleted_by":null,"category":{"id":12,"name":"Alimentari"},"receipt":{"large":"https://splitwise.s3.amazonaws.com/uploads/expense/receipt/2115163070/large_28a40d87-5e70-4942-a9c8-673b8f8e2f40.png","original":"https://splitwise.s3.amazonaws.com/uploads/expense/receipt/2115163070/28a40d87-5e70-4942-a9c8-673b8f8e2f40.pdf"},"users":[{"user":{"id":21623741,"first_name":"test1","last_name":"test","picture":{"medium":"https://splitwise.s3.amazonaws.com/uploads/user/avatar/21623741/medium_5b87c7d4-d6f5-4ef1-9a7f-0b8fbd0b806d.jpeg"}},"user_id":21623741,"paid_share":"23134.09","owed_share":"11567.04","net_balance":"11567.05"},{"user":{"id":21679690,"first_name":"test2","last_name":"test","picture":{"medium":"https://s3.amazonaws.com/splitwise/uploads/user/default_avatars/avatar-orange1-100px.png"}},"user_id":21679690,"paid_share":"0.0","owed_share":"11567.05","net_balance":"-11567.05"}]},{"id":2115160067,"group_id":11741221,"friendship_id":null,"expense_bundle_id":null,"description":"Regalo Nati","repeats":false,"repeat_interval":null,"email_reminder":false,"email_reminder_in_advance":-1,"next_repeat":null,"details":null,"comments_count":0,"payment":false,"creation_method":"equal","transaction_method":"offline","transaction_confirmed":false,"transaction_id":null,"transaction_status":null,"cost":"3680.0","currency_code":"ARS","repayments":[{"from":21679690,"to":21623741,"amount":"1840.0"}],"date":"2023-01-06T21:38:07Z","created_at":"2023-01-06T21:39:04Z","created_by":{"id":21623741,"first_name":"test1","last_name":"test","picture":{"medium":"https://splitwise.s3.amazonaws.com/uploads/user/avatar/21623741/medium_5b87c7d4-d6f5-4ef1-9a7f-0b8fbd0b806d.jpeg"},"custom_picture":true},"updated_at":"2023-01-06T21:39:04Z","updated_by":null,"deleted_at":null,"deleted_by":null,"category":{"id":42,"name":"Regali"},"receipt":{"large":null,"original":null},"users":[{"user":{"id":21623741,"first_name":"test1","last_name":"test","picture":{"medium":"https://splitwise.s3.amazonaws.com/uploads/user/avatar/21623741/medium_5b87c7d4-d6f5-4ef1-9a7f-0b8fbd0b806d.jpeg"}},"user_id":21623741,"paid_share":"3680.0","owed_share":"1840.0","net_balance":"1840.0"},{"user":{"id":21679690,"first_name":"test2","last_name":"test","picture":{"medium":"https://s3.amazonaws.com/splitwise/uploads/user/default_avatars/avatar-orange1-100px.png"}},"user_id":21679690,"paid_share":"0.0","owed_share":"1840.0","net_balance":"-1840.0"}]},{"id":2114356059,"group_id":11741221,"friendship_id":null,"expense_bundle_id":null,"description":"Payment","repeats":false,"repeat_interval":null,"email_reminder":false,"email_reminder_in_advance":-1,"next_repeat":null,"details":null,"comments_count":1,"payment":true,"creation_method":"payment","transaction_method":"offline","transaction_confirmed":false,"transaction_id":null,"transaction_status":null,"cost":"5000.0","currency_code":"ARS","repayments":[{"from":21679690,"to":21623741,"amount":"5000.0"}],"date":"2023-01-06T13:38:32Z","created_at":"2023-01-06T13:38:35Z","created_by":{"id":21623741,"first_name":"test1","last_name":"test","picture":{"medium":"https://splitwise.s3.amazonaws.com/uploads/user/avatar/21623741/medium_5b87c7d4-d6f5-4ef1-9a7f-0b8fbd0b806d.jpeg"},"custom_picture":true},"updated_at":"2023-01-06T13:38:35Z","updated_by":null,"deleted_at":null,"deleted_by":null,"category":{"id":18,"name":"Generali"},"receipt":{"large":null,"original":null},"users":[{"user":{"id":21623741,"first_name":"test1","last_name":"test","picture":{"medium":"https://splitwise.s3.amazonaws.com/uploads/user/avatar/21623741/medium_5b87c7d4-d6f5-4ef1-9a7f-0b8fbd0b806d.jpeg"}},"user_id":21623741,"paid_share":"5000.0","owed_share":"0.0","net_balance":"5000.0"},{"user":{"id":21679690,"first_name":"test2","last_name":"test","picture":{"medium":"https://s3.amazonaws.com/splitwise/uploads/user/default_avatars/avatar-orange1-100px.png"}},"user_id":21679690,"paid_share":"0.0","owed_share":"5000.0","net_balance":"-5000.0"}]},{"id":2114350422,"group_id":11741221,"friendship_id":null,"expense_bundle_id":null,"description":"Matafuego","repeats":false,"repeat_interval":null,"email_reminder":false,"email_reminder_in_advance":-1,"next_repeat":null,"details":null,"comments_count":0,"payment":false,"creation_method":null,"transaction_method":"offline","transaction_confirmed":false,"transaction_id":null,"transaction_status":null,"cost":"5000.0","currency_code":"ARS","repayments":[{"from":21623741,"to":21679690,"amount":"2500.0"}],"date":"2023-01-06T13:35:16Z","created_at":"2023-01-06T13:35:25Z","created_by":{"id":21679690,"first_name":"test2","last_name":"test","picture":{"medium":"https://s3.amazonaws.com/splitwise/uploads/user/default_avatars/avatar-orange1-100px.png"},"custom_picture":false},"updated_at":"2023-01-06T21:42:22Z","updated_by":{"id":21623741,"first_name":"test1","last_name":"test","picture":{"medium":"https://splitwise.s3.amazonaws.com/uploads/user/avatar/21623741/medium_5b87c7d4-d6f5-4ef1-9a7f-0b8fbd0b806d.jpeg"},"custom_picture":true},"deleted_at":null,"deleted_by":null,"category":{"id":15,"name":"Auto"},"receipt":{"large":null,"original":null},"users":[{"user":{"id":21679690,"first_name":"test2","last_name":"test","picture":{"medium":"https://s3.amazonaws.com/splitwise/uploads/user/default_avatars/avatar-orange1-100px.png"}},"user_id":21679690,"paid_share":"5000.0","owed_share":"2500.0","net_balance":"2500.0"},{"user":{"id":21623741,"first_name":"test1","last_name":"test","picture":{"medium":"https://splitwise.s3.amazonaws.com/uploads/user/avatar/21623741/medium_5b87c7d4-d6f5-4ef1-9a7f-0b8fbd0b806d.jpeg"}},"user_id":21623741,"paid_share":"0.0","owed_share":"2500.0","net_balance":"-2500.0"}]},{"id":2114349755,"group_id":11741221,"friendship_id":null,"expense_bundle_id":null,"description":"Chocolates keto","repeats":false,"repeat_interval":null,"email_reminder":false,"email_reminder_in_advance":-1,"next_repeat":null,"details":null,"comments_count":0,"payment":false,"creation_method":null,"transaction_method":"offline","transaction_confirmed":false,"transaction_id":null,"transaction_status":null,"cost":"1140.0","currency_code":"ARS","repayments":[{"from":21623741,"to":21679690,"amount":"570.0"}],"date":"2023-01-06T13:34:28Z","created_at":"2023-01-06T13:35:02Z","created_by":{"id":21679690,"first_name":"test2","last_name":"test","picture":{"medium":"https://s3.amazonaws.com/splitwise/uploads/user/default_avatars/avatar-orange1-100px.png"},"custom_picture":false},"updated_at":"2023-01-06T21:42:31Z","updated_by":{"id":21623741,"first_name":"test1","last_name":"test","picture":{"medium":"https://splitwise.s3.amazonaws.com/uploads/user/avatar/21623741/medium_5b87c7d4-d6f5-4ef1-9a7f-0b8fbd0b806d.jpeg"},"custom_picture":true},"deleted_at":null,"deleted_by":null,"category":{"id":12,"name":"Alimentari"},"receipt":{"large":null,"original":null},"users":[{"user":{"id":21679690,"first_name":"test2","last_name":"test","picture":{"medium":"https://s3.amazonaws.com/splitwise/uploads/user/default_avatars/avatar-orange1-100px.png"}},"user_id":21679690,"paid_share":"1140.0","owed_share":"570.0","net_balance":"570.0"},{"user":{"id":21623741,"first_name":"test1","last_name":"test","picture":{"medium":"https://splitwise.s3.amazonaws.com/uploads/user/avatar/21623741/medium_5b87c7d4-d6f5-4ef1-9a7f-0b8fbd0b806d.jpeg"}},"user_id":21623741,"paid_share":"0.0","owed_share":"570.0","net_balance":"-570.0"}]},{"id":2114348276,"group_id":11741221,"friendship_id":null,"expense_bundle_id":null,"description":"Verduleria","repeats":false,"repeat_interval":null,"email_reminder":false,"email_reminder_in_advance":-1,"next_repeat":null,"details":null,"comments_count":0,"payment":false,"creation_method":null,"transaction_method":"offline","transaction_confirmed":false,"transaction_id":null,"transaction_status":null,"cost":"2500.0","currency_code":"ARS","repayments":[{"from":21679690,"to":21623741,"amount":"1250.0"}],"date":"2023-01-06T13:33:58Z","created_at":"2023-01-06T13:34:10Z","created_by":{"id":21679690,"first_name":"test2","last_name":"test","picture":{"medium":"https://s3.amazonaws.com/splitwise/uploads/user/default_avatars/avatar-orange1-100px.png"},"custom_picture":false},"updated_at":"2023-01-06T21:42:34Z","updated_by":{"id":21623741,"first_name":"test1","last_name":"test","picture":{"medium":"https://splitwise.s3.amazonaws.com/uploads/user/avatar/21623741/medium_5b87c7d4-d6f5-4ef1-9a7f-0b8fbd0b806d.jpeg"},"custom_picture":true},"deleted_at":null,"deleted_by":null,"category":{"id":12,"name":"Alimentari"},"receipt":{"large":null,"original":null},"users":[{"user":{"id":21679690,"first_name":"test2","last_name":"test","picture":{"medium":"https://s3.amazonaws.com/splitwise/uploads/user/default_avatars/avatar-orange1-100px.png"}},"user_id":21679690,"paid_share":"0.0","owed_share":"1250.0","net_balance":"-1250.0"},{"user":{"id":21623741,"first_name":"test1","last_name":"test","picture":{"medium":"https://splitwise.s3.amazonaws.com/uploads/user/avatar/21623741/medium_5b87c7d4-d6f5-4ef1-9a7f-0b8fbd0b806d.jpeg"}},"user_id":21623741,"paid_share":"2500.0","owed_share":"1250.0","net_balance":"1250.0"}]},{"id":2114347861,"group_id":11741221,"friendship_id":null,"expense_bundle_id":null,"description":"Verduleria ","repeats":false,"repeat_interval":null,"email_reminder":false,"email_reminder_in_advance":-1,"next_repeat":null,"details":null,"comments_count":0,"payment":false,"creation_method":null,"transaction_method":"offline","transaction_confirmed":false,"transaction_id":null,"transaction_status":null,"cost":"2500.0","currency_code":"ARS","repayments":[{"from":21623741,"to":21679690,"amount":"1250.0"}],"date":"2023-01-06T13:33:47Z","created_at":"2023-01-06T13:33:56Z","created_by":{"id":21679690,"first_name":"test2","last_name":"test","picture":{"medium":"https://s3.amazonaws.com/splitwise/uploads/user/default_avatars/avatar-orange1-100px.png"},"custom_picture":false},"updated_at":"2023-01-06T21:42:38Z","updated_by":{"id":21623741,"first_name":"test1","last_name":"test","picture":{"medium":"https://splitwise.s3.amazonaws.com/uploads/user/avatar/21623741/medium_5b87c7d4-d6f5-4ef1-9a7f-0b8fbd0b806d.jpeg"},"custom_picture":true},"deleted_at":null,"deleted_by":null,"category":{"id":12,"name":"Alimentari"},"receipt":{"large":null,"original":null},"users":[{"user":{"id":21623741,"first_name":"test1","last_name":"test","picture":{"medium":"https://splitwise.s3.amazonaws.com/uploads/user/avatar/21623741/medium_5b87c7d4-d6f5-4ef1-9a7f-0b8fbd0b806d.jpeg"}},"user_id":21623741,"paid_share":"0.0","owed_share":"1250.0","net_balance":"-1250.0"},{"user":{"id":21679690,"first_name":"test2","last_name":"test","picture":{"medium":"https://s3.amazonaws.com/splitwise/uploads/user/default_avatars/avatar-orange1-100px.png"}},"user_id":21679690,"paid_share":"2500.0","owed_share":"1250.0","net_balance":"1250.0"}]},{"id":2114348729,"group_id":11741221,"friendship_id":null,"expense_bundle_id":null,"description":"Consulta pediatra ","repeats":false,"repeat_interval":null,"email_reminder":false,"email_reminder_in_advance":-1,"next_repeat":null,"details":null,"comments_count":0,"payment":false,"creation_method":null,"transaction_method":"offline","transaction_confirmed":false,"transaction_id":null,"transaction_status":null,"cost":"5500.0","currency_code":"ARS","repayments":[{"from":21623741,"to":21679690,"amount":"2750.0"}],"date":"2023-01-05T13:34:00Z","created_at":"2023-01-06T13:34:26Z","created_by":{"id":21679690,"first_name":"test2","last_name":"test","picture":{"medium":"https://s3.amazonaws.com/splitwise/uploads/user/default_avatars/avatar-orange1-100px.png"},"custom_picture":false},"updated_at":"2023-01-06T21:42:57Z","updated_by":{"id":21623741,"first_name":"test1","last_name":"test","picture":{"medium":"https://splitwise.s3.amazonaws.com/uploads/user/avatar/21623741/medium_5b87c7d4-d6f5-4ef1-9a7f-0b8fbd0b806d.jpeg"},"custom_picture":true},"deleted_at":null,"deleted_by":null,"category":{"id":43,"name":"Spese mediche"},"receipt":{"large":null,"original":null},"users":[{"user":{"id":21679690,"first_name":"test2","last_name":"test","picture":{"medium":"https://s3.amazonaws.com/splitwise/uploads/user/default_avatars/avatar-orange1-100px.png"}},"user_id":21679690,"paid_share":"5500.0","owed_share":"2750.0","net_balance":"2750.0"},{"user":{"id":21623741,"first_name":"test1","last_name":"test","picture":{"medium":"https://splitwise.s3.amazonaws.com/uploads/user/avatar/21623741/medium_5b87c7d4-d6f5-4ef1-9a7f-0b8fbd0b806d.jpeg"}},"user_id":21623741,"paid_share":"0.0","owed_share":"2750.0","net_balance":"-2750.0"}]}]}`
-
-const testNotifications = `{"notifications":[{"id":7561935342,"type":1,"created_at":"2023-04-11T12:15:53Z","created_by":33433366,"source":{"type":"Expense","id":2256815219,"url":null},"image_url":"https://s3.amazonaws.com/splitwise/uploads/notifications/v2021/0-17.png","image_shape":"square","content":"\u003cstrong\u003eMiguel A.\u003c/strong\u003e ha aggiornato \u003cstrong\u003e“arreglo porton”\u003c/strong\u003e nel gruppo \u003cstrong\u003e“Fundación ”\u003c/strong\u003e.\u003cbr\u003e\u003cfont color=\"#999999\"\u003eNon devi nulla\u003c/font\u003e"},{"id":7559274538,"type":0,"created_at":"2023-04-11T01:51:09Z","created_by":21702157,"source":{"type":"Expense","id":2292261592,"url":null},"image_url":"https://s3.amazonaws.com/splitwise/uploads/notifications/v2021/0-18.png","image_shape":"square","content":"\u003cstrong\u003etestf A.\u003c/strong\u003e ha aggiunto \u003cstrong\u003e“TelViso”\u003c/strong\u003e nel gruppo \u003cstrong\u003e“Fundación ”\u003c/strong\u003e.\u003cbr\u003e\u003cfont color=\"#ff652f\"\u003eDevi dare 1.024,25 $\u003c/font\u003e"},{"id":7558376782,"type":0,"created_at":"2023-04-10T21:37:48Z","created_by":21623741,"source":{"type":"Expense","id":2292000211,"url":null},"image_url":"https://s3.amazonaws.com/splitwise/uploads/notifications/v2021/0-13.png","image_shape":"square","content":"\u003cstrong\u003eTu\u003c/strong\u003e hai aggiunto \u003cstrong\u003e“Pedidoya”\u003c/strong\u003e nel gruppo \u003cstrong\u003e“Familia Cerbino Rosso”\u003c/strong\u003e.\u003cbr\u003e\u003cfont color=\"#5bc5a7\"\u003eTi devono essere restituiti 921,50 $\u003c/font\u003e"}]}`
-
 const getFriends200Response = `
 {
   "friends": [
@@ -404,8 +399,6 @@ const unauthorized = `{
     "error": "Invalid API Request: you are not logged in"
 }`
 
-const testGroups = `{"groups":[{"id":0,"name":"Spese senza gruppo","created_at":"2019-03-02T01:25:44Z","updated_at":"2023-04-11T17:30:41Z","members":[{"id":21623741,"first_name":"test1","last_name":"test","picture":{"small":"https://splitwise.s3.amazonaws.com/uploads/user/avatar/21623741/small_5b87c7d4-d6f5-4ef1-9a7f-0b8fbd0b806d.jpeg","medium":"https://splitwise.s3.amazonaws.com/uploads/user/avatar/21623741/medium_5b87c7d4-d6f5-4ef1-9a7f-0b8fbd0b806d.jpeg","large":"https://splitwise.s3.amazonaws.com/uploads/user/avatar/21623741/large_5b87c7d4-d6f5-4ef1-9a7f-0b8fbd0b806d.jpeg"},"custom_picture":true,"email":"test@test.com","registration_status":"confirmed","balance":[]}],"simplify_by_default":false,"original_debts":[],"simplified_debts":[],"avatar":{"small":"https://s3.amazonaws.com/splitwise/uploads/group/default_avatars/v2021/avatar-nongroup-50px.png","medium":"https://s3.amazonaws.com/splitwise/uploads/group/default_avatars/v2021/avatar-nongroup-100px.png","large":"https://s3.amazonaws.com/splitwise/uploads/group/default_avatars/v2021/avatar-nongroup-200px.png","xlarge":"https://s3.amazonaws.com/splitwise/uploads/group/default_avatars/v2021/avatar-nongroup-500px.png","xxlarge":"https://s3.amazonaws.com/splitwise/uploads/group/default_avatars/v2021/avatar-nongroup-1000px.png","original":null},"tall_avatar":{"xlarge":"https://s3.amazonaws.com/splitwise/uploads/group/default_tall_avatars/avatar-nongroup-288px.png","large":"https://s3.amazonaws.com/splitwise/uploads/group/default_tall_avatars/avatar-nongroup-192px.png"},"custom_avatar":false,"cover_photo":{"xxlarge":"https://s3.amazonaws.com/splitwise/uploads/group/default_cover_photos/coverphoto-nongroup-1000px.png","xlarge":"https://s3.amazonaws.com/splitwise/uploads/group/default_cover_photos/coverphoto-nongroup-500px.png"}},{"id":11741221,"name":"Familia test test","created_at":"2019-03-02T02:39:34Z","updated_at":"2023-04-10T21:37:48Z","members":[{"id":21623741,"first_name":"test1","last_name":"test","picture":{"small":"https://splitwise.s3.amazonaws.com/uploads/user/avatar/21623741/small_5b87c7d4-d6f5-4ef1-9a7f-0b8fbd0b806d.jpeg","medium":"https://splitwise.s3.amazonaws.com/uploads/user/avatar/21623741/medium_5b87c7d4-d6f5-4ef1-9a7f-0b8fbd0b806d.jpeg","large":"https://splitwise.s3.amazonaws.com/uploads/user/avatar/21623741/large_5b87c7d4-d6f5-4ef1-9a7f-0b8fbd0b806d.jpeg"},"custom_picture":true,"email":"test@test.com","registration_status":"confirmed","balance":[{"currency_code":"ARS","amount":"4983304.52"},{"currency_code":"USD","amount":"525.0"}]},{"id":21679690,"first_name":"test2","last_name":"test","picture":{"small":"https://s3.amazonaws.com/splitwise/uploads/user/default_avatars/avatar-orange1-50px.png","medium":"https://s3.amazonaws.com/splitwise/uploads/user/default_avatars/avatar-orange1-100px.png","large":"https://s3.amazonaws.com/splitwise/uploads/user/default_avatars/avatar-orange1-200px.png"},"custom_picture":false,"email":"test2@test.com","registration_status":"confirmed","balance":[{"currency_code":"ARS","amount":"-4983304.52"},{"currency_code":"USD","amount":"-525.0"}]}],"simplify_by_default":false,"original_debts":[{"from":21679690,"to":21623741,"amount":"4983304.52","currency_code":"ARS"},{"from":21679690,"to":21623741,"amount":"525.0","currency_code":"USD"}],"simplified_debts":[{"from":21679690,"to":21623741,"amount":"4983304.52","currency_code":"ARS"},{"from":21679690,"to":21623741,"amount":"525.0","currency_code":"USD"}],"whiteboard":null,"group_type":"apartment","invite_link":"https://www.splitwise.com/join/DoD65gHhn9w+cvgzh","group_reminders":null,"avatar":{"small":"https://splitwise.s3.amazonaws.com/uploads/group/avatar/11741221/small_2a568696-c654-4fca-b761-a5554d9c2c2e.jpeg","medium":"https://splitwise.s3.amazonaws.com/uploads/group/avatar/11741221/medium_2a568696-c654-4fca-b761-a5554d9c2c2e.jpeg","large":"https://splitwise.s3.amazonaws.com/uploads/group/avatar/11741221/large_2a568696-c654-4fca-b761-a5554d9c2c2e.jpeg","xlarge":"https://splitwise.s3.amazonaws.com/uploads/group/avatar/11741221/xlarge_2a568696-c654-4fca-b761-a5554d9c2c2e.jpeg","xxlarge":"https://splitwise.s3.amazonaws.com/uploads/group/avatar/11741221/xxlarge_2a568696-c654-4fca-b761-a5554d9c2c2e.jpeg","original":"https://splitwise.s3.amazonaws.com/uploads/group/avatar/11741221/2a568696-c654-4fca-b761-a5554d9c2c2e.jpeg"},"tall_avatar":{"xlarge":"https://splitwise.s3.amazonaws.com/uploads/group/avatar/11741221/xlarge_2a568696-c654-4fca-b761-a5554d9c2c2e.jpeg","large":"https://splitwise.s3.amazonaws.com/uploads/group/avatar/11741221/large_2a568696-c654-4fca-b761-a5554d9c2c2e.jpeg"},"custom_avatar":true,"cover_photo":{"xxlarge":"https://splitwise.s3.amazonaws.com/uploads/group/avatar/11741221/xxlarge_2a568696-c654-4fca-b761-a5554d9c2c2e.jpeg","xlarge":"https://splitwise.s3.amazonaws.com/uploads/group/avatar/11741221/xlarge_2a568696-c654-4fca-b761-a5554d9c2c2e.jpeg"}},{"id":11794860,"name":"Construcción","created_at":"2019-03-04T15:04:52Z","updated_at":"2019-08-02T13:38:25Z","members":[{"id":21623741,"first_name":"test1","last_name":"test","picture":{"small":"https://splitwise.s3.amazonaws.com/uploads/user/avatar/21623741/small_5b87c7d4-d6f5-4ef1-9a7f-0b8fbd0b806d.jpeg","medium":"https://splitwise.s3.amazonaws.com/uploads/user/avatar/21623741/medium_5b87c7d4-d6f5-4ef1-9a7f-0b8fbd0b806d.jpeg","large":"https://splitwise.s3.amazonaws.com/uploads/user/avatar/21623741/large_5b87c7d4-d6f5-4ef1-9a7f-0b8fbd0b806d.jpeg"},"custom_picture":true,"email":"test@test.com","registration_status":"confirmed","balance":[{"currency_code":"ARS","amount":"49575.25"}]},{"id":21679690,"first_name":"test2","last_name":"test","picture":{"small":"https://s3.amazonaws.com/splitwise/uploads/user/default_avatars/avatar-orange1-50px.png","medium":"https://s3.amazonaws.com/splitwise/uploads/user/default_avatars/avatar-orange1-100px.png","large":"https://s3.amazonaws.com/splitwise/uploads/user/default_avatars/avatar-orange1-200px.png"},"custom_picture":false,"email":"test2@test.com","registration_status":"confirmed","balance":[{"currency_code":"ARS","amount":"-49575.25"}]}],"simplify_by_default":false,"original_debts":[{"to":21623741,"from":21679690,"amount":"49575.25","currency_code":"ARS"}],"simplified_debts":[{"from":21679690,"to":21623741,"amount":"49575.25","currency_code":"ARS"}],"whiteboard":null,"group_type":"apartment","invite_link":"https://www.splitwise.com/join/jT1o4B9MQux+cvgzh","group_reminders":null,"avatar":{"small":"https://s3.amazonaws.com/splitwise/uploads/group/default_avatars/avatar-teal23-house-50px.png","medium":"https://s3.amazonaws.com/splitwise/uploads/group/default_avatars/avatar-teal23-house-100px.png","large":"https://s3.amazonaws.com/splitwise/uploads/group/default_avatars/avatar-teal23-house-200px.png","xlarge":"https://s3.amazonaws.com/splitwise/uploads/group/default_avatars/avatar-teal23-house-500px.png","xxlarge":"https://s3.amazonaws.com/splitwise/uploads/group/default_avatars/avatar-teal23-house-1000px.png","original":null},"tall_avatar":{"xlarge":"https://s3.amazonaws.com/splitwise/uploads/group/default_tall_avatars/avatar-teal23-house-288px.png","large":"https://s3.amazonaws.com/splitwise/uploads/group/default_tall_avatars/avatar-teal23-house-192px.png"},"custom_avatar":false,"cover_photo":{"xxlarge":"https://s3.amazonaws.com/splitwise/uploads/group/default_cover_photos/coverphoto-teal-1000px.png","xlarge":"https://s3.amazonaws.com/splitwise/uploads/group/default_cover_photos/coverphoto-teal-500px.png"}},{"id":12683611,"name":"Personal","created_at":"2019-04-29T21:20:51Z","updated_at":"2019-07-02T16:03:15Z","members":[{"id":21623741,"first_name":"test1","last_name":"test","picture":{"small":"https://splitwise.s3.amazonaws.com/uploads/user/avatar/21623741/small_5b87c7d4-d6f5-4ef1-9a7f-0b8fbd0b806d.jpeg","medium":"https://splitwise.s3.amazonaws.com/uploads/user/avatar/21623741/medium_5b87c7d4-d6f5-4ef1-9a7f-0b8fbd0b806d.jpeg","large":"https://splitwise.s3.amazonaws.com/uploads/user/avatar/21623741/large_5b87c7d4-d6f5-4ef1-9a7f-0b8fbd0b806d.jpeg"},"custom_picture":true,"email":"test@test.com","registration_status":"confirmed","balance":[]}],"simplify_by_default":false,"original_debts":[],"simplified_debts":[],"whiteboard":null,"group_type":"apartment","invite_link":"https://www.splitwise.com/join/BbCn6pxf1f4+cvgzh","group_reminders":null,"avatar":{"small":"https://s3.amazonaws.com/splitwise/uploads/group/default_avatars/avatar-blue4-house-50px.png","medium":"https://s3.amazonaws.com/splitwise/uploads/group/default_avatars/avatar-blue4-house-100px.png","large":"https://s3.amazonaws.com/splitwise/uploads/group/default_avatars/avatar-blue4-house-200px.png","xlarge":"https://s3.amazonaws.com/splitwise/uploads/group/default_avatars/avatar-blue4-house-500px.png","xxlarge":"https://s3.amazonaws.com/splitwise/uploads/group/default_avatars/avatar-blue4-house-1000px.png","original":null},"tall_avatar":{"xlarge":"https://s3.amazonaws.com/splitwise/uploads/group/default_tall_avatars/avatar-blue4-house-288px.png","large":"https://s3.amazonaws.com/splitwise/uploads/group/default_tall_avatars/avatar-blue4-house-192px.png"},"custom_avatar":false,"cover_photo":{"xxlarge":"https://s3.amazonaws.com/splitwise/uploads/group/default_cover_photos/coverphoto-blue-1000px.png","xlarge":"https://s3.amazonaws.com/splitwise/uploads/group/default_cover_photos/coverphoto-blue-500px.png"}},{"id":13548002,"name":"Bautismo","created_at":"2019-06-17T20:09:48Z","updated_at":"2019-06-26T18:34:46Z","members":[{"id":21679690,"first_name":"test2","last_name":"test","picture":{"small":"https://s3.amazonaws.com/splitwise/uploads/user/default_avatars/avatar-orange1-50px.png","medium":"https://s3.amazonaws.com/splitwise/uploads/user/default_avatars/avatar-orange1-100px.png","large":"https://s3.amazonaws.com/splitwise/uploads/user/default_avatars/avatar-orange1-200px.png"},"custom_picture":false,"email":"test2@test.com","registration_status":"confirmed","balance":[]},{"id":21623741,"first_name":"test1","last_name":"test","picture":{"small":"https://splitwise.s3.amazonaws.com/uploads/user/avatar/21623741/small_5b87c7d4-d6f5-4ef1-9a7f-0b8fbd0b806d.jpeg","medium":"https://splitwise.s3.amazonaws.com/uploads/user/avatar/21623741/medium_5b87c7d4-d6f5-4ef1-9a7f-0b8fbd0b806d.jpeg","large":"https://splitwise.s3.amazonaws.com/uploads/user/avatar/21623741/large_5b87c7d4-d6f5-4ef1-9a7f-0b8fbd0b806d.jpeg"},"custom_picture":true,"email":"test@test.com","registration_status":"confirmed","balance":[]}],"simplify_by_default":false,"original_debts":[],"simplified_debts":[],"whiteboard":null,"group_type":"apartment","invite_link":"https://www.splitwise.com/join/PkT9FtyWTbL+cvgzh","group_reminders":null,"avatar":{"small":"https://s3.amazonaws.com/splitwise/uploads/group/default_avatars/avatar-orange18-house-50px.png","medium":"https://s3.amazonaws.com/splitwise/uploads/group/default_avatars/avatar-orange18-house-100px.png","large":"https://s3.amazonaws.com/splitwise/uploads/group/default_avatars/avatar-orange18-house-200px.png","xlarge":"https://s3.amazonaws.com/splitwise/uploads/group/default_avatars/avatar-orange18-house-500px.png","xxlarge":"https://s3.amazonaws.com/splitwise/uploads/group/default_avatars/avatar-orange18-house-1000px.png","original":null},"tall_avatar":{"xlarge":"https://s3.amazonaws.com/splitwise/uploads/group/default_tall_avatars/avatar-orange18-house-288px.png","large":"https://s3.amazonaws.com/splitwise/uploads/group/default_tall_avatars/avatar-orange18-house-192px.png"},"custom_avatar":false,"cover_photo":{"xxlarge":"https://s3.amazonaws.com/splitwise/uploads/group/default_cover_photos/coverphoto-orange-1000px.png","xlarge":"https://s3.amazonaws.com/splitwise/uploads/group/default_cover_photos/coverphoto-orange-500px.png"}},{"id":19457330,"name":"Fundación ","created_at":"2020-06-21T17:40:26Z","updated_at":"2023-04-11T12:15:53Z","members":[{"id":33433366,"first_name":"test3","last_name":"test","picture":{"small":"https://s3.amazonaws.com/splitwise/uploads/user/default_avatars/avatar-orange11-50px.png","medium":"https://s3.amazonaws.com/splitwise/uploads/user/default_avatars/avatar-orange11-100px.png","large":"https://s3.amazonaws.com/splitwise/uploads/user/default_avatars/avatar-orange11-200px.png"},"custom_picture":false,"email":"test3@test.com","registration_status":"confirmed","balance":[{"currency_code":"ARS","amount":"-194.06"}]},{"id":21623741,"first_name":"test1","last_name":"test","picture":{"small":"https://splitwise.s3.amazonaws.com/uploads/user/avatar/21623741/small_5b87c7d4-d6f5-4ef1-9a7f-0b8fbd0b806d.jpeg","medium":"https://splitwise.s3.amazonaws.com/uploads/user/avatar/21623741/medium_5b87c7d4-d6f5-4ef1-9a7f-0b8fbd0b806d.jpeg","large":"https://splitwise.s3.amazonaws.com/uploads/user/avatar/21623741/large_5b87c7d4-d6f5-4ef1-9a7f-0b8fbd0b806d.jpeg"},"custom_picture":true,"email":"test@test.com","registration_status":"confirmed","balance":[{"currency_code":"ARS","amount":"22918.26"}]},{"id":21702157,"first_name":"testf","last_name":"test","picture":{"small":"https://s3.amazonaws.com/splitwise/uploads/user/default_avatars/avatar-ruby2-50px.png","medium":"https://s3.amazonaws.com/splitwise/uploads/user/default_avatars/avatar-ruby2-100px.png","large":"https://s3.amazonaws.com/splitwise/uploads/user/default_avatars/avatar-ruby2-200px.png"},"custom_picture":false,"email":"test4@test.com","registration_status":"confirmed","balance":[{"currency_code":"ARS","amount":"-22724.2"}]}],"simplify_by_default":false,"original_debts":[{"from":33433366,"to":21623741,"amount":"16978.44","currency_code":"ARS"},{"from":21702157,"to":21623741,"amount":"5939.82","currency_code":"ARS"},{"currency_code":"ARS","from":21702157,"to":33433366,"amount":"16784.38"}],"simplified_debts":[{"from":21702157,"to":21623741,"amount":"22724.2","currency_code":"ARS"},{"from":33433366,"to":21623741,"amount":"194.06","currency_code":"ARS"}],"whiteboard":null,"group_type":"apartment","invite_link":"https://www.splitwise.com/join/Q41YUqXHzcq+cvgzh","group_reminders":null,"avatar":{"small":"https://splitwise.s3.amazonaws.com/uploads/group/avatar/19457330/small_d5fade38-f5cc-4afa-a6db-5db157f857f2.jpeg","medium":"https://splitwise.s3.amazonaws.com/uploads/group/avatar/19457330/medium_d5fade38-f5cc-4afa-a6db-5db157f857f2.jpeg","large":"https://splitwise.s3.amazonaws.com/uploads/group/avatar/19457330/large_d5fade38-f5cc-4afa-a6db-5db157f857f2.jpeg","xlarge":"https://splitwise.s3.amazonaws.com/uploads/group/avatar/19457330/xlarge_d5fade38-f5cc-4afa-a6db-5db157f857f2.jpeg","xxlarge":"https://splitwise.s3.amazonaws.com/uploads/group/avatar/19457330/xxlarge_d5fade38-f5cc-4afa-a6db-5db157f857f2.jpeg","original":"https://splitwise.s3.amazonaws.com/uploads/group/avatar/19457330/d5fade38-f5cc-4afa-a6db-5db157f857f2.jpeg"},"tall_avatar":{"xlarge":"https://splitwise.s3.amazonaws.com/uploads/group/avatar/19457330/xlarge_d5fade38-f5cc-4afa-a6db-5db157f857f2.jpeg","large":"https://splitwise.s3.amazonaws.com/uploads/group/avatar/19457330/large_d5fade38-f5cc-4afa-a6db-5db157f857f2.jpeg"},"custom_avatar":true,"cover_photo":{"xxlarge":"https://splitwise.s3.amazonaws.com/uploads/group/avatar/19457330/xxlarge_d5fade38-f5cc-4afa-a6db-5db157f857f2.jpeg","xlarge":"https://splitwise.s3.amazonaws.com/uploads/group/avatar/19457330/xlarge_d5fade38-f5cc-4afa-a6db-5db157f857f2.jpeg"}},{"id":29044250,"name":"Viaggio In Italia","created_at":"2021-12-18T08:53:13Z","updated_at":"2022-09-01T15:06:00Z","members":[{"id":21623741,"first_name":"test1","last_name":"test","picture":{"small":"https://splitwise.s3.amazonaws.com/uploads/user/avatar/21623741/small_5b87c7d4-d6f5-4ef1-9a7f-0b8fbd0b806d.jpeg","medium":"https://splitwise.s3.amazonaws.com/uploads/user/avatar/21623741/medium_5b87c7d4-d6f5-4ef1-9a7f-0b8fbd0b806d.jpeg","large":"https://splitwise.s3.amazonaws.com/uploads/user/avatar/21623741/large_5b87c7d4-d6f5-4ef1-9a7f-0b8fbd0b806d.jpeg"},"custom_picture":true,"email":"test@test.com","registration_status":"confirmed","balance":[{"currency_code":"EUR","amount":"2514.68"}]},{"id":21679690,"first_name":"test2","last_name":"test","picture":{"small":"https://s3.amazonaws.com/splitwise/uploads/user/default_avatars/avatar-orange1-50px.png","medium":"https://s3.amazonaws.com/splitwise/uploads/user/default_avatars/avatar-orange1-100px.png","large":"https://s3.amazonaws.com/splitwise/uploads/user/default_avatars/avatar-orange1-200px.png"},"custom_picture":false,"email":"test2@test.com","registration_status":"confirmed","balance":[{"currency_code":"EUR","amount":"-2514.68"}]}],"simplify_by_default":false,"original_debts":[{"to":21623741,"from":21679690,"amount":"2514.68","currency_code":"EUR"}],"simplified_debts":[{"from":21679690,"to":21623741,"amount":"2514.68","currency_code":"EUR"}],"whiteboard":null,"group_type":"trip","invite_link":"https://www.splitwise.com/join/LxaFazBPRQ8+cvgzh","group_reminders":null,"avatar":{"small":"https://s3.amazonaws.com/splitwise/uploads/group/default_avatars/v2021/avatar-orange37-trip-50px.png","medium":"https://s3.amazonaws.com/splitwise/uploads/group/default_avatars/v2021/avatar-orange37-trip-100px.png","large":"https://s3.amazonaws.com/splitwise/uploads/group/default_avatars/v2021/avatar-orange37-trip-200px.png","xlarge":"https://s3.amazonaws.com/splitwise/uploads/group/default_avatars/v2021/avatar-orange37-trip-500px.png","xxlarge":"https://s3.amazonaws.com/splitwise/uploads/group/default_avatars/v2021/avatar-orange37-trip-1000px.png","original":null},"tall_avatar":{"xlarge":"https://s3.amazonaws.com/splitwise/uploads/group/default_tall_avatars/avatar-orange37-trip-288px.png","large":"https://s3.amazonaws.com/splitwise/uploads/group/default_tall_avatars/avatar-orange37-trip-192px.png"},"custom_avatar":false,"cover_photo":{"xxlarge":"https://s3.amazonaws.com/splitwise/uploads/group/default_cover_photos/coverphoto-orange-1000px.png","xlarge":"https://s3.amazonaws.com/splitwise/uploads/group/default_cover_photos/coverphoto-orange-500px.png"}}]}`
-
 type httpClientStub struct {
 	DoFunc func(*http.Request) (*http.Response, error)
 }
@@ -418,31 +411,40 @@ func (c httpClientStub) Do(r *http.Request) (*http.Response, error) {
 	return c.DoFunc(r)
 }
 
-type logger interface {
-	Printf(string, ...interface{})
+// lockedBuffer is written by the executor goroutines while the test reads it.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
 }
 
-type testLogger struct {
-	buf    bytes.Buffer
-	logger logger
-	once   sync.Once
-	T      *testing.T
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
 }
 
-func (l testLogger) Printf(s string, args ...interface{}) {
-	l.once.Do(func() {
-		tname := l.T.Name()
-		prefix := fmt.Sprintf("%s:: ", tname)
-		l.logger = log.New(io.Writer(&l.buf), prefix, log.LstdFlags)
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
 
-		l.T.Cleanup(func() {
-			if l.T.Failed() {
-				fmt.Print(l.buf.String())
-			}
-		})
+// getTestLogger returns a debug logger whose output is printed only if the
+// test fails.
+func getTestLogger(t *testing.T) *slog.Logger {
+	buf := &lockedBuffer{}
+	t.Cleanup(func() {
+		if t.Failed() {
+			fmt.Print(buf.String())
+		}
 	})
 
-	l.logger.Printf(s, args...)
+	handler := slog.NewTextHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug})
+	return slog.New(handler).With(slog.String("test", t.Name()))
+}
+
+func isClosed[T splitwiseResouces](executor CommandExecutor[T]) bool {
+	return executor.(*commandExecutorStruct[T]).isClose()
 }
 
 func TestOpen(t *testing.T) {
@@ -450,60 +452,83 @@ func TestOpen(t *testing.T) {
 
 	token := "test"
 	ctx := context.Background()
-	log := log.New(os.Stdout, "Splitwise LOG: ", log.Lshortfile)
+	log := slog.New(slog.NewTextHandler(os.Stdout, nil))
 
-	result := Open(token, ctx, log)
+	result := Open(token, ctx, log).(*swConnectionStruct)
 
 	assert.Equal(token, result.getClient().Token)
 	assert.Equal(ctx, result.getCtx())
-	assert.Equal(log, result.getClient().Logger)
+	assert.Equal(log, result.getLogger())
+}
+
+// newReferenceConnection opens a connection to a fake server, whose
+// reference data has 2 main categories and 3 currencies.
+func newReferenceConnection(t *testing.T) (*splitwisetest.Server, SwConnection) {
+	server := splitwisetest.NewServer()
+	t.Cleanup(server.Close)
+
+	return server, OpenWithOptions(WithBaseURL(server.URL, ""), WithLogger(getTestLogger(t)))
+}
+
+// countRequests counts the requests the server got for path.
+func countRequests(server *splitwisetest.Server, path string) int {
+	count := 0
+	for _, r := range server.Requests() {
+		if r == "GET "+path {
+			count++
+		}
+	}
+	return count
 }
 
 func TestMainCategoryCache(t *testing.T) {
-	assert.Equal(t, true, len(mainCategoryCache) >= 7)
+	server, conn := newReferenceConnection(t)
+
+	for i := 0; i < 2; i++ {
+		categories, _, err := conn.(*swConnectionStruct).loadReference()
+		assert.NoError(t, err)
+		assert.Len(t, categories, 2)
+	}
+	assert.Equal(t, 1, countRequests(server, "/get_categories"))
 }
 
 func TestCurenciesCache(t *testing.T) {
-	assert.Equal(t, true, len(curenciesCache) >= 148)
+	server, conn := newReferenceConnection(t)
+
+	for i := 0; i < 2; i++ {
+		_, currencies, err := conn.(*swConnectionStruct).loadReference()
+		assert.NoError(t, err)
+		assert.Len(t, currencies, 3)
+	}
+	assert.Equal(t, 1, countRequests(server, "/get_currencies"))
 }
 
 func TestClose(t *testing.T) {
 	assert := assert.New(t)
 
-	token := "test"
-	ctx := context.Background()
-	log := log.New(os.Stdout, "Splitwise LOG: ", log.Lshortfile)
-
-	conn := Open(token, ctx, log)
+	_, conn := newReferenceConnection(t)
 
 	executor := conn.GetCurecies()
 
-	assert.Equal(false, executor.isClose())
+	assert.Equal(false, isClosed(executor))
 	executor.Close()
-	assert.Equal(true, executor.isClose())
+	assert.Equal(true, isClosed(executor))
 }
 
 func TestGetCategory(t *testing.T) {
 	assert := assert.New(t)
 
-	token := "test"
-	ctx := context.Background()
-	log := log.New(os.Stdout, "Splitwise LOG: ", log.Lshortfile)
-
-	conn := Open(token, ctx, log)
-
-	category, err := conn.GetMainCategory(resources.Identifier(1))
-	assert.Equal(nil, err)
-	assert.Equal("Utilities", category.Name)
+	_, conn := newReferenceConnection(t)
 
+	category, err := conn.GetMainCategory(resources.Identifier(25))
+	if assert.NoError(err) {
+		assert.Equal("Food and drink", category.Name)
+		assert.Len(category.Subcategories, 2)
+	}
 }
 
 func TestGetCategoryNotFound(t *testing.T) {
-	token := "test"
-	ctx := context.Background()
-	log := log.New(os.Stdout, "Splitwise LOG: ", log.Lshortfile)
-
-	conn := Open(token, ctx, log)
+	_, conn := newReferenceConnection(t)
 
 	_, err := conn.GetMainCategory(resources.Identifier(0))
 	assert.EqualErrorf(t, err, (&ElementNotFound{}).Error(), "Error should be: %v, got: %v", (&ElementNotFound{}).Error(), err)
@@ -511,67 +536,54 @@ func TestGetCategoryNotFound(t *testing.T) {
 }
 
 func TestGetCategoryies(t *testing.T) {
-	token := "test"
-	ctx := context.Background()
-	log := log.New(os.Stdout, "Splitwise LOG: ", log.Lshortfile)
-
-	conn := Open(token, ctx, log)
+	_, conn := newReferenceConnection(t)
 
 	executor := conn.GetMainCategories()
 	cont := 0
-	want := 7
+	want := 2
 
 	for range executor.GetChan() {
 		cont++
 	}
 
-	assert.GreaterOrEqual(t, cont, want, "Get categories should return unless %d arg and got it %d", want, cont)
+	assert.NoError(t, executor.Err())
+	assert.Equal(t, want, cont, "Get categories should return %d categories and got %d", want, cont)
 }
 
 func TestGetCurrencies(t *testing.T) {
-	token := "test"
-	ctx := context.Background()
-	log := log.New(os.Stdout, "Splitwise LOG: ", log.Lshortfile)
-
-	conn := Open(token, ctx, log)
+	_, conn := newReferenceConnection(t)
 
 	executor := conn.GetCurecies()
 	cont := 0
-	want := 148
+	want := 3
 
 	for range executor.GetChan() {
 		cont++
 	}
 
-	assert.GreaterOrEqual(t, cont, want, "Get currencies should return unless %d currencies and got it %d", want, cont)
+	assert.NoError(t, executor.Err())
+	assert.Equal(t, want, cont, "Get currencies should return %d currencies and got %d", want, cont)
 }
 
 func TestGetCurrency(t *testing.T) {
 	assert := assert.New(t)
 
-	token := "test"
-	ctx := context.Background()
-	log := log.New(os.Stdout, "Splitwise LOG: ", log.Lshortfile)
-
-	conn := Open(token, ctx, log)
+	_, conn := newReferenceConnection(t)
 
 	currencyCode := "USD"
 	currencyUnit := "$"
 
 	currency, err := conn.GetCurency(currencyCode)
 
-	assert.NoError(err, "%s should be present as a currency code", currencyCode)
-	assert.Equal(currencyUnit, currency.Unit, "%s currency unit should be %s but got %s", currencyCode, currencyUnit, currency.Unit)
+	if assert.NoError(err, "%s should be present as a currency code", currencyCode) {
+		assert.Equal(currencyUnit, currency.Unit, "%s currency unit should be %s but got %s", currencyCode, currencyUnit, currency.Unit)
+	}
 }
 
 func TestGetCurrencyNotFund(t *testing.T) {
 	assert := assert.New(t)
 
-	token := "test"
-	ctx := context.Background()
-	log := log.New(os.Stdout, "Splitwise LOG: ", log.Lshortfile)
-
-	conn := Open(token, ctx, log)
+	_, conn := newReferenceConnection(t)
 
 	currencyCode := "US"
 
@@ -580,6 +592,21 @@ func TestGetCurrencyNotFund(t *testing.T) {
 	assert.EqualErrorf(err, (&ElementNotFound{}).Error(), "Error should be: %v, got: %v", (&ElementNotFound{}).Error(), err)
 }
 
+func TestReferenceLookupsReturnFetchErrors(t *testing.T) {
+	server, conn := newReferenceConnection(t)
+
+	fault := splitwisetest.ServerError()
+	fault.Path = "/get_currencies"
+	server.Inject(fault)
+
+	_, err := conn.GetCurency("USD")
+	assert.ErrorContains(t, err, "fetch currencies")
+	assert.False(t, errors.As(err, new(*ElementNotFound)))
+
+	_, err = conn.GetMainCategory(resources.Identifier(25))
+	assert.ErrorContains(t, err, "fetch currencies")
+}
+
 func TestGetFriends(t *testing.T) {
 	doFunc := func(r *http.Request) (*http.Response, error) {
 		resposne := http.Response{}
@@ -616,7 +643,6 @@ func TestGetFriends(t *testing.T) {
 }
 
 func TestCurrentUser(t *testing.T) {
-	assert.Nil(t, currentUser)
 	doFunc := func(r *http.Request) (*http.Response, error) {
 		resposne := http.Response{}
 		resposne.Body = io.NopCloser(strings.NewReader(testUser))
@@ -639,6 +665,7 @@ func TestCurrentUser(t *testing.T) {
 	}
 
 	conn := getClientMockedConnection(t, doFunc)
+	assert.Nil(t, conn.(*swConnectionStruct).currentUser)
 
 	user, err := conn.GetCurrentUser()
 
@@ -647,7 +674,7 @@ func TestCurrentUser(t *testing.T) {
 	}
 
 	assert.Equal(t, wantedRespounce.User, user)
-	assert.NotNil(t, currentUser)
+	assert.NotNil(t, conn.(*swConnectionStruct).currentUser)
 
 	user, err = conn.GetCurrentUser()
 
@@ -657,7 +684,6 @@ func TestCurrentUser(t *testing.T) {
 	assert.Equal(t, wantedRespounce.User, user)
 }
 func TestCurrentUserWhenUnathorized(t *testing.T) {
-	currentUser = nil
 	doFunc := func(r *http.Request) (*http.Response, error) {
 		resposne := http.Response{}
 		resposne.Body = io.NopCloser(strings.NewReader(unauthorized))
@@ -758,54 +784,10 @@ func TestGetGroup(t *testing.T) {
 
 }
 
-func getIntFromParams(url *url.URL, param string) int {
-	urlValues := url.Query()
-
-	tmps, ok := urlValues[param]
-
-	if !ok {
-		return 0
-	} else if len(tmps) > 0 {
-		rtr, err := strconv.Atoi(tmps[0])
-		if err != nil {
-			panic("unable to parse limit")
-		}
-		return rtr
-	}
-	return 0
-}
-
 func TestGetNotifications(t *testing.T) {
-	type responseStruct struct {
-		Notifications []resources.Notification
-	}
-	wantedRespounce := responseStruct{}
-	wantedRespounce.Notifications = make([]resources.Notification, 10)
-	err := json.Unmarshal([]byte(testNotifications), &wantedRespounce)
-
-	assert.NoError(t, err, "error Unmarshaling struct espected nil but received %s", err)
-
-	doFunc := func(r *http.Request) (*http.Response, error) {
-		resposne := http.Response{}
-
-		content, err := json.Marshal(wantedRespounce)
-		if err != nil {
-			panic("error converting parial strunt")
-		}
-		resposne.Body = io.NopCloser(bytes.NewReader(content))
-
-		resposne.Header = make(map[string][]string)
-		resposne.Header["Content-Type"] = []string{"application/json", "charset=utf-8"}
-		resposne.Status = "200"
-		resposne.StatusCode = 200
-		return &resposne, nil
-	}
-
-	conn := getClientMockedConnection(t, doFunc)
-
-	params := splitwise.NotificationsParams{}
+	conn, recorder := replayConnection(t, "notifications")
 
-	executor := conn.GetNotifications(params)
+	executor := conn.GetNotifications(splitwise.NotificationsParams{})
 
 	count := 0
 
@@ -813,40 +795,14 @@ func TestGetNotifications(t *testing.T) {
 		count++
 	}
 	assert.Equal(t, 3, count)
-	assert.True(t, executor.isClose())
+	assert.True(t, isClosed(executor))
+	assert.Empty(t, recorder.Unused())
 }
 
 func TestGetNotificationsWithClose(t *testing.T) {
-	type responseStruct struct {
-		Notifications []resources.Notification
-	}
-	wantedRespounce := responseStruct{}
-	wantedRespounce.Notifications = make([]resources.Notification, 10)
-	err := json.Unmarshal([]byte(testNotifications), &wantedRespounce)
-
-	assert.NoError(t, err, "error Unmarshaling struct espected nil but received %s", err)
-
-	doFunc := func(r *http.Request) (*http.Response, error) {
-		resposne := http.Response{}
-
-		content, err := json.Marshal(wantedRespounce)
-		if err != nil {
-			panic("error converting parial strunt")
-		}
-		resposne.Body = io.NopCloser(bytes.NewReader(content))
-
-		resposne.Header = make(map[string][]string)
-		resposne.Header["Content-Type"] = []string{"application/json", "charset=utf-8"}
-		resposne.Status = "200"
-		resposne.StatusCode = 200
-		return &resposne, nil
-	}
+	conn, _ := replayConnection(t, "notifications")
 
-	conn := getClientMockedConnection(t, doFunc)
-
-	params := splitwise.NotificationsParams{}
-
-	executor := conn.GetNotifications(params)
+	executor := conn.GetNotifications(splitwise.NotificationsParams{})
 
 	count := 0
 
@@ -855,87 +811,34 @@ func TestGetNotificationsWithClose(t *testing.T) {
 		executor.Close()
 	}
 	assert.Equal(t, 1, count)
-	assert.True(t, executor.isClose())
+	assert.True(t, isClosed(executor))
 }
 
 func TestGetExpences(t *testing.T) {
-	type responseStruct struct {
-		Expenses []resources.Expense
-	}
-	wantedRespounce := responseStruct{}
-	wantedRespounce.Expenses = make([]resources.Expense, 10)
-	err := json.Unmarshal([]byte(testExpences), &wantedRespounce)
-
-	assert.NoError(t, err, "error Unmarshaling struct espected nil but received %s", err)
-
-	doFunc := func(r *http.Request) (*http.Response, error) {
-		resposne := http.Response{}
-
-		limit := getIntFromParams(r.URL, "limit")
-		offset := getIntFromParams(r.URL, "offset")
-
-		if len(wantedRespounce.Expenses) > offset {
-			patial := responseStruct{}
-
-			patial.Expenses = wantedRespounce.Expenses[offset:int(math.Min(float64(offset+limit), float64(len(wantedRespounce.Expenses))))]
-			content, err := json.Marshal(patial)
-			if err != nil {
-				panic("error converting parial strunt")
-			}
-			resposne.Body = io.NopCloser(bytes.NewReader(content))
-		} else {
-			resposne.Body = io.NopCloser(bytes.NewReader([]byte{}))
-		}
-
-		resposne.Header = make(map[string][]string)
-		resposne.Header["Content-Type"] = []string{"application/json", "charset=utf-8"}
-		resposne.Status = "200"
-		resposne.StatusCode = 200
-		return &resposne, nil
-	}
-
-	assert.NoError(t, err, "error Unmarshaling struct espected nil but received %s", err)
-
-	conn := getClientMockedConnection(t, doFunc)
-
 	testCases := []struct {
 		Params        splitwise.ExpensesParams
 		ExpectedValus int
 		closeAfter    int
 	}{
 		{
-			Params: splitwise.ExpensesParams{
-				splitwise.ExpensesDatedAfter: time.Date(time.Now().Year(), 1, 1, 0, 0, 0, 0, time.Local),
-				splitwise.ExpensesGroupId:    "12345",
-				splitwise.ExpensesLimit:      5,
-			},
+			Params:        cassetteExpensesParams(5),
 			ExpectedValus: 10,
 		}, {
-			Params: splitwise.ExpensesParams{
-				splitwise.ExpensesDatedAfter: time.Date(time.Now().Year(), 1, 1, 0, 0, 0, 0, time.Local),
-				splitwise.ExpensesGroupId:    "12345",
-				splitwise.ExpensesLimit:      5,
-			},
+			Params:        cassetteExpensesParams(5),
 			ExpectedValus: 2,
 			closeAfter:    1,
 		}, {
-			Params: splitwise.ExpensesParams{
-				splitwise.ExpensesDatedAfter: time.Date(time.Now().Year(), 1, 1, 0, 0, 0, 0, time.Local),
-				splitwise.ExpensesGroupId:    "12345",
-				splitwise.ExpensesLimit:      4,
-			},
+			Params:        cassetteExpensesParams(4),
 			ExpectedValus: 10,
 		}, {
-			Params: splitwise.ExpensesParams{
-				splitwise.ExpensesDatedAfter: time.Date(time.Now().Year(), 1, 1, 0, 0, 0, 0, time.Local),
-				splitwise.ExpensesGroupId:    "12345",
-				splitwise.ExpensesLimit:      0,
-			},
-			ExpectedValus: 0,
+			// the API reads a zero limit as no limit
+			Params:        cassetteExpensesParams(0),
+			ExpectedValus: 10,
 		},
 	}
 
 	for _, params := range testCases {
+		conn, _ := replayConnection(t, "expenses")
 		executor := conn.GetExpenses(params.Params)
 
 		cont := 0
@@ -947,26 +850,15 @@ func TestGetExpences(t *testing.T) {
 			}
 		}
 		assert.Equal(t, params.ExpectedValus, cont)
-		assert.True(t, executor.isClose())
+		assert.True(t, isClosed(executor))
 	}
 }
 
 func TestGetExpencesWithPanic(t *testing.T) {
-	type responseStruct struct {
-		Expenses []resources.Expense
-	}
-	wantedRespounce := responseStruct{}
-	wantedRespounce.Expenses = make([]resources.Expense, 10)
-	err := json.Unmarshal([]byte(testExpences), &wantedRespounce)
-
-	assert.NoError(t, err, "error Unmarshaling struct espected nil but received %s", err)
-
 	doFunc := func(r *http.Request) (*http.Response, error) {
 		panic("painic produced in test")
 	}
 
-	assert.NoError(t, err, "error Unmarshaling struct espected nil but received %s", err)
-
 	conn := getClientMockedConnection(t, doFunc)
 
 	params := splitwise.ExpensesParams{}
@@ -982,28 +874,11 @@ func TestGetExpencesWithPanic(t *testing.T) {
 		count++
 	}
 	assert.Equal(t, 0, count)
-	assert.True(t, executor.isClose())
+	assert.True(t, isClosed(executor))
 }
 
 func TestGetgroups(t *testing.T) {
-	doFunc := func(r *http.Request) (*http.Response, error) {
-		resposne := http.Response{}
-		resposne.Body = io.NopCloser(strings.NewReader(testGroups))
-		resposne.Header = make(map[string][]string)
-		resposne.Header["Content-Type"] = []string{"application/json", "charset=utf-8"}
-		resposne.Status = "200"
-		resposne.StatusCode = 200
-		return &resposne, nil
-	}
-	type responseStruct struct {
-		Groups []resources.Group
-	}
-
-	wantedRespounce := responseStruct{}
-	wantedRespounce.Groups = make([]resources.Group, 10)
-	err := json.Unmarshal([]byte(testGroups), &wantedRespounce)
-	assert.NoError(t, err, "error Unmarshaling struct espected nil but received %s", err)
-	conn := getClientMockedConnection(t, doFunc)
+	conn, recorder := replayConnection(t, "groups")
 	executor := conn.GetGroups()
 
 	count := 0
@@ -1012,27 +887,11 @@ func TestGetgroups(t *testing.T) {
 		count++
 	}
 	assert.Equal(t, 7, count)
+	assert.Empty(t, recorder.Unused())
 }
 
 func TestGetgroupsWithClose(t *testing.T) {
-	doFunc := func(r *http.Request) (*http.Response, error) {
-		resposne := http.Response{}
-		resposne.Body = io.NopCloser(strings.NewReader(testGroups))
-		resposne.Header = make(map[string][]string)
-		resposne.Header["Content-Type"] = []string{"application/json", "charset=utf-8"}
-		resposne.Status = "200"
-		resposne.StatusCode = 200
-		return &resposne, nil
-	}
-	type responseStruct struct {
-		Groups []resources.Group
-	}
-
-	wantedRespounce := responseStruct{}
-	wantedRespounce.Groups = make([]resources.Group, 10)
-	err := json.Unmarshal([]byte(testGroups), &wantedRespounce)
-	assert.NoError(t, err, "error Unmarshaling struct espected nil but received %s", err)
-	conn := getClientMockedConnection(t, doFunc)
+	conn, _ := replayConnection(t, "groups")
 	executor := conn.GetGroups()
 
 	count := 0
@@ -1041,29 +900,90 @@ func TestGetgroupsWithClose(t *testing.T) {
 		count++
 		if count == 1 {
 			executor.Close()
-			assert.True(t, executor.isClose())
+			assert.True(t, isClosed(executor))
 		}
 	}
 	assert.Equal(t, 1, count)
 }
 
 func getClientMockedConnection(t *testing.T, doFunc func(r *http.Request) (*http.Response, error)) SwConnection {
-	client := Open("testtoken", context.Background(), log.New(os.Stdout, "Test Splitwise LOG: ", log.Lshortfile))
+	return OpenWithOptions(
+		WithToken("testtoken"),
+		WithHTTPClient(httpClientStub{DoFunc: doFunc}),
+		WithLogger(getTestLogger(t)),
+	)
+}
 
-	bareclient, ok := client.(*swConnectionStruct)
+func TestGetExpensesFromCassette(t *testing.T) {
+	conn, _ := replayConnection(t, "expenses")
 
-	if !ok {
-		panic("unable to convert interface to istance")
+	descriptions := []string{}
+	for e := range conn.GetExpenses(cassetteExpensesParams(4)).GetChan() {
+		descriptions = append(descriptions, e.Description)
 	}
 
-	cliststub := httpClientStub{
-		DoFunc: doFunc,
+	assert.Len(t, descriptions, 10)
+	assert.Equal(t, []string{"Jumbo", "Fiambre", "Jumbo", "Regalo Nati"}, descriptions[:4])
+}
+
+func TestGetExpensesWithFakeServer(t *testing.T) {
+	server := splitwisetest.NewServer()
+	defer server.Close()
+
+	me := server.CurrentUser()
+	ana := server.AddUser("Ana", "B", "ana@example.com")
+	home := server.AddGroup("Home", ana.ID)
+
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 10; i++ {
+		_, err := server.AddExpense(int(home.ID), fmt.Sprintf("Expense %d", i), "10", start.AddDate(0, 0, i),
+			splitwisetest.Share{UserID: me.ID, Paid: "10", Owed: "5"},
+			splitwisetest.Share{UserID: ana.ID, Paid: "0", Owed: "5"},
+		)
+		assert.NoError(t, err)
 	}
 
-	bareclient.client.HttpClient = cliststub
-	bareclient.client.Logger = testLogger{
-		T: t,
+	conn := OpenWithOptions(WithBaseURL(server.URL, ""), WithLogger(getTestLogger(t)))
+
+	testCases := []struct {
+		limit      int
+		closeAfter int
+		expected   int
+	}{
+		{limit: 4, expected: 10},
+		{limit: 5, expected: 10},
+		{limit: 3, closeAfter: 2, expected: 2},
 	}
 
-	return bareclient
+	for _, tc := range testCases {
+		params := splitwise.ExpensesParams{
+			splitwise.ExpensesGroupId: int(home.ID),
+			splitwise.ExpensesLimit:   tc.limit,
+		}
+
+		executor := conn.GetExpenses(params)
+		seen := map[resources.ExpenseID]bool{}
+		for e := range executor.GetChan() {
+			seen[e.ID] = true
+			if len(seen) == tc.closeAfter {
+				executor.Close()
+			}
+		}
+		assert.Len(t, seen, tc.expected, "limit %d", tc.limit)
+	}
+
+	deleted := 0
+	for e := range conn.GetExpenses(splitwise.ExpensesParams{splitwise.ExpensesLimit: 4}).GetChan() {
+		deleted = int(e.ID)
+		break
+	}
+	assert.NoError(t, conn.DeleteExpense(deleted))
+
+	stored, ok := server.Expense(deleted)
+	assert.True(t, ok)
+	assert.NotEmpty(t, stored.DeletedAt)
+
+	group, err := conn.GetGroup(int(home.ID))
+	assert.NoError(t, err)
+	assert.Equal(t, "45.00", group.SimplifiedDebts[0].Amount)
 }
@@ -0,0 +1,756 @@
+// Package splitwisetest provides an in-process fake of the Splitwise v3 API
+// for integration tests. The server keeps users, groups, friends, expenses
+// and notifications in memory, pages and filters expenses the way Splitwise
+// does and can inject faults.
+//
+// Point a connection at it with WithBaseURL(server.URL, "").
+package splitwisetest
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aanzolaavila/splitwise.go/resources"
+)
+
+const (
+	APIPath = "/api/v3.0"
+
+	DefaultExpensesLimit = 20
+)
+
+// Fault makes the server fail the matching requests. Status is written
+// after Delay; a zero Status only delays the request.
+type Fault struct {
+	// Path restricts the fault to the endpoints starting with it, such as
+	// "/get_expenses". Empty matches every request.
+	Path   string
+	Status int
+	Delay  time.Duration
+	// RetryAfter is sent with 429 responses.
+	RetryAfter string
+	// Times is how many requests fail; zero keeps failing until
+	// ClearFaults.
+	Times int
+}
+
+func Unauthorized() Fault {
+	return Fault{Status: http.StatusUnauthorized}
+}
+
+func RateLimited(retryAfter string) Fault {
+	return Fault{Status: http.StatusTooManyRequests, RetryAfter: retryAfter}
+}
+
+func ServerError() Fault {
+	return Fault{Status: http.StatusInternalServerError}
+}
+
+func Slow(delay time.Duration) Fault {
+	return Fault{Delay: delay}
+}
+
+type Server struct {
+	*httptest.Server
+
+	// Token, when set, must be sent as a Bearer token. Set it, like the
+	// fields below, before the first request.
+	Token string
+	// Currency of the expenses created through the server.
+	Currency string
+	// Now is the clock of the server, truncated to seconds like the
+	// Splitwise timestamps.
+	Now func() time.Time
+
+	mu            sync.Mutex
+	lastID        int
+	me            resources.UserID
+	users         map[resources.UserID]resources.User
+	userOrder     []resources.UserID
+	groups        map[int]*group
+	expenses      map[int]*expense
+	notifications []resources.Notification
+	comments      map[int][]resources.Comment
+	faults        []*Fault
+	requests      []string
+}
+
+// NewServer starts a server authenticated as a current user with no
+// friends, groups or expenses. Close it when done.
+func NewServer() *Server {
+	s := &Server{
+		Currency: "USD",
+		Now:      time.Now,
+		users:    map[resources.UserID]resources.User{},
+		groups:   map[int]*group{},
+		expenses: map[int]*expense{},
+		comments: map[int][]resources.Comment{},
+	}
+	s.me = s.addUser("Test", "User", "test@example.com").ID
+
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Inject adds a fault, checked in order before the others added later.
+func (s *Server) Inject(f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.faults = append(s.faults, &f)
+}
+
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.faults = nil
+}
+
+// Requests returns the requests served so far as "METHOD /path?query",
+// without the API path.
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string(nil), s.requests...)
+}
+
+func (s *Server) now() time.Time {
+	return s.Now().UTC().Truncate(time.Second)
+}
+
+type handler func(s *Server, r *http.Request, id int) (int, interface{})
+
+var routes = map[string]struct {
+	method string
+	withID bool
+	handle handler
+}{
+	"/get_current_user":  {http.MethodGet, false, (*Server).getCurrentUser},
+	"/get_groups":        {http.MethodGet, false, (*Server).getGroups},
+	"/get_group":         {http.MethodGet, true, (*Server).getGroup},
+	"/get_friends":       {http.MethodGet, false, (*Server).getFriends},
+	"/get_friend":        {http.MethodGet, true, (*Server).getFriend},
+	"/get_expenses":      {http.MethodGet, false, (*Server).getExpenses},
+	"/get_expense":       {http.MethodGet, true, (*Server).getExpense},
+	"/create_expense":    {http.MethodPost, false, (*Server).createExpense},
+	"/update_expense":    {http.MethodPost, true, (*Server).updateExpense},
+	"/delete_expense":    {http.MethodPost, true, (*Server).deleteExpense},
+	"/undelete_expense":  {http.MethodPost, true, (*Server).restoreExpense},
+	"/get_notifications": {http.MethodGet, false, (*Server).getNotifications},
+	"/get_comments":      {http.MethodGet, false, (*Server).getComments},
+	"/get_categories":    {http.MethodGet, false, (*Server).getCategories},
+	"/get_currencies":    {http.MethodGet, false, (*Server).getCurrencies},
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, APIPath)
+
+	s.mu.Lock()
+	request := r.Method + " " + path
+	if r.URL.RawQuery != "" {
+		request += "?" + r.URL.RawQuery
+	}
+	s.requests = append(s.requests, request)
+	fault := s.takeFault(path)
+	token := s.Token
+	s.mu.Unlock()
+
+	if fault != nil {
+		if fault.Delay > 0 {
+			select {
+			case <-time.After(fault.Delay):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		if fault.Status != 0 {
+			if fault.RetryAfter != "" {
+				w.Header().Set("Retry-After", fault.RetryAfter)
+			}
+			writeJSON(w, fault.Status, errorBody(fault.Status))
+			return
+		}
+	}
+
+	if token != "" && r.Header.Get("Authorization") != "Bearer "+token {
+		writeJSON(w, http.StatusUnauthorized, errorBody(http.StatusUnauthorized))
+		return
+	}
+
+	base, id := path, 0
+	if i := strings.LastIndex(path, "/"); i > 0 {
+		if n, err := strconv.Atoi(path[i+1:]); err == nil {
+			base, id = path[:i], n
+		}
+	}
+
+	route, ok := routes[base]
+	if !ok || route.withID != (base != path) {
+		writeJSON(w, http.StatusNotFound, errorBody(http.StatusNotFound))
+		return
+	}
+	if r.Method != route.method {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "Method not allowed"})
+		return
+	}
+
+	status, body := s.handle(route.handle, r, id)
+	writeJSON(w, status, body)
+}
+
+// handle runs h under the lock, released even if h panics.
+func (s *Server) handle(h handler, r *http.Request, id int) (int, interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return h(s, r, id)
+}
+
+func (s *Server) takeFault(path string) *Fault {
+	for i, f := range s.faults {
+		if !strings.HasPrefix(path, f.Path) {
+			continue
+		}
+
+		if f.Times > 0 {
+			f.Times--
+			if f.Times == 0 {
+				s.faults = append(s.faults[:i:i], s.faults[i+1:]...)
+			}
+		}
+		return f
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func errorBody(status int) interface{} {
+	switch status {
+	case http.StatusUnauthorized:
+		return map[string]string{"error": "Invalid API request: you are not logged in"}
+	case http.StatusNotFound:
+		return map[string]interface{}{"errors": map[string][]string{"base": {"Invalid API Request: record not found"}}}
+	case http.StatusTooManyRequests:
+		return map[string]string{"error": "Too many requests"}
+	default:
+		return map[string]interface{}{"errors": map[string][]string{"base": {http.StatusText(status)}}}
+	}
+}
+
+// failure is how Splitwise reports invalid writes: a 200 with the errors.
+func failure(container string, err error) (int, interface{}) {
+	body := map[string]interface{}{
+		"errors": map[string][]string{"base": {err.Error()}},
+	}
+	if container != "" {
+		body[container] = []interface{}{}
+	}
+	return http.StatusOK, body
+}
+
+func (s *Server) getCurrentUser(r *http.Request, id int) (int, interface{}) {
+	return http.StatusOK, map[string]interface{}{"user": s.users[s.me]}
+}
+
+func (s *Server) getGroups(r *http.Request, id int) (int, interface{}) {
+	ids := make([]int, 0, len(s.groups))
+	for gid := range s.groups {
+		ids = append(ids, gid)
+	}
+	sort.Ints(ids)
+
+	groups := []resources.Group{}
+	for _, gid := range ids {
+		groups = append(groups, s.renderGroup(s.groups[gid]))
+	}
+	return http.StatusOK, map[string]interface{}{"groups": groups}
+}
+
+func (s *Server) getGroup(r *http.Request, id int) (int, interface{}) {
+	g, ok := s.groups[id]
+	if !ok {
+		return http.StatusNotFound, errorBody(http.StatusNotFound)
+	}
+	return http.StatusOK, map[string]interface{}{"group": s.renderGroup(g)}
+}
+
+func (s *Server) getFriends(r *http.Request, id int) (int, interface{}) {
+	friends := []resources.Friend{}
+	for _, uid := range s.userOrder {
+		if uid != s.me {
+			friends = append(friends, s.renderFriend(uid))
+		}
+	}
+	return http.StatusOK, map[string]interface{}{"friends": friends}
+}
+
+func (s *Server) getFriend(r *http.Request, id int) (int, interface{}) {
+	if _, ok := s.users[resources.UserID(id)]; !ok || resources.UserID(id) == s.me {
+		return http.StatusNotFound, errorBody(http.StatusNotFound)
+	}
+	return http.StatusOK, map[string]interface{}{"friend": s.renderFriend(resources.UserID(id))}
+}
+
+// getExpenses returns the expenses newest first, the deleted ones included
+// as Splitwise does.
+func (s *Server) getExpenses(r *http.Request, id int) (int, interface{}) {
+	query := r.URL.Query()
+
+	filters := []func(e *expense) bool{}
+	if v := query.Get("group_id"); v != "" {
+		gid, _ := strconv.Atoi(v)
+		filters = append(filters, func(e *expense) bool { return int(e.GroupId) == gid })
+	}
+	if v := query.Get("friend_id"); v != "" {
+		fid, _ := strconv.ParseUint(v, 10, 64)
+		filters = append(filters, func(e *expense) bool {
+			for _, u := range e.Users {
+				if u.UserId == fid {
+					return true
+				}
+			}
+			return false
+		})
+	}
+	for _, p := range []struct {
+		param string
+		field func(e *expense) string
+		after bool
+	}{
+		{"dated_after", func(e *expense) string { return e.Date }, true},
+		{"dated_before", func(e *expense) string { return e.Date }, false},
+		{"updated_after", func(e *expense) string { return e.UpdatedAt }, true},
+		{"updated_before", func(e *expense) string { return e.UpdatedAt }, false},
+	} {
+		v := query.Get(p.param)
+		if v == "" {
+			continue
+		}
+		limit, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("Invalid %s", p.param)}
+		}
+
+		p := p
+		filters = append(filters, func(e *expense) bool {
+			t, err := time.Parse(time.RFC3339, p.field(e))
+			if err != nil {
+				return false
+			}
+			// the ranges are half open, so that a cursor set to the last
+			// update does not miss the ones in the same second
+			if p.after {
+				return !t.Before(limit)
+			}
+			return t.Before(limit)
+		})
+	}
+
+	list := []*expense{}
+	for _, e := range s.expenses {
+		keep := true
+		for _, f := range filters {
+			keep = keep && f(e)
+		}
+		if keep {
+			list = append(list, e)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Date != list[j].Date {
+			return list[i].Date > list[j].Date
+		}
+		return list[i].ID > list[j].ID
+	})
+
+	offset, limit := 0, DefaultExpensesLimit
+	for _, p := range []struct {
+		param string
+		value *int
+	}{{"offset", &offset}, {"limit", &limit}} {
+		v := query.Get(p.param)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("Invalid %s", p.param)}
+		}
+		*p.value = n
+	}
+
+	if offset > len(list) {
+		offset = len(list)
+	}
+	list = list[offset:]
+	// a zero limit returns every expense
+	if limit > 0 && limit < len(list) {
+		list = list[:limit]
+	}
+
+	expenses := make([]resources.Expense, 0, len(list))
+	for _, e := range list {
+		expenses = append(expenses, s.renderExpense(e))
+	}
+	return http.StatusOK, map[string]interface{}{"expenses": expenses}
+}
+
+func (s *Server) getExpense(r *http.Request, id int) (int, interface{}) {
+	e, ok := s.expenses[id]
+	if !ok {
+		return http.StatusNotFound, errorBody(http.StatusNotFound)
+	}
+	return http.StatusOK, map[string]interface{}{"expense": s.renderExpense(e)}
+}
+
+// expenseParams are the fields of create_expense and update_expense.
+type expenseParams map[string]interface{}
+
+func readParams(r *http.Request) (expenseParams, error) {
+	params := expenseParams{}
+
+	content, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	if len(content) > 0 && string(content) != "null" {
+		if err := json.Unmarshal(content, &params); err != nil {
+			return nil, err
+		}
+	}
+
+	for name, values := range r.URL.Query() {
+		if _, ok := params[name]; !ok && len(values) > 0 {
+			params[name] = values[0]
+		}
+	}
+	return params, nil
+}
+
+func (p expenseParams) has(name string) bool {
+	_, ok := p[name]
+	return ok
+}
+
+func (p expenseParams) str(name string) string {
+	switch v := p[name].(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		return ""
+	}
+}
+
+func (p expenseParams) number(name string) int {
+	n, _ := strconv.Atoi(p.str(name))
+	return n
+}
+
+// shares returns the users__N__ parameters, creating the users given only
+// by email.
+func (s *Server) shares(p expenseParams) ([]Share, error) {
+	shares := []Share{}
+	for i := 0; ; i++ {
+		prefix := fmt.Sprintf("users__%d__", i)
+		if !p.has(prefix+"user_id") && !p.has(prefix+"email") {
+			return shares, nil
+		}
+
+		id := resources.UserID(p.number(prefix + "user_id"))
+		if id == 0 {
+			email := p.str(prefix + "email")
+			for _, u := range s.users {
+				if strings.EqualFold(u.Email, email) {
+					id = u.ID
+				}
+			}
+			if id == 0 {
+				id = s.addUser(p.str(prefix+"first_name"), p.str(prefix+"last_name"), email).ID
+			}
+		}
+
+		shares = append(shares, Share{UserID: id, Paid: p.str(prefix + "paid_share"), Owed: p.str(prefix + "owed_share")})
+	}
+}
+
+func (s *Server) applyParams(e *expense, p expenseParams) {
+	if v := p.str("details"); v != "" {
+		e.Details = v
+	}
+	if v := p.str("currency_code"); v != "" {
+		e.CurrencyCode = v
+	}
+	if v := p.number("category_id"); v != 0 {
+		e.CategoryId = uint32(v)
+		e.Category.ID = resources.CategoryID(v)
+	}
+	if v := p.str("date"); v != "" {
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			e.Date = t.UTC().Format(time.RFC3339)
+		}
+	}
+	if p.has("payment") {
+		e.Payment = p.str("payment") == "true"
+	}
+}
+
+func (s *Server) createExpense(r *http.Request, id int) (int, interface{}) {
+	p, err := readParams(r)
+	if err != nil {
+		return http.StatusBadRequest, map[string]string{"error": err.Error()}
+	}
+
+	groupID := p.number("group_id")
+	g, err := s.checkGroup(groupID)
+	if err != nil {
+		return failure("expenses", err)
+	}
+
+	var shares []Share
+	if p.str("split_equally") == "true" {
+		if g == nil {
+			return failure("expenses", fmt.Errorf("An expense split equally needs a group with members"))
+		}
+		shares, err = equalShares(p.str("cost"), g.members)
+	} else {
+		shares, err = s.shares(p)
+	}
+	if err != nil {
+		return failure("expenses", err)
+	}
+
+	e, err := s.newExpense(groupID, p.str("description"), p.str("cost"), s.now().Format(time.RFC3339), shares)
+	if err != nil {
+		return failure("expenses", err)
+	}
+	e.equal = p.str("split_equally") == "true"
+	s.applyParams(e, p)
+
+	s.notify(e, 0, "added")
+	return http.StatusOK, map[string]interface{}{"expenses": []resources.Expense{s.renderExpense(e)}}
+}
+
+func (s *Server) updateExpense(r *http.Request, id int) (int, interface{}) {
+	e, ok := s.expenses[id]
+	if !ok {
+		return http.StatusNotFound, errorBody(http.StatusNotFound)
+	}
+
+	p, err := readParams(r)
+	if err != nil {
+		return http.StatusBadRequest, map[string]string{"error": err.Error()}
+	}
+
+	updated := *e
+	updated.Users = append([]expenseShare(nil), e.Users...)
+
+	if p.has("group_id") {
+		if _, err := s.checkGroup(p.number("group_id")); err != nil {
+			return failure("expenses", err)
+		}
+		updated.GroupId = uint32(p.number("group_id"))
+	}
+	if v := p.str("description"); v != "" {
+		updated.Description = v
+	}
+
+	cost := updated.Cost
+	if v := p.str("cost"); v != "" {
+		cost = v
+	}
+
+	shares, err := s.shares(p)
+	if err != nil {
+		return failure("expenses", err)
+	}
+	if len(shares) == 0 {
+		// without new shares an equal split stays equal and the others
+		// must still add up to the cost
+		ids := []resources.UserID{}
+		for _, u := range updated.Users {
+			ids = append(ids, resources.UserID(u.UserId))
+			shares = append(shares, Share{UserID: resources.UserID(u.UserId), Paid: u.PaidShare, Owed: u.OwedShare})
+		}
+		if updated.equal {
+			shares, err = equalShares(cost, ids)
+			if err != nil {
+				return failure("expenses", err)
+			}
+		}
+	} else {
+		updated.equal = false
+	}
+
+	if err := s.setShares(&updated, cost, shares); err != nil {
+		return failure("expenses", err)
+	}
+	s.applyParams(&updated, p)
+
+	updated.UpdatedAt = s.now().Format(time.RFC3339)
+	updated.UpdatedBy = s.users[s.me]
+	*e = updated
+
+	s.notify(e, 1, "updated")
+	return http.StatusOK, map[string]interface{}{"expenses": []resources.Expense{s.renderExpense(e)}}
+}
+
+func (s *Server) deleteExpense(r *http.Request, id int) (int, interface{}) {
+	e, ok := s.expenses[id]
+	if !ok {
+		return http.StatusNotFound, errorBody(http.StatusNotFound)
+	}
+	if e.DeletedAt != "" {
+		return failure("", fmt.Errorf("Expense %d is already deleted", id))
+	}
+
+	now := s.now().Format(time.RFC3339)
+	e.DeletedAt = now
+	e.DeletedBy = s.users[s.me]
+	e.UpdatedAt = now
+
+	s.notify(e, 2, "deleted")
+	return http.StatusOK, map[string]interface{}{"success": true}
+}
+
+func (s *Server) restoreExpense(r *http.Request, id int) (int, interface{}) {
+	e, ok := s.expenses[id]
+	if !ok {
+		return http.StatusNotFound, errorBody(http.StatusNotFound)
+	}
+	if e.DeletedAt == "" {
+		return failure("", fmt.Errorf("Expense %d is not deleted", id))
+	}
+
+	e.DeletedAt = ""
+	e.DeletedBy = resources.User{}
+	e.UpdatedAt = s.now().Format(time.RFC3339)
+
+	s.notify(e, 13, "restored")
+	return http.StatusOK, map[string]interface{}{"success": true}
+}
+
+// notify records the notification Splitwise shows the current user for a
+// write on e, in English.
+func (s *Server) notify(e *expense, kind int, verb string) {
+	s.lastID++
+
+	content := fmt.Sprintf("<strong>You</strong> %s <strong>“%s”</strong>", verb, html.EscapeString(e.Description))
+	if g, ok := s.groups[int(e.GroupId)]; ok {
+		content += fmt.Sprintf(" in <strong>“%s”</strong>", html.EscapeString(g.name))
+	}
+	content += "."
+
+	if e.DeletedAt == "" {
+		net := netShares(e)[s.me]
+		switch {
+		case net > 0:
+			content += fmt.Sprintf(`<br><font color="#5bc5a7">You get back %s %s</font>`, e.CurrencyCode, formatCents(net))
+		case net < 0:
+			content += fmt.Sprintf(`<br><font color="#ff652f">You owe %s %s</font>`, e.CurrencyCode, formatCents(-net))
+		default:
+			content += `<br><font color="#999999">You do not owe anything</font>`
+		}
+	}
+
+	n := resources.Notification{
+		ID:         resources.NotificationID(s.lastID),
+		Type:       kind,
+		CreatedAt:  s.now(),
+		CreatedBy:  int(s.me),
+		ImageShape: "square",
+		Content:    content,
+	}
+	n.Source.ID = resources.Identifier(e.ID)
+	n.Source.Type = "Expense"
+
+	s.notifications = append(s.notifications, n)
+}
+
+func (s *Server) listNotifications(after time.Time, limit int) []resources.Notification {
+	list := []resources.Notification{}
+	for i := len(s.notifications) - 1; i >= 0; i-- {
+		n := s.notifications[i]
+		if n.CreatedAt.Before(after) {
+			continue
+		}
+		list = append(list, n)
+		if limit > 0 && len(list) == limit {
+			break
+		}
+	}
+	return list
+}
+
+// getNotifications reads its parameters from the JSON body, where the
+// client sends them, or from the query.
+func (s *Server) getNotifications(r *http.Request, id int) (int, interface{}) {
+	p, err := readParams(r)
+	if err != nil {
+		return http.StatusBadRequest, map[string]string{"error": err.Error()}
+	}
+
+	var after time.Time
+	if v := p.str("updated_after"); v != "" {
+		after, err = time.Parse(time.RFC3339, v)
+		if err != nil {
+			return http.StatusBadRequest, map[string]string{"error": "Invalid updated_after"}
+		}
+	}
+
+	return http.StatusOK, map[string]interface{}{"notifications": s.listNotifications(after, p.number("limit"))}
+}
+
+func (s *Server) getComments(r *http.Request, id int) (int, interface{}) {
+	expenseID := atoi(r.URL.Query().Get("expense_id"))
+	if _, ok := s.expenses[expenseID]; !ok {
+		return http.StatusNotFound, errorBody(http.StatusNotFound)
+	}
+	return http.StatusOK, map[string]interface{}{"comments": append([]resources.Comment{}, s.comments[expenseID]...)}
+}
+
+func (s *Server) getCategories(r *http.Request, id int) (int, interface{}) {
+	general := resources.MainCategory{}
+	general.ID = 2
+	general.Name = "Uncategorized"
+	general.Subcategories = []resources.Category{{ID: 18, Name: "General"}}
+
+	food := resources.MainCategory{}
+	food.ID = 25
+	food.Name = "Food and drink"
+	food.Subcategories = []resources.Category{{ID: 12, Name: "Groceries"}, {ID: 13, Name: "Dining out"}}
+
+	return http.StatusOK, map[string]interface{}{"categories": []resources.MainCategory{general, food}}
+}
+
+func (s *Server) getCurrencies(r *http.Request, id int) (int, interface{}) {
+	return http.StatusOK, map[string]interface{}{"currencies": []resources.Currency{
+		{CurrencyCode: "ARS", Unit: "$"},
+		{CurrencyCode: "EUR", Unit: "€"},
+		{CurrencyCode: "USD", Unit: "$"},
+	}}
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
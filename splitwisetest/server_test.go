@@ -0,0 +1,234 @@
+package splitwisetest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aanzolaavila/splitwise.go"
+	"github.com/aanzolaavila/splitwise.go/resources"
+	"github.com/dcerbino-golib/smartsplitwise"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestServer(t *testing.T) (*Server, smartsplitwise.SwConnection) {
+	server := NewServer()
+	server.Token = "testtoken"
+	t.Cleanup(server.Close)
+
+	conn := smartsplitwise.OpenWithOptions(
+		smartsplitwise.WithToken("testtoken"),
+		smartsplitwise.WithBaseURL(server.URL, ""),
+	)
+	return server, conn
+}
+
+func TestGroupsAndFriends(t *testing.T) {
+	server, conn := newTestServer(t)
+	me := server.CurrentUser()
+	ana := server.AddUser("Ana", "B", "ana@example.com")
+	bob := server.AddUser("Bob", "C", "bob@example.com")
+	home := server.AddGroup("Home", ana.ID, bob.ID)
+
+	_, err := server.AddExpense(int(home.ID), "Groceries", "90", time.Now(),
+		Share{UserID: me.ID, Paid: "90", Owed: "30"},
+		Share{UserID: ana.ID, Paid: "0", Owed: "30"},
+		Share{UserID: bob.ID, Paid: "0", Owed: "30"},
+	)
+	assert.NoError(t, err)
+
+	_, err = server.AddExpense(int(home.ID), "Wrong", "90", time.Now(), Share{UserID: me.ID, Paid: "80", Owed: "90"})
+	assert.Error(t, err)
+
+	group, err := conn.GetGroup(int(home.ID))
+	assert.NoError(t, err)
+	assert.Equal(t, "Home", group.Name)
+	assert.Len(t, group.Members, 3)
+	assert.Equal(t, []resources.Debt{
+		{CurrencyCode: "USD", From: int(ana.ID), To: int(me.ID), Amount: "30.00"},
+		{CurrencyCode: "USD", From: int(bob.ID), To: int(me.ID), Amount: "30.00"},
+	}, group.SimplifiedDebts)
+
+	friend, err := conn.GetFriend(int(ana.ID))
+	assert.NoError(t, err)
+	assert.Equal(t, "30.00", friend.Balance[0].Amount)
+	assert.Equal(t, int(home.ID), friend.Groups[0].GroupId)
+
+	count := 0
+	for range conn.GetFriends().GetChan() {
+		count++
+	}
+	assert.Equal(t, 2, count)
+
+	user, err := conn.GetCurrentUser()
+	assert.NoError(t, err)
+	assert.Equal(t, me.ID, user.ID)
+
+	_, err = conn.GetGroup(9999)
+	assert.ErrorIs(t, err, splitwise.ErrNotFound)
+}
+
+func TestExpensesPagingAndFilters(t *testing.T) {
+	server, conn := newTestServer(t)
+	me := server.CurrentUser()
+	ana := server.AddUser("Ana", "B", "ana@example.com")
+	home := server.AddGroup("Home", ana.ID)
+
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 7; i++ {
+		_, err := server.AddExpense(int(home.ID), "Expense", "10", start.AddDate(0, 0, i),
+			Share{UserID: me.ID, Paid: "10", Owed: "5"},
+			Share{UserID: ana.ID, Paid: "0", Owed: "5"},
+		)
+		assert.NoError(t, err)
+	}
+
+	dates := []string{}
+	params := splitwise.ExpensesParams{splitwise.ExpensesGroupId: int(home.ID), splitwise.ExpensesLimit: 3}
+	for e := range conn.GetExpenses(params).GetChan() {
+		dates = append(dates, e.Date)
+	}
+	assert.Len(t, dates, 7)
+	assert.Equal(t, "2023-01-07T00:00:00Z", dates[0])
+	// three full pages, a partial one and the empty one that ends the loop
+	assert.Len(t, server.Requests(), 4)
+
+	count := 0
+	params = splitwise.ExpensesParams{
+		splitwise.ExpensesDatedAfter:  start.AddDate(0, 0, 2),
+		splitwise.ExpensesDatedBefore: start.AddDate(0, 0, 5),
+	}
+	for range conn.GetExpenses(params).GetChan() {
+		count++
+	}
+	assert.Equal(t, 3, count)
+}
+
+func TestWritesAndNotifications(t *testing.T) {
+	server, conn := newTestServer(t)
+	clock := time.Date(2023, 3, 1, 10, 0, 0, 0, time.UTC)
+	server.Now = func() time.Time { return clock }
+
+	ana := server.AddUser("Ana", "B", "ana@example.com")
+	home := server.AddGroup("Home", ana.ID)
+
+	created, err := conn.CreateExpenseEqualGroupSplit(10.01, "Pizza", int(home.ID), nil)
+	assert.NoError(t, err)
+	assert.Len(t, created, 1)
+	assert.Equal(t, "10.01", created[0].Cost)
+	assert.Equal(t, "5.01", created[0].Users[0].OwedShare)
+	assert.Equal(t, "5.00", created[0].Users[1].OwedShare)
+
+	id := int(created[0].ID)
+	clock = clock.Add(time.Minute)
+
+	updated, err := conn.UpdateExpense(id, 20, "Pizza and beer", int(home.ID), nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "Pizza and beer", updated[0].Description)
+	assert.Equal(t, "10.00", updated[0].Users[1].OwedShare)
+
+	_, err = conn.UpdateExpense(id, 20, "Pizza", int(home.ID), nil, []splitwise.ExpenseUser{{Id: ana.ID, PaidShare: 20, OwedShare: 10}})
+	assert.ErrorIs(t, err, splitwise.ErrUnsuccessful)
+
+	clock = clock.Add(time.Minute)
+	assert.NoError(t, conn.DeleteExpense(id))
+	assert.Error(t, conn.DeleteExpense(id))
+	assert.NoError(t, conn.RestoreExpense(id))
+
+	stored, ok := server.Expense(id)
+	assert.True(t, ok)
+	assert.Empty(t, stored.DeletedAt)
+
+	types := []int{}
+	for n := range conn.GetNotifications(splitwise.NotificationsParams{}).GetChan() {
+		types = append(types, n.Type)
+	}
+	assert.Equal(t, []int{13, 2, 1, 0}, types)
+
+	event := smartsplitwise.ParseNotification(server.Notifications()[3])
+	assert.Equal(t, smartsplitwise.ExpenseAdded, event.Type)
+	assert.Equal(t, "Pizza", event.Subject)
+	assert.Equal(t, "Home", event.GroupName)
+	assert.Equal(t, smartsplitwise.DirectionYouAreOwed, event.Direction)
+
+	count := 0
+	params := splitwise.NotificationsParams{splitwise.NotificationsUpdatedAfter: clock}
+	for range conn.GetNotifications(params).GetChan() {
+		count++
+	}
+	assert.Equal(t, 2, count)
+
+	count = 0
+	for range conn.GetExpenses(splitwise.ExpensesParams{splitwise.ExpensesUpdatedAfter: clock}).GetChan() {
+		count++
+	}
+	assert.Equal(t, 1, count)
+}
+
+func TestFaults(t *testing.T) {
+	server, conn := newTestServer(t)
+
+	server.Inject(Unauthorized())
+	_, err := conn.GetCurrentUser()
+	assert.ErrorIs(t, err, splitwise.ErrNotLoggedIn)
+	server.ClearFaults()
+
+	server.Inject(Fault{Path: "/get_group", Status: http.StatusInternalServerError, Times: 1})
+	_, err = conn.GetGroup(1)
+	assert.ErrorIs(t, err, splitwise.ErrSplitwiseServer)
+	_, err = conn.GetGroup(1)
+	assert.ErrorIs(t, err, splitwise.ErrNotFound)
+
+	retrying := smartsplitwise.OpenWithOptions(
+		smartsplitwise.WithToken("testtoken"),
+		smartsplitwise.WithBaseURL(server.URL, ""),
+		smartsplitwise.WithRetry(smartsplitwise.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}),
+	)
+	rateLimited := RateLimited("0")
+	rateLimited.Times = 2
+	server.Inject(rateLimited)
+	_, err = retrying.GetCurrentUser()
+	assert.NoError(t, err)
+
+	slow := Slow(200 * time.Millisecond)
+	slow.Times = 1
+	server.Inject(slow)
+	impatient := smartsplitwise.OpenWithOptions(
+		smartsplitwise.WithToken("testtoken"),
+		smartsplitwise.WithBaseURL(server.URL, ""),
+		smartsplitwise.WithTimeout(20*time.Millisecond),
+	)
+	_, err = impatient.GetCurrentUser()
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+
+	stranger := smartsplitwise.OpenWithOptions(
+		smartsplitwise.WithToken("other"),
+		smartsplitwise.WithBaseURL(server.URL, ""),
+	)
+	_, err = stranger.GetGroup(1)
+	assert.ErrorIs(t, err, splitwise.ErrNotLoggedIn)
+}
+
+func TestExpensesRejectInvalidPaging(t *testing.T) {
+	server, _ := newTestServer(t)
+
+	get := func(query string) int {
+		req, err := http.NewRequest(http.MethodGet, server.URL+APIPath+"/get_expenses?"+query, nil)
+		assert.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer testtoken")
+
+		res, err := http.DefaultClient.Do(req)
+		if !assert.NoError(t, err) {
+			return 0
+		}
+		res.Body.Close()
+		return res.StatusCode
+	}
+
+	for _, query := range []string{"offset=-5", "limit=-1", "offset=abc"} {
+		assert.Equal(t, http.StatusBadRequest, get(query), query)
+	}
+	assert.Equal(t, http.StatusOK, get("offset=3&limit=2"))
+}
@@ -0,0 +1,644 @@
+package splitwisetest
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aanzolaavila/splitwise.go/resources"
+	"github.com/dcerbino-golib/smartsplitwise/backup"
+)
+
+// expenseShare is the element type of resources.Expense.Users.
+type expenseShare = struct {
+	resources.User
+	UserId     uint64 `json:"user_id"`
+	PaidShare  string `json:"paid_share"`
+	OwedShare  string `json:"owed_share"`
+	NetBalance string `json:"net_balance"`
+}
+
+type repayment = struct {
+	From   uint32 `json:"from"`
+	To     uint32 `json:"to"`
+	Amount string `json:"amount"`
+}
+
+type balance = struct {
+	Amount       string `json:"amount"`
+	CurrencyCode string `json:"currency_code"`
+}
+
+// friendBalance is the element type of resources.Friend.Balance, which
+// orders the fields the other way around.
+type friendBalance = struct {
+	CurrencyCode string `json:"currency_code"`
+	Amount       string `json:"amount"`
+}
+
+// Share is what a user paid and owes on an expense, as decimal strings.
+type Share struct {
+	UserID resources.UserID
+	Paid   string
+	Owed   string
+}
+
+type group struct {
+	id      int
+	name    string
+	members []resources.UserID
+	created time.Time
+}
+
+type expense struct {
+	resources.Expense
+	equal bool
+}
+
+// AddUser adds a user, who is also a friend of the current user.
+func (s *Server) AddUser(firstName, lastName, email string) resources.User {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.addUser(firstName, lastName, email)
+}
+
+func (s *Server) addUser(firstName, lastName, email string) resources.User {
+	s.lastID++
+	user := resources.User{
+		ID:                 resources.UserID(s.lastID),
+		FirstName:          firstName,
+		LastName:           lastName,
+		Email:              email,
+		RegistrationStatus: "confirmed",
+		DefaultCurrency:    s.Currency,
+		Locale:             "en",
+	}
+	s.users[user.ID] = user
+	s.userOrder = append(s.userOrder, user.ID)
+	return user
+}
+
+// CurrentUser returns the user the server is authenticated as.
+func (s *Server) CurrentUser() resources.User {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.users[s.me]
+}
+
+// AddGroup adds a group of the current user and members.
+func (s *Server) AddGroup(name string, members ...resources.UserID) resources.Group {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastID++
+	g := &group{id: s.lastID, name: name, members: []resources.UserID{s.me}, created: s.now()}
+	for _, m := range members {
+		if m != s.me {
+			g.members = append(g.members, m)
+		}
+	}
+	s.groups[g.id] = g
+	return s.renderGroup(g)
+}
+
+// AddExpense adds an expense without generating a notification, to seed
+// the server. The shares must add up to cost.
+func (s *Server) AddExpense(groupID int, description string, cost string, date time.Time, shares ...Share) (resources.Expense, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, err := s.newExpense(groupID, description, cost, date.UTC().Format(time.RFC3339), shares)
+	if err != nil {
+		return resources.Expense{}, err
+	}
+	return s.renderExpense(e), nil
+}
+
+// Expense returns the stored state of an expense, deleted or not.
+func (s *Server) Expense(id int) (resources.Expense, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.expenses[id]
+	if !ok {
+		return resources.Expense{}, false
+	}
+	return s.renderExpense(e), true
+}
+
+// Notifications returns the notifications generated by the writes so far,
+// newest first.
+func (s *Server) Notifications() []resources.Notification {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.listNotifications(time.Time{}, 0)
+}
+
+// AddComment adds a comment of the current user on an expense.
+func (s *Server) AddComment(expenseID int, content string) (resources.Comment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.expenses[expenseID]
+	if !ok {
+		return resources.Comment{}, fmt.Errorf("expense %d does not exist", expenseID)
+	}
+
+	s.lastID++
+	c := resources.Comment{
+		ID:           resources.CommentID(s.lastID),
+		Content:      content,
+		CommentType:  "User",
+		RelationType: "ExpenseComment",
+		RelationID:   resources.Identifier(expenseID),
+		CreatedAt:    s.now(),
+		User:         s.users[s.me],
+	}
+	s.comments[expenseID] = append(s.comments[expenseID], c)
+	e.CommentsCount++
+	return c, nil
+}
+
+// LoadArchive replaces the state of the server with the one of a backup
+// archive, authenticating as its current user. Expenses keep their IDs,
+// shares and repayments; the group members, shares and friends become
+// users.
+func (s *Server) LoadArchive(a *backup.Archive) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastID = 0
+	s.users = map[resources.UserID]resources.User{}
+	s.userOrder = nil
+	s.groups = map[int]*group{}
+	s.expenses = map[int]*expense{}
+	s.notifications = nil
+	s.comments = map[int][]resources.Comment{}
+
+	addUser := func(user resources.User) {
+		if _, ok := s.users[user.ID]; !ok {
+			user.Balance = nil
+			s.users[user.ID] = user
+		}
+		s.lastID = max(s.lastID, int(user.ID))
+	}
+
+	s.me = a.CurrentUser.ID
+	addUser(a.CurrentUser)
+	s.userOrder = append(s.userOrder, s.me)
+
+	for _, g := range a.Groups {
+		// Splitwise lists the expenses outside of groups as group 0
+		if g.ID == 0 {
+			continue
+		}
+		loaded := &group{id: int(g.ID), name: g.Name, created: g.CreatedAt}
+		for _, m := range g.Members {
+			addUser(m)
+			loaded.members = append(loaded.members, m.ID)
+		}
+		s.groups[loaded.id] = loaded
+		s.lastID = max(s.lastID, loaded.id)
+	}
+
+	for _, e := range a.Expenses {
+		for _, u := range e.Users {
+			user := u.User
+			user.ID = resources.UserID(u.UserId)
+			addUser(user)
+		}
+		s.expenses[int(e.ID)] = &expense{Expense: e}
+		s.lastID = max(s.lastID, int(e.ID))
+	}
+
+	// friends carry fewer fields than the members and shares, so they only
+	// fill in the users seen nowhere else
+	for _, f := range a.Friends {
+		addUser(resources.User{
+			ID:                 resources.UserID(f.ID),
+			FirstName:          f.FirstName,
+			LastName:           f.LastName,
+			Email:              f.Email,
+			RegistrationStatus: f.RegistrationStatus,
+		})
+		s.userOrder = append(s.userOrder, resources.UserID(f.ID))
+	}
+
+	for _, n := range a.Notifications {
+		s.notifications = append(s.notifications, n)
+		s.lastID = max(s.lastID, int(n.ID))
+	}
+	// the server keeps them oldest first
+	sort.Slice(s.notifications, func(i, j int) bool {
+		ni, nj := s.notifications[i], s.notifications[j]
+		if !ni.CreatedAt.Equal(nj.CreatedAt) {
+			return ni.CreatedAt.Before(nj.CreatedAt)
+		}
+		return ni.ID < nj.ID
+	})
+
+	for expenseID, comments := range a.Comments {
+		s.comments[expenseID] = append([]resources.Comment(nil), comments...)
+		for _, c := range comments {
+			s.lastID = max(s.lastID, int(c.ID))
+		}
+	}
+}
+
+func (s *Server) newExpense(groupID int, description string, cost string, date string, shares []Share) (*expense, error) {
+	if _, err := s.checkGroup(groupID); err != nil {
+		return nil, err
+	}
+
+	s.lastID++
+	now := s.now().Format(time.RFC3339)
+
+	e := &expense{}
+	e.ID = resources.ExpenseID(s.lastID)
+	e.GroupId = uint32(groupID)
+	e.Description = description
+	e.CurrencyCode = s.Currency
+	e.Date = date
+	e.CreatedAt = now
+	e.UpdatedAt = now
+	e.CreatedBy = s.users[s.me]
+
+	if err := s.setShares(e, cost, shares); err != nil {
+		s.lastID--
+		return nil, err
+	}
+
+	s.expenses[int(e.ID)] = e
+	return e, nil
+}
+
+func (s *Server) checkGroup(groupID int) (*group, error) {
+	if groupID == 0 {
+		return nil, nil
+	}
+
+	g, ok := s.groups[groupID]
+	if !ok {
+		return nil, fmt.Errorf("group %d does not exist", groupID)
+	}
+	return g, nil
+}
+
+// setShares validates and stores the cost and shares of e.
+func (s *Server) setShares(e *expense, cost string, shares []Share) error {
+	total, err := parseCents(cost)
+	if err != nil || total <= 0 {
+		return errors.New("Cost must be a positive number")
+	}
+
+	var paid, owed int64
+	users := []expenseShare{}
+	for _, share := range shares {
+		user, ok := s.users[share.UserID]
+		if !ok {
+			return fmt.Errorf("user %d does not exist", share.UserID)
+		}
+
+		p, err := parseCents(share.Paid)
+		if err != nil {
+			return fmt.Errorf("invalid paid share %q", share.Paid)
+		}
+		o, err := parseCents(share.Owed)
+		if err != nil {
+			return fmt.Errorf("invalid owed share %q", share.Owed)
+		}
+		paid += p
+		owed += o
+
+		users = append(users, expenseShare{
+			User:       user,
+			UserId:     uint64(user.ID),
+			PaidShare:  formatCents(p),
+			OwedShare:  formatCents(o),
+			NetBalance: formatCents(p - o),
+		})
+	}
+
+	if paid != total {
+		return errors.New("The total of everyone's paid shares is not equal to the total cost")
+	}
+	if owed != total {
+		return errors.New("The total of everyone's owed shares is not equal to the total cost")
+	}
+
+	e.Cost = formatCents(total)
+	e.Users = users
+	e.Repayments = nil
+	for _, r := range settle(netShares(e)) {
+		e.Repayments = append(e.Repayments, repayment{From: uint32(r.from), To: uint32(r.to), Amount: formatCents(r.amount)})
+	}
+	return nil
+}
+
+// equalShares splits cost equally between users, the first one paying it
+// all. The cents left go to the first users.
+func equalShares(cost string, users []resources.UserID) ([]Share, error) {
+	total, err := parseCents(cost)
+	if err != nil || total <= 0 {
+		return nil, errors.New("Cost must be a positive number")
+	}
+	if len(users) == 0 {
+		return nil, errors.New("An expense split equally needs a group with members")
+	}
+
+	each := total / int64(len(users))
+	rest := total - each*int64(len(users))
+
+	shares := make([]Share, len(users))
+	for i, id := range users {
+		owed := each
+		if int64(i) < rest {
+			owed++
+		}
+		shares[i] = Share{UserID: id, Paid: "0", Owed: formatCents(owed)}
+	}
+	shares[0].Paid = formatCents(total)
+	return shares, nil
+}
+
+type debt struct {
+	from, to resources.UserID
+	amount   int64
+}
+
+func netShares(e *expense) map[resources.UserID]int64 {
+	net := map[resources.UserID]int64{}
+	for _, u := range e.Users {
+		p, _ := parseCents(u.PaidShare)
+		o, _ := parseCents(u.OwedShare)
+		net[resources.UserID(u.UserId)] += p - o
+	}
+	return net
+}
+
+// settle matches debtors and creditors greedily, in user ID order so that
+// the result is stable.
+func settle(net map[resources.UserID]int64) []debt {
+	var creditors, debtors []resources.UserID
+	for id, amount := range net {
+		if amount > 0 {
+			creditors = append(creditors, id)
+		} else if amount < 0 {
+			debtors = append(debtors, id)
+		}
+	}
+	sort.Slice(creditors, func(i, j int) bool { return creditors[i] < creditors[j] })
+	sort.Slice(debtors, func(i, j int) bool { return debtors[i] < debtors[j] })
+
+	rest := map[resources.UserID]int64{}
+	for id, amount := range net {
+		rest[id] = amount
+	}
+
+	debts := []debt{}
+	c := 0
+	for _, d := range debtors {
+		for rest[d] < 0 && c < len(creditors) {
+			amount := min(-rest[d], rest[creditors[c]])
+			debts = append(debts, debt{from: d, to: creditors[c], amount: amount})
+			rest[d] += amount
+			rest[creditors[c]] -= amount
+			if rest[creditors[c]] == 0 {
+				c++
+			}
+		}
+	}
+	return debts
+}
+
+func (s *Server) activeExpenses(filter func(e *expense) bool) []*expense {
+	list := []*expense{}
+	for _, e := range s.expenses {
+		if e.DeletedAt == "" && filter(e) {
+			list = append(list, e)
+		}
+	}
+	return list
+}
+
+// balances returns, by currency, what other owes the current user over
+// expenses, negative when the current user owes.
+func (s *Server) balances(other resources.UserID, expenses []*expense) map[string]int64 {
+	result := map[string]int64{}
+	for _, e := range expenses {
+		for _, r := range e.Repayments {
+			amount, _ := parseCents(r.Amount)
+			switch {
+			case resources.UserID(r.From) == other && resources.UserID(r.To) == s.me:
+				result[e.CurrencyCode] += amount
+			case resources.UserID(r.From) == s.me && resources.UserID(r.To) == other:
+				result[e.CurrencyCode] -= amount
+			}
+		}
+	}
+	return result
+}
+
+func renderBalances(amounts map[string]int64) []balance {
+	list := []balance{}
+	for currency, amount := range amounts {
+		if amount != 0 {
+			list = append(list, balance{Amount: formatCents(amount), CurrencyCode: currency})
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CurrencyCode < list[j].CurrencyCode })
+	return list
+}
+
+func (s *Server) renderExpense(e *expense) resources.Expense {
+	rendered := e.Expense
+	rendered.Users = append([]expenseShare(nil), e.Users...)
+	for i, u := range rendered.Users {
+		u.User = s.users[resources.UserID(u.UserId)]
+		rendered.Users[i] = u
+	}
+	return rendered
+}
+
+func (s *Server) renderGroup(g *group) resources.Group {
+	rendered := resources.Group{
+		ID:        resources.GroupID(g.id),
+		Name:      g.name,
+		Type:      "other",
+		CreatedAt: g.created,
+		UpdatedAt: g.created,
+	}
+
+	expenses := s.activeExpenses(func(e *expense) bool { return int(e.GroupId) == g.id })
+
+	nets := map[string]map[resources.UserID]int64{}
+	pairs := map[string]map[[2]resources.UserID]int64{}
+	for _, e := range expenses {
+		if nets[e.CurrencyCode] == nil {
+			nets[e.CurrencyCode] = map[resources.UserID]int64{}
+			pairs[e.CurrencyCode] = map[[2]resources.UserID]int64{}
+		}
+		for id, amount := range netShares(e) {
+			nets[e.CurrencyCode][id] += amount
+		}
+		for _, r := range e.Repayments {
+			amount, _ := parseCents(r.Amount)
+			from, to := resources.UserID(r.From), resources.UserID(r.To)
+			if from < to {
+				pairs[e.CurrencyCode][[2]resources.UserID{from, to}] += amount
+			} else {
+				pairs[e.CurrencyCode][[2]resources.UserID{to, from}] -= amount
+			}
+		}
+		if updated, err := time.Parse(time.RFC3339, e.UpdatedAt); err == nil && updated.After(rendered.UpdatedAt) {
+			rendered.UpdatedAt = updated
+		}
+	}
+
+	for _, id := range g.members {
+		member := s.users[id]
+		member.Balance = nil
+		for currency, net := range nets {
+			if net[id] != 0 {
+				member.Balance = append(member.Balance, balance{Amount: formatCents(net[id]), CurrencyCode: currency})
+			}
+		}
+		rendered.Members = append(rendered.Members, member)
+	}
+
+	currencies := make([]string, 0, len(nets))
+	for currency := range nets {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+
+	rendered.OriginalDebts = []resources.Debt{}
+	rendered.SimplifiedDebts = []resources.Debt{}
+	for _, currency := range currencies {
+		keys := make([][2]resources.UserID, 0, len(pairs[currency]))
+		for k := range pairs[currency] {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			return keys[i][0] < keys[j][0] || keys[i][0] == keys[j][0] && keys[i][1] < keys[j][1]
+		})
+		for _, k := range keys {
+			amount := pairs[currency][k]
+			from, to := k[0], k[1]
+			if amount < 0 {
+				from, to, amount = to, from, -amount
+			}
+			if amount != 0 {
+				rendered.OriginalDebts = append(rendered.OriginalDebts, resources.Debt{CurrencyCode: currency, From: int(from), To: int(to), Amount: formatCents(amount)})
+			}
+		}
+
+		for _, d := range settle(nets[currency]) {
+			rendered.SimplifiedDebts = append(rendered.SimplifiedDebts, resources.Debt{CurrencyCode: currency, From: int(d.from), To: int(d.to), Amount: formatCents(d.amount)})
+		}
+	}
+
+	return rendered
+}
+
+func (s *Server) renderFriend(id resources.UserID) resources.Friend {
+	user := s.users[id]
+	friend := resources.Friend{
+		ID:                 resources.FriendID(user.ID),
+		FirstName:          user.FirstName,
+		LastName:           user.LastName,
+		Email:              user.Email,
+		RegistrationStatus: user.RegistrationStatus,
+	}
+
+	involved := func(e *expense) bool {
+		for _, u := range e.Users {
+			if resources.UserID(u.UserId) == id {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, b := range renderBalances(s.balances(id, s.activeExpenses(involved))) {
+		friend.Balance = append(friend.Balance, friendBalance{CurrencyCode: b.CurrencyCode, Amount: b.Amount})
+	}
+
+	ids := make([]int, 0, len(s.groups))
+	for gid := range s.groups {
+		ids = append(ids, gid)
+	}
+	sort.Ints(ids)
+
+	for _, gid := range ids {
+		if !s.groups[gid].hasMember(id) {
+			continue
+		}
+		inGroup := s.activeExpenses(func(e *expense) bool { return int(e.GroupId) == gid && involved(e) })
+
+		g := struct {
+			GroupId int             `json:"group_id"`
+			Balance []friendBalance `json:"balance"`
+		}{GroupId: gid}
+		for _, b := range renderBalances(s.balances(id, inGroup)) {
+			g.Balance = append(g.Balance, friendBalance{CurrencyCode: b.CurrencyCode, Amount: b.Amount})
+		}
+		friend.Groups = append(friend.Groups, g)
+	}
+
+	return friend
+}
+
+func (g *group) hasMember(id resources.UserID) bool {
+	for _, m := range g.members {
+		if m == id {
+			return true
+		}
+	}
+	return false
+}
+
+func parseCents(amount string) (int64, error) {
+	amount = strings.TrimSpace(amount)
+	negative := strings.HasPrefix(amount, "-")
+	amount = strings.TrimPrefix(amount, "-")
+
+	units, fraction, _ := strings.Cut(amount, ".")
+	if units == "" {
+		units = "0"
+	}
+	if len(fraction) > 2 {
+		return 0, fmt.Errorf("too many decimals in %q", amount)
+	}
+	fraction = (fraction + "00")[:2]
+
+	u, err := strconv.ParseInt(units, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	f, err := strconv.ParseInt(fraction, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	cents := u*100 + f
+	if negative {
+		cents = -cents
+	}
+	return cents, nil
+}
+
+func formatCents(cents int64) string {
+	sign := ""
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, cents/100, cents%100)
+}
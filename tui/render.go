@@ -0,0 +1,214 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aanzolaavila/splitwise.go/resources"
+	"github.com/gdamore/tcell/v2"
+	"github.com/mattn/go-runewidth"
+)
+
+const (
+	groupsWidth   = 28
+	detailsHeight = 12
+)
+
+var (
+	styleDefault  = tcell.StyleDefault
+	styleTitle    = tcell.StyleDefault.Bold(true)
+	styleSelected = tcell.StyleDefault.Reverse(true)
+	styleDimmed   = tcell.StyleDefault.Foreground(tcell.ColorGray)
+	styleDeleted  = tcell.StyleDefault.Foreground(tcell.ColorGray).StrikeThrough(true)
+)
+
+func (a *App) draw() {
+	a.screen.Clear()
+	width, height := a.screen.Size()
+
+	if width < groupsWidth+20 || height < detailsHeight+6 {
+		drawText(a.screen, 0, 0, width, styleDefault, "terminal too small")
+		a.screen.Show()
+		return
+	}
+
+	listHeight := height - detailsHeight - 1
+
+	a.drawGroups(0, 0, groupsWidth, height-1)
+	a.drawExpenses(groupsWidth+1, 0, width-groupsWidth-1, listHeight)
+	a.drawDetails(groupsWidth+1, listHeight, width-groupsWidth-1, detailsHeight)
+	a.drawStatus(0, height-1, width)
+
+	a.screen.Show()
+}
+
+func (a *App) drawGroups(x int, y int, width int, height int) {
+	drawBox(a.screen, x, y, width, height, "Groups", a.focus == groupsPane)
+
+	for i, g := range a.groups {
+		row := y + 1 + i
+		if row >= y+height-1 {
+			break
+		}
+
+		style := styleDefault
+		if i == a.groupIdx {
+			style = styleSelected
+		}
+
+		label := g.Name
+		if i == a.loadedIdx {
+			label = "* " + label
+		} else {
+			label = "  " + label
+		}
+		drawText(a.screen, x+1, row, width-2, style, label)
+	}
+}
+
+func (a *App) drawExpenses(x int, y int, width int, height int) {
+	title := "Expenses"
+	if len(a.groups) > 0 {
+		title = fmt.Sprintf("Expenses - %s (%d%s)", a.groups[a.loadedIdx].Name, len(a.visible), a.moreMarker())
+	}
+	if a.filter != "" {
+		title += fmt.Sprintf(" filter: %q", a.filter)
+	}
+	drawBox(a.screen, x, y, width, height, title, a.focus == expensesPane)
+
+	rows := height - 2
+	if a.selected < a.top {
+		a.top = a.selected
+	}
+	if a.selected >= a.top+rows {
+		a.top = a.selected - rows + 1
+	}
+
+	for i := 0; i < rows && a.top+i < len(a.visible); i++ {
+		e := a.expenses[a.visible[a.top+i]]
+
+		style := styleDefault
+		if e.DeletedAt != "" {
+			style = styleDeleted
+		}
+		if a.top+i == a.selected && a.focus == expensesPane {
+			style = styleSelected
+		}
+
+		drawText(a.screen, x+1, y+1+i, width-2, style, expenseRow(e, width-2))
+	}
+}
+
+func (a *App) moreMarker() string {
+	if a.exhausted {
+		return ""
+	}
+	return "+"
+}
+
+func expenseRow(e resources.Expense, width int) string {
+	date := e.Date
+	if len(date) >= 10 {
+		date = date[:10]
+	}
+	amount := fmt.Sprintf("%s %s", e.Cost, e.CurrencyCode)
+
+	descWidth := width - len(date) - runewidth.StringWidth(amount) - 4
+	if descWidth < 4 {
+		descWidth = 4
+	}
+
+	desc := runewidth.Truncate(e.Description, descWidth, "…")
+	desc = runewidth.FillRight(desc, descWidth)
+	return fmt.Sprintf("%s  %s  %s", date, desc, amount)
+}
+
+func (a *App) drawDetails(x int, y int, width int, height int) {
+	drawBox(a.screen, x, y, width, height, "Details", false)
+
+	e := a.currentExpense()
+	if e == nil {
+		return
+	}
+
+	lines := []struct {
+		style tcell.Style
+		text  string
+	}{
+		{styleTitle, fmt.Sprintf("%s - %s %s", e.Description, e.Cost, e.CurrencyCode)},
+		{styleDimmed, fmt.Sprintf("%s  %s  by %s", e.Date, e.Category.Name, a.userName(uint64(e.CreatedBy.ID)))},
+	}
+
+	for _, u := range e.Users {
+		lines = append(lines, struct {
+			style tcell.Style
+			text  string
+		}{styleDefault, fmt.Sprintf("  %-20s paid %10s  owes %10s", a.userName(u.UserId), u.PaidShare, u.OwedShare)})
+	}
+
+	for _, c := range a.expenseComments(e) {
+		author := strings.TrimSpace(c.User.FirstName + " " + c.User.LastName)
+		lines = append(lines, struct {
+			style tcell.Style
+			text  string
+		}{styleDimmed, fmt.Sprintf("  %s: %s", author, c.Content)})
+	}
+
+	for i, l := range lines {
+		if i >= height-2 {
+			break
+		}
+		drawText(a.screen, x+1, y+1+i, width-2, l.style, l.text)
+	}
+}
+
+func (a *App) drawStatus(x int, y int, width int) {
+	switch a.mode {
+	case modeFilter:
+		drawText(a.screen, x, y, width, styleDefault, "filter: "+a.input+"_")
+	case modeQuickAdd:
+		drawText(a.screen, x, y, width, styleDefault, "add <description> <cost>: "+a.input+"_")
+	default:
+		help := "q quit  tab pane  enter open  / filter  a add  r reload"
+		text := help
+		if a.status != "" {
+			text = a.status + "  |  " + help
+		}
+		drawText(a.screen, x, y, width, styleDimmed, text)
+	}
+}
+
+func drawBox(s tcell.Screen, x int, y int, width int, height int, title string, focused bool) {
+	style := styleDimmed
+	if focused {
+		style = styleDefault
+	}
+
+	for i := x + 1; i < x+width-1; i++ {
+		s.SetContent(i, y, tcell.RuneHLine, nil, style)
+		s.SetContent(i, y+height-1, tcell.RuneHLine, nil, style)
+	}
+	for j := y + 1; j < y+height-1; j++ {
+		s.SetContent(x, j, tcell.RuneVLine, nil, style)
+		s.SetContent(x+width-1, j, tcell.RuneVLine, nil, style)
+	}
+	s.SetContent(x, y, tcell.RuneULCorner, nil, style)
+	s.SetContent(x+width-1, y, tcell.RuneURCorner, nil, style)
+	s.SetContent(x, y+height-1, tcell.RuneLLCorner, nil, style)
+	s.SetContent(x+width-1, y+height-1, tcell.RuneLRCorner, nil, style)
+
+	drawText(s, x+2, y, width-4, styleTitle, " "+title+" ")
+}
+
+// drawText writes text on one line, truncated to width cells.
+func drawText(s tcell.Screen, x int, y int, width int, style tcell.Style, text string) {
+	col := 0
+	for _, r := range text {
+		w := runewidth.RuneWidth(r)
+		if col+w > width {
+			break
+		}
+		s.SetContent(x+col, y, r, nil, style)
+		col += w
+	}
+}
@@ -0,0 +1,363 @@
+// Package tui is a terminal browser for the groups and expenses of a
+// Splitwise account, built on tcell so it needs no cgo.
+package tui
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aanzolaavila/splitwise.go"
+	"github.com/aanzolaavila/splitwise.go/resources"
+	"github.com/dcerbino-golib/smartsplitwise"
+	"github.com/gdamore/tcell/v2"
+)
+
+const DefaultPageSize = 30
+
+type pane int
+
+const (
+	groupsPane pane = iota
+	expensesPane
+)
+
+type inputMode int
+
+const (
+	modeNormal inputMode = iota
+	modeFilter
+	modeQuickAdd
+)
+
+type App struct {
+	// PageSize is the number of expenses requested per page and pulled
+	// from the executor each time the selection gets near the end.
+	PageSize int
+
+	conn   smartsplitwise.SwConnection
+	screen tcell.Screen
+
+	groups    []resources.Group
+	groupIdx  int
+	loadedIdx int
+	names     map[uint64]string
+
+	executor  smartsplitwise.CommandExecutor[resources.Expense]
+	exhausted bool
+	expenses  []resources.Expense
+	visible   []int
+	selected  int
+	top       int
+
+	comments map[resources.ExpenseID][]resources.Comment
+
+	focus  pane
+	mode   inputMode
+	filter string
+	input  string
+	status string
+}
+
+func New(conn smartsplitwise.SwConnection, screen tcell.Screen) *App {
+	return &App{
+		PageSize: DefaultPageSize,
+		conn:     conn,
+		screen:   screen,
+		names:    make(map[uint64]string),
+		comments: make(map[resources.ExpenseID][]resources.Comment),
+	}
+}
+
+// Run initializes the screen and processes key events until the user quits.
+func (a *App) Run() error {
+	if err := a.screen.Init(); err != nil {
+		return err
+	}
+	defer a.screen.Fini()
+
+	a.loadGroups()
+	a.draw()
+
+	for {
+		switch ev := a.screen.PollEvent().(type) {
+		case nil:
+			return nil
+		case *tcell.EventResize:
+			a.screen.Sync()
+		case *tcell.EventKey:
+			if !a.handleKey(ev) {
+				a.closeExecutor()
+				return nil
+			}
+		}
+		a.draw()
+	}
+}
+
+func (a *App) loadGroups() {
+	a.status = "loading groups..."
+	a.draw()
+
+	friends, err := smartsplitwise.Collect(a.conn.GetFriends())
+	if err != nil {
+		a.status = "unable to load friends: " + err.Error()
+		return
+	}
+	for _, f := range friends {
+		a.names[uint64(f.ID)] = strings.TrimSpace(f.FirstName + " " + f.LastName)
+	}
+
+	a.groups, err = smartsplitwise.Collect(a.conn.GetGroups())
+	if err != nil {
+		a.status = "unable to load groups: " + err.Error()
+		return
+	}
+	for _, g := range a.groups {
+		for _, m := range g.Members {
+			a.names[uint64(m.ID)] = strings.TrimSpace(m.FirstName + " " + m.LastName)
+		}
+	}
+
+	a.status = fmt.Sprintf("%d groups", len(a.groups))
+	if len(a.groups) > 0 {
+		a.selectGroup(0)
+	}
+}
+
+func (a *App) selectGroup(idx int) {
+	a.closeExecutor()
+
+	a.groupIdx = idx
+	a.loadedIdx = idx
+	a.expenses = nil
+	a.visible = nil
+	a.selected = 0
+	a.top = 0
+	a.exhausted = false
+
+	params := splitwise.ExpensesParams{
+		splitwise.ExpensesGroupId: int(a.groups[idx].ID),
+		splitwise.ExpensesLimit:   a.PageSize,
+	}
+	a.executor = a.conn.GetExpenses(params)
+	a.loadMore()
+}
+
+// loadMore pulls the next page from the executor, which fetches from the
+// API only as the channel is drained.
+func (a *App) loadMore() {
+	if a.exhausted || a.executor == nil {
+		return
+	}
+
+	for i := 0; i < a.PageSize; i++ {
+		e, ok := <-a.executor.GetChan()
+		if !ok {
+			a.exhausted = true
+			if err := a.executor.Err(); err != nil {
+				a.status = "unable to load expenses: " + err.Error()
+			}
+			break
+		}
+		a.expenses = append(a.expenses, e)
+	}
+
+	a.applyFilter()
+}
+
+func (a *App) closeExecutor() {
+	if a.executor != nil && !a.exhausted {
+		a.executor.Close()
+	}
+	a.executor = nil
+}
+
+func (a *App) applyFilter() {
+	a.visible = a.visible[:0]
+	needle := strings.ToLower(a.filter)
+	for i, e := range a.expenses {
+		if needle == "" || strings.Contains(strings.ToLower(e.Description), needle) {
+			a.visible = append(a.visible, i)
+		}
+	}
+
+	if a.selected >= len(a.visible) {
+		a.selected = len(a.visible) - 1
+	}
+	if a.selected < 0 {
+		a.selected = 0
+	}
+}
+
+func (a *App) currentExpense() *resources.Expense {
+	if a.selected < 0 || a.selected >= len(a.visible) {
+		return nil
+	}
+	return &a.expenses[a.visible[a.selected]]
+}
+
+// handleKey applies a key press and reports whether the app keeps running.
+func (a *App) handleKey(ev *tcell.EventKey) bool {
+	if a.mode != modeNormal {
+		a.handleInput(ev)
+		return true
+	}
+
+	switch ev.Key() {
+	case tcell.KeyCtrlC:
+		return false
+	case tcell.KeyEscape:
+		a.filter = ""
+		a.applyFilter()
+	case tcell.KeyTab, tcell.KeyRight, tcell.KeyLeft:
+		if a.focus == groupsPane {
+			a.focus = expensesPane
+		} else {
+			a.focus = groupsPane
+		}
+	case tcell.KeyUp:
+		a.move(-1)
+	case tcell.KeyDown:
+		a.move(1)
+	case tcell.KeyPgUp:
+		a.move(-a.PageSize / 2)
+	case tcell.KeyPgDn:
+		a.move(a.PageSize / 2)
+	case tcell.KeyEnter:
+		if a.focus == groupsPane && len(a.groups) > 0 {
+			a.selectGroup(a.groupIdx)
+			a.focus = expensesPane
+		}
+	case tcell.KeyRune:
+		switch ev.Rune() {
+		case 'q':
+			return false
+		case 'k':
+			a.move(-1)
+		case 'j':
+			a.move(1)
+		case '/':
+			a.mode = modeFilter
+			a.input = a.filter
+		case 'a':
+			if len(a.groups) > 0 {
+				a.mode = modeQuickAdd
+				a.input = ""
+			}
+		case 'r':
+			if len(a.groups) > 0 {
+				a.selectGroup(a.loadedIdx)
+			}
+		}
+	}
+
+	return true
+}
+
+func (a *App) move(delta int) {
+	if a.focus == groupsPane {
+		a.groupIdx = clamp(a.groupIdx+delta, 0, len(a.groups)-1)
+		return
+	}
+
+	a.selected = clamp(a.selected+delta, 0, len(a.visible)-1)
+	if a.selected >= len(a.visible)-a.PageSize/3 {
+		a.loadMore()
+	}
+}
+
+func (a *App) handleInput(ev *tcell.EventKey) {
+	switch ev.Key() {
+	case tcell.KeyEscape:
+		a.mode = modeNormal
+		a.input = ""
+	case tcell.KeyEnter:
+		mode := a.mode
+		a.mode = modeNormal
+		if mode == modeFilter {
+			a.filter = a.input
+			a.applyFilter()
+		} else {
+			a.quickAdd(a.input)
+		}
+		a.input = ""
+	case tcell.KeyBackspace, tcell.KeyBackspace2:
+		if r := []rune(a.input); len(r) > 0 {
+			a.input = string(r[:len(r)-1])
+		}
+	case tcell.KeyRune:
+		a.input += string(ev.Rune())
+	}
+}
+
+// parseQuickAdd splits "<description> <cost>" as typed in the quick-add
+// prompt, accepting a comma as decimal separator. The cost has no currency:
+// the expense takes the default currency of the user.
+func parseQuickAdd(input string) (string, smartsplitwise.Money, error) {
+	fields := strings.Fields(input)
+	if len(fields) < 2 {
+		return "", smartsplitwise.Money{}, fmt.Errorf("expected \"<description> <cost>\"")
+	}
+
+	cost, err := smartsplitwise.ParseMoney(strings.Replace(fields[len(fields)-1], ",", ".", 1), "")
+	if err != nil || cost.Sign() <= 0 {
+		return "", smartsplitwise.Money{}, fmt.Errorf("invalid cost %q", fields[len(fields)-1])
+	}
+
+	return strings.Join(fields[:len(fields)-1], " "), cost.Round(), nil
+}
+
+func (a *App) quickAdd(input string) {
+	description, cost, err := parseQuickAdd(input)
+	if err != nil {
+		a.status = err.Error()
+		return
+	}
+
+	group := a.groups[a.loadedIdx]
+	created, err := a.conn.CreateExpenseEqualGroupSplit(cost.Float64(), description, int(group.ID), splitwise.CreateExpenseParams{})
+	if err != nil {
+		a.status = "unable to add expense: " + err.Error()
+		return
+	}
+
+	a.expenses = append(created, a.expenses...)
+	a.applyFilter()
+	a.selected = 0
+	a.status = fmt.Sprintf("added %q to %s", description, group.Name)
+}
+
+func (a *App) expenseComments(e *resources.Expense) []resources.Comment {
+	if e.CommentsCount == 0 {
+		return nil
+	}
+
+	if comments, ok := a.comments[e.ID]; ok {
+		return comments
+	}
+
+	comments, err := a.conn.GetExpenseComments(int(e.ID))
+	if err != nil {
+		a.status = "unable to load comments: " + err.Error()
+	}
+	a.comments[e.ID] = comments
+	return comments
+}
+
+func (a *App) userName(id uint64) string {
+	if name, ok := a.names[id]; ok && name != "" {
+		return name
+	}
+	return strconv.FormatUint(id, 10)
+}
+
+func clamp(v int, min int, max int) int {
+	if v > max {
+		v = max
+	}
+	if v < min {
+		v = min
+	}
+	return v
+}
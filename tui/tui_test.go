@@ -0,0 +1,149 @@
+package tui
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aanzolaavila/splitwise.go/resources"
+	"github.com/dcerbino-golib/smartsplitwise"
+	"github.com/dcerbino-golib/smartsplitwise/splitwisetest"
+	"github.com/gdamore/tcell/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestApp(t *testing.T) (*App, tcell.SimulationScreen) {
+	screen := tcell.NewSimulationScreen("UTF-8")
+	assert.NoError(t, screen.Init())
+	screen.SetSize(100, 30)
+	t.Cleanup(screen.Fini)
+
+	app := New(nil, screen)
+	app.groups = []resources.Group{{ID: 1, Name: "Familia"}, {ID: 2, Name: "Fundación"}}
+	app.names[21623741] = "test1 test"
+	app.exhausted = true
+
+	for i, desc := range []string{"Jumbo", "Fiambre", "Jumbo", "Pedidoya"} {
+		e := resources.Expense{ID: resources.ExpenseID(i + 1)}
+		e.Description = desc
+		e.Cost = "100.0"
+		e.CurrencyCode = "ARS"
+		e.Date = "2023-01-09T14:41:00Z"
+		app.expenses = append(app.expenses, e)
+	}
+	app.expenses[0].Users = append(app.expenses[0].Users, struct {
+		resources.User
+		UserId     uint64 `json:"user_id"`
+		PaidShare  string `json:"paid_share"`
+		OwedShare  string `json:"owed_share"`
+		NetBalance string `json:"net_balance"`
+	}{UserId: 21623741, PaidShare: "100.0", OwedShare: "50.0"})
+	app.applyFilter()
+
+	return app, screen
+}
+
+func screenText(screen tcell.SimulationScreen) string {
+	cells, width, _ := screen.GetContents()
+
+	b := strings.Builder{}
+	for i, c := range cells {
+		if len(c.Runes) > 0 {
+			b.WriteRune(c.Runes[0])
+		}
+		if (i+1)%width == 0 {
+			b.WriteByte('\n')
+		}
+	}
+	return b.String()
+}
+
+func TestDrawPanes(t *testing.T) {
+	app, screen := newTestApp(t)
+	app.draw()
+
+	text := screenText(screen)
+	assert.Contains(t, text, "Familia")
+	assert.Contains(t, text, "Fundación")
+	assert.Contains(t, text, "Expenses - Familia (4)")
+	assert.Contains(t, text, "Pedidoya")
+	assert.Contains(t, text, "test1 test")
+	assert.Contains(t, text, "owes       50.0")
+}
+
+func TestFilterKeys(t *testing.T) {
+	app, screen := newTestApp(t)
+	app.focus = expensesPane
+
+	keys := []*tcell.EventKey{tcell.NewEventKey(tcell.KeyRune, '/', tcell.ModNone)}
+	for _, r := range "jum" {
+		keys = append(keys, tcell.NewEventKey(tcell.KeyRune, r, tcell.ModNone))
+	}
+	keys = append(keys, tcell.NewEventKey(tcell.KeyEnter, 0, tcell.ModNone))
+
+	for _, k := range keys {
+		assert.True(t, app.handleKey(k))
+	}
+
+	assert.Equal(t, "jum", app.filter)
+	assert.Equal(t, []int{0, 2}, app.visible)
+
+	app.handleKey(tcell.NewEventKey(tcell.KeyDown, 0, tcell.ModNone))
+	assert.Equal(t, resources.ExpenseID(3), app.currentExpense().ID)
+
+	app.draw()
+	assert.NotContains(t, screenText(screen), "Pedidoya")
+
+	app.handleKey(tcell.NewEventKey(tcell.KeyEscape, 0, tcell.ModNone))
+	assert.Len(t, app.visible, 4)
+
+	assert.False(t, app.handleKey(tcell.NewEventKey(tcell.KeyRune, 'q', tcell.ModNone)))
+}
+
+func TestParseQuickAdd(t *testing.T) {
+	desc, cost, err := parseQuickAdd("Pizza con amigos 1200,50")
+	assert.NoError(t, err)
+	assert.Equal(t, "Pizza con amigos", desc)
+	assert.Equal(t, "1200.50", cost.Amount())
+
+	_, _, err = parseQuickAdd("Pizza")
+	assert.Error(t, err)
+
+	_, _, err = parseQuickAdd("Pizza free")
+	assert.Error(t, err)
+
+	_, _, err = parseQuickAdd("Pizza 1e3")
+	assert.Error(t, err)
+}
+
+func TestLoadFailuresShowInStatus(t *testing.T) {
+	server := splitwisetest.NewServer()
+	defer server.Close()
+	home := server.AddGroup("Home")
+
+	screen := tcell.NewSimulationScreen("UTF-8")
+	assert.NoError(t, screen.Init())
+	screen.SetSize(100, 30)
+	t.Cleanup(screen.Fini)
+
+	conn := smartsplitwise.OpenWithOptions(smartsplitwise.WithBaseURL(server.URL, ""))
+
+	groups := splitwisetest.ServerError()
+	groups.Path = "/get_groups"
+	groups.Times = 1
+	server.Inject(groups)
+
+	app := New(conn, screen)
+	app.loadGroups()
+	assert.Contains(t, app.status, "unable to load groups")
+	assert.Empty(t, app.groups)
+
+	expenses := splitwisetest.ServerError()
+	expenses.Path = "/get_expenses"
+	server.Inject(expenses)
+
+	app.loadGroups()
+	if assert.Len(t, app.groups, 1) {
+		assert.Equal(t, home.ID, app.groups[0].ID)
+	}
+	assert.Contains(t, app.status, "unable to load expenses")
+}
@@ -0,0 +1,187 @@
+package smartsplitwise
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/aanzolaavila/splitwise.go"
+	"github.com/aanzolaavila/splitwise.go/resources"
+)
+
+type BalanceSource string
+
+const (
+	// SourceMemberBalance is the balance of each member of the group.
+	SourceMemberBalance BalanceSource = "balance"
+	// SourceOriginalDebts is the net balance derived from the original,
+	// unsimplified debts of the group.
+	SourceOriginalDebts BalanceSource = "original_debts"
+)
+
+// MemberNet is the net balance of a member in a currency: positive when the
+// group owes them.
+type MemberNet struct {
+	UserID       uint64 `json:"user_id"`
+	CurrencyCode string `json:"currency_code"`
+	Amount       string `json:"amount"`
+}
+
+type BalanceMismatch struct {
+	UserID       uint64        `json:"user_id"`
+	CurrencyCode string        `json:"currency_code"`
+	Source       BalanceSource `json:"source"`
+	Reported     string        `json:"reported"`
+	Computed     string        `json:"computed"`
+	Difference   string        `json:"difference"`
+	// Expenses are the expenses contributing to the computed balance.
+	Expenses []resources.ExpenseID `json:"expenses"`
+}
+
+type BalanceVerification struct {
+	GroupID    resources.GroupID `json:"group_id"`
+	Computed   []MemberNet       `json:"computed"`
+	Mismatches []BalanceMismatch `json:"mismatches"`
+}
+
+func (v BalanceVerification) OK() bool {
+	return len(v.Mismatches) == 0
+}
+
+type netKey struct {
+	currency string
+	user     uint64
+}
+
+type nets map[netKey]Money
+
+func (n nets) add(key netKey, amount Money) {
+	n[key], _ = n[key].Add(amount)
+}
+
+// VerifyBalances replays expenses, payments included and deleted ones
+// excluded, to compute the net balance of every member of group per
+// currency, and compares it with the balances and original debts the group
+// reports. Amounts are compared exactly, so balances in the millions do not
+// drift. An expense whose shares cannot be read fails the verification.
+func VerifyBalances(group resources.Group, expenses []resources.Expense) (BalanceVerification, error) {
+	computed := nets{}
+	contributing := map[netKey][]resources.ExpenseID{}
+
+	for _, e := range expenses {
+		if e.DeletedAt != "" || int(e.GroupId) != int(group.ID) {
+			continue
+		}
+
+		shares, err := ExpenseShares(e)
+		if err != nil {
+			return BalanceVerification{}, fmt.Errorf("verify group %d: %w", group.ID, err)
+		}
+		for _, share := range shares {
+			if share.Net.IsZero() {
+				continue
+			}
+
+			key := netKey{e.CurrencyCode, share.UserID}
+			computed.add(key, share.Net)
+			contributing[key] = append(contributing[key], e.ID)
+		}
+	}
+
+	balances := nets{}
+	for _, m := range group.Members {
+		amounts, _ := UserBalances(m)
+		for _, amount := range amounts {
+			balances.add(netKey{amount.Currency(), uint64(m.ID)}, amount)
+		}
+	}
+
+	debts := nets{}
+	for _, d := range group.OriginalDebts {
+		amount, err := DebtAmount(d)
+		if err != nil {
+			continue
+		}
+		debts.add(netKey{d.CurrencyCode, uint64(d.To)}, amount)
+		debts.add(netKey{d.CurrencyCode, uint64(d.From)}, amount.Neg())
+	}
+
+	for _, ids := range contributing {
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	}
+	keys := sortedNetKeys(computed, balances, debts)
+
+	verification := BalanceVerification{GroupID: group.ID, Computed: []MemberNet{}, Mismatches: []BalanceMismatch{}}
+	for _, key := range keys {
+		net := computed[key]
+		if !net.IsZero() {
+			verification.Computed = append(verification.Computed, MemberNet{UserID: key.user, CurrencyCode: key.currency, Amount: net.Amount()})
+		}
+
+		for _, reported := range []struct {
+			source BalanceSource
+			nets   nets
+		}{{SourceMemberBalance, balances}, {SourceOriginalDebts, debts}} {
+			amount := reported.nets[key]
+			if amount.Equal(net) {
+				continue
+			}
+
+			difference, _ := amount.Sub(net)
+			verification.Mismatches = append(verification.Mismatches, BalanceMismatch{
+				UserID:       key.user,
+				CurrencyCode: key.currency,
+				Source:       reported.source,
+				Reported:     withCurrency(amount, key.currency).Amount(),
+				Computed:     withCurrency(net, key.currency).Amount(),
+				Difference:   withCurrency(difference, key.currency).Amount(),
+				Expenses:     contributing[key],
+			})
+		}
+	}
+
+	return verification, nil
+}
+
+// withCurrency gives the zero Money the currency of the key, so it prints
+// with the decimals of the currency.
+func withCurrency(m Money, currency string) Money {
+	if m.Currency() == "" {
+		m.currency = currency
+	}
+	return m
+}
+
+// VerifyGroup fetches a group and its expenses and verifies its balances.
+func VerifyGroup(conn SwConnection, groupID int) (BalanceVerification, error) {
+	group, err := conn.GetGroup(groupID)
+	if err != nil {
+		return BalanceVerification{}, fmt.Errorf("verify group %d: %w", groupID, err)
+	}
+
+	expenses, err := Collect(conn.GetExpenses(splitwise.ExpensesParams{splitwise.ExpensesGroupId: groupID}))
+	if err != nil {
+		return BalanceVerification{}, fmt.Errorf("verify group %d: %w", groupID, err)
+	}
+	return VerifyBalances(group, expenses)
+}
+
+func sortedNetKeys(all ...nets) []netKey {
+	seen := map[netKey]bool{}
+	var keys []netKey
+	for _, n := range all {
+		for key := range n {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].currency != keys[j].currency {
+			return keys[i].currency < keys[j].currency
+		}
+		return keys[i].user < keys[j].user
+	})
+	return keys
+}
@@ -0,0 +1,120 @@
+package smartsplitwise
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aanzolaavila/splitwise.go"
+	"github.com/aanzolaavila/splitwise.go/resources"
+	"github.com/dcerbino-golib/smartsplitwise/splitwisetest"
+	"github.com/stretchr/testify/assert"
+)
+
+// newARSGroup seeds a group with a large rent, a partial payment and a
+// deleted expense, in ARS.
+func newARSGroup(t *testing.T) (*splitwisetest.Server, SwConnection, resources.Group) {
+	server := splitwisetest.NewServer()
+	server.Currency = "ARS"
+	t.Cleanup(server.Close)
+
+	me := server.CurrentUser()
+	ana := server.AddUser("Ana", "B", "ana@example.com")
+	familia := server.AddGroup("Familia", ana.ID)
+	now := time.Now()
+
+	for _, seed := range []struct {
+		description string
+		cost        string
+		shares      []splitwisetest.Share
+	}{
+		{"Rent", "2500000.33", []splitwisetest.Share{
+			{UserID: me.ID, Paid: "2500000.33", Owed: "1250000.17"},
+			{UserID: ana.ID, Paid: "0", Owed: "1250000.16"},
+		}},
+		{"Payment", "1000000", []splitwisetest.Share{
+			{UserID: ana.ID, Paid: "1000000", Owed: "0"},
+			{UserID: me.ID, Paid: "0", Owed: "1000000"},
+		}},
+		{"Wrong", "999", []splitwisetest.Share{
+			{UserID: me.ID, Paid: "999", Owed: "0"},
+			{UserID: ana.ID, Paid: "0", Owed: "999"},
+		}},
+	} {
+		_, err := server.AddExpense(int(familia.ID), seed.description, seed.cost, now, seed.shares...)
+		assert.NoError(t, err)
+	}
+
+	conn := OpenWithOptions(WithBaseURL(server.URL, ""))
+	assert.NoError(t, conn.DeleteExpense(6))
+
+	return server, conn, familia
+}
+
+func TestVerifyGroup(t *testing.T) {
+	_, conn, familia := newARSGroup(t)
+
+	verification, err := VerifyGroup(conn, int(familia.ID))
+	assert.NoError(t, err)
+	assert.True(t, verification.OK(), "%+v", verification.Mismatches)
+	assert.Equal(t, []MemberNet{
+		{UserID: 1, CurrencyCode: "ARS", Amount: "250000.16"},
+		{UserID: 2, CurrencyCode: "ARS", Amount: "-250000.16"},
+	}, verification.Computed)
+}
+
+func TestVerifyBalancesMismatches(t *testing.T) {
+	_, conn, familia := newARSGroup(t)
+
+	group, err := conn.GetGroup(int(familia.ID))
+	assert.NoError(t, err)
+	expenses, err := Collect(conn.GetExpenses(splitwise.ExpensesParams{}))
+	assert.NoError(t, err)
+
+	group.Members[0].Balance[0].Amount = "250000.17"
+	group.OriginalDebts = nil
+
+	verification, err := VerifyBalances(group, expenses)
+	assert.NoError(t, err)
+	assert.False(t, verification.OK())
+	assert.Equal(t, []BalanceMismatch{
+		{UserID: 1, CurrencyCode: "ARS", Source: SourceMemberBalance, Reported: "250000.17", Computed: "250000.16", Difference: "0.01", Expenses: []resources.ExpenseID{4, 5}},
+		{UserID: 1, CurrencyCode: "ARS", Source: SourceOriginalDebts, Reported: "0.00", Computed: "250000.16", Difference: "-250000.16", Expenses: []resources.ExpenseID{4, 5}},
+		{UserID: 2, CurrencyCode: "ARS", Source: SourceOriginalDebts, Reported: "0.00", Computed: "-250000.16", Difference: "250000.16", Expenses: []resources.ExpenseID{4, 5}},
+	}, verification.Mismatches)
+}
+
+func TestVerifyGroupNotFound(t *testing.T) {
+	_, conn, _ := newARSGroup(t)
+
+	_, err := VerifyGroup(conn, 99)
+	assert.ErrorContains(t, err, "verify group 99")
+}
+
+func TestVerifyGroupFailsOnUnreadableExpenses(t *testing.T) {
+	server, conn, familia := newARSGroup(t)
+
+	fault := splitwisetest.ServerError()
+	fault.Path = "/get_expenses"
+	server.Inject(fault)
+
+	_, err := VerifyGroup(conn, int(familia.ID))
+	assert.ErrorContains(t, err, "verify group")
+}
+
+func TestVerifyBalancesFailsOnUnreadableShares(t *testing.T) {
+	_, conn, familia := newARSGroup(t)
+
+	group, err := conn.GetGroup(int(familia.ID))
+	assert.NoError(t, err)
+	expenses, err := Collect(conn.GetExpenses(splitwise.ExpensesParams{}))
+	assert.NoError(t, err)
+
+	for i := range expenses {
+		if expenses[i].DeletedAt == "" {
+			expenses[i].Users[0].PaidShare = "lots"
+			break
+		}
+	}
+	_, err = VerifyBalances(group, expenses)
+	assert.ErrorContains(t, err, "paid share")
+}
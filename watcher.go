@@ -0,0 +1,340 @@
+package smartsplitwise
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aanzolaavila/splitwise.go"
+	"github.com/aanzolaavila/splitwise.go/resources"
+)
+
+const (
+	DefaultWatcherMinInterval = 30 * time.Second
+	DefaultWatcherMaxInterval = 10 * time.Minute
+)
+
+type NotificationHandler func(ev NotificationEvent)
+
+type NotificationFilter func(ev NotificationEvent) bool
+
+func FilterByType(types ...NotificationEventType) NotificationFilter {
+	return func(ev NotificationEvent) bool {
+		for _, t := range types {
+			if ev.Type == t {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func FilterByGroup(names ...string) NotificationFilter {
+	return func(ev NotificationEvent) bool {
+		for _, n := range names {
+			if strings.EqualFold(ev.GroupName, n) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// WatcherCursor is the persisted position of a NotificationWatcher. Seen
+// keeps the IDs already dispatched at or after UpdatedAfter, so a restart
+// never delivers the same notification twice.
+type WatcherCursor struct {
+	UpdatedAfter time.Time                              `json:"updated_after"`
+	Seen         map[resources.NotificationID]time.Time `json:"seen"`
+}
+
+type CursorStore interface {
+	Load() (WatcherCursor, error)
+	Save(cursor WatcherCursor) error
+}
+
+type FileCursorStore struct {
+	Path string
+}
+
+func (s FileCursorStore) Load() (WatcherCursor, error) {
+	cursor := WatcherCursor{}
+
+	content, err := os.ReadFile(s.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cursor, nil
+	}
+	if err != nil {
+		return cursor, err
+	}
+
+	err = json.Unmarshal(content, &cursor)
+	return cursor, err
+}
+
+func (s FileCursorStore) Save(cursor WatcherCursor) error {
+	content, err := json.Marshal(cursor)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.Path), filepath.Base(s.Path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.Path)
+}
+
+type registeredHandler struct {
+	handler NotificationHandler
+	filters []NotificationFilter
+}
+
+type NotificationWatcher struct {
+	MinInterval time.Duration
+	MaxInterval time.Duration
+	Limit       int
+
+	conn     SwConnection
+	store    CursorStore
+	mu       sync.Mutex
+	handlers []registeredHandler
+	cursor   *WatcherCursor
+	// dispatching counts the polls still running their handlers; the cursor
+	// is saved once none is.
+	dispatching int
+}
+
+func NewNotificationWatcher(conn SwConnection, store CursorStore) *NotificationWatcher {
+	return &NotificationWatcher{
+		MinInterval: DefaultWatcherMinInterval,
+		MaxInterval: DefaultWatcherMaxInterval,
+		conn:        conn,
+		store:       store,
+	}
+}
+
+// Handle registers h for every new notification that passes all filters.
+func (w *NotificationWatcher) Handle(h NotificationHandler, filters ...NotificationFilter) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.handlers = append(w.handlers, registeredHandler{handler: h, filters: filters})
+}
+
+// Poll fetches the notifications updated after the cursor once, dispatches
+// the ones not seen before and persists the cursor. It returns the number
+// of new notifications. Handlers run after the watcher is unlocked, so they
+// may call Handle or Poll themselves.
+//
+// Delivery is at least once: the cursor is persisted only after the
+// handlers return, so the notifications being dispatched when the process
+// dies are dispatched again after a restart.
+func (w *NotificationWatcher) Poll() (int, error) {
+	events, handlers, err := w.advance()
+	if len(events) == 0 {
+		return 0, err
+	}
+
+	for _, ev := range events {
+		dispatch(handlers, ev)
+	}
+
+	return len(events), w.commit()
+}
+
+// advance reads the new notifications, moves the cursor in memory past them
+// and returns them oldest first along with the handlers to dispatch them
+// to. A poll running meanwhile skips them.
+func (w *NotificationWatcher) advance() ([]NotificationEvent, []registeredHandler, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.loadCursor(); err != nil {
+		return nil, nil, err
+	}
+
+	notifications, err := w.fetch()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var fresh []resources.Notification
+	for _, n := range notifications {
+		if _, ok := w.cursor.Seen[n.ID]; ok || n.CreatedAt.Before(w.cursor.UpdatedAfter) {
+			continue
+		}
+		fresh = append(fresh, n)
+	}
+
+	if len(fresh) == 0 {
+		return nil, nil, nil
+	}
+
+	sort.SliceStable(fresh, func(i, j int) bool {
+		return fresh[i].CreatedAt.Before(fresh[j].CreatedAt)
+	})
+
+	events := make([]NotificationEvent, 0, len(fresh))
+	for _, n := range fresh {
+		events = append(events, ParseNotification(n))
+		w.cursor.Seen[n.ID] = n.CreatedAt
+		if n.CreatedAt.After(w.cursor.UpdatedAfter) {
+			w.cursor.UpdatedAfter = n.CreatedAt
+		}
+	}
+
+	for id, createdAt := range w.cursor.Seen {
+		if createdAt.Before(w.cursor.UpdatedAfter) {
+			delete(w.cursor.Seen, id)
+		}
+	}
+
+	w.dispatching++
+	handlers := append([]registeredHandler(nil), w.handlers...)
+	return events, handlers, nil
+}
+
+// commit persists the cursor once the last poll still dispatching is done,
+// so it never covers notifications whose handlers have not returned.
+func (w *NotificationWatcher) commit() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.dispatching--
+	if w.dispatching > 0 {
+		return nil
+	}
+	return w.store.Save(*w.cursor)
+}
+
+// fetch reads the notifications updated after the cursor. The API returns
+// the newest ones first and has no offset, so while a full page has not
+// reached the cursor yet the read is repeated with twice the limit. Without
+// a cursor a single page is read.
+func (w *NotificationWatcher) fetch() ([]resources.Notification, error) {
+	params := splitwise.NotificationsParams{}
+	if !w.cursor.UpdatedAfter.IsZero() {
+		params[splitwise.NotificationsUpdatedAfter] = w.cursor.UpdatedAfter.Format(time.RFC3339)
+	}
+
+	limit := w.Limit
+	for {
+		if limit > 0 {
+			params[splitwise.NotificationsLimit] = limit
+		}
+
+		notifications, err := Collect(w.conn.GetNotifications(params))
+		if err != nil {
+			return nil, err
+		}
+
+		if limit <= 0 || len(notifications) < limit || w.cursor.UpdatedAfter.IsZero() || w.reached(notifications) {
+			return notifications, nil
+		}
+		limit *= 2
+	}
+}
+
+// reached reports whether the page goes back to a notification the cursor
+// already covers.
+func (w *NotificationWatcher) reached(notifications []resources.Notification) bool {
+	for _, n := range notifications {
+		if _, ok := w.cursor.Seen[n.ID]; ok || !n.CreatedAt.After(w.cursor.UpdatedAfter) {
+			return true
+		}
+	}
+	return false
+}
+
+// Run polls until ctx is done. The interval starts at MinInterval, doubles
+// after every poll without news up to MaxInterval, and resets as soon as a
+// new notification arrives.
+func (w *NotificationWatcher) Run(ctx context.Context) error {
+	minInterval, maxInterval := w.MinInterval, w.MaxInterval
+	if minInterval <= 0 {
+		minInterval = DefaultWatcherMinInterval
+	}
+	if maxInterval <= 0 {
+		maxInterval = DefaultWatcherMaxInterval
+	}
+	if maxInterval < minInterval {
+		maxInterval = minInterval
+	}
+	interval := minInterval
+
+	for {
+		count, err := w.Poll()
+		if err != nil {
+			connLogger(w.conn).Warn("notification watcher poll failed", slog.String("error", err.Error()))
+		}
+
+		if count > 0 {
+			interval = minInterval
+		} else {
+			interval *= 2
+			if interval > maxInterval {
+				interval = maxInterval
+			}
+		}
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (w *NotificationWatcher) loadCursor() error {
+	if w.cursor != nil {
+		return nil
+	}
+
+	cursor, err := w.store.Load()
+	if err != nil {
+		return err
+	}
+	if cursor.Seen == nil {
+		cursor.Seen = make(map[resources.NotificationID]time.Time)
+	}
+
+	w.cursor = &cursor
+	return nil
+}
+
+func dispatch(handlers []registeredHandler, ev NotificationEvent) {
+	for _, h := range handlers {
+		if acceptsEvent(h.filters, ev) {
+			h.handler(ev)
+		}
+	}
+}
+
+func acceptsEvent(filters []NotificationFilter, ev NotificationEvent) bool {
+	for _, f := range filters {
+		if !f(ev) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,248 @@
+package smartsplitwise
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dcerbino-golib/smartsplitwise/splitwisetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func getNotificationsDoFunc(t *testing.T, calls *int32, bodies *[]string) func(r *http.Request) (*http.Response, error) {
+	notifications := cassetteBody(t, "notifications", "/api/v3.0/get_notifications")
+
+	return func(r *http.Request) (*http.Response, error) {
+		atomic.AddInt32(calls, 1)
+		if bodies != nil && r.Body != nil {
+			content, _ := io.ReadAll(r.Body)
+			*bodies = append(*bodies, string(content))
+		}
+
+		resposne := http.Response{}
+		resposne.Body = io.NopCloser(strings.NewReader(notifications))
+		resposne.Header = make(map[string][]string)
+		resposne.Header["Content-Type"] = []string{"application/json", "charset=utf-8"}
+		resposne.Status = "200"
+		resposne.StatusCode = 200
+		return &resposne, nil
+	}
+}
+
+func TestWatcherPollDedupe(t *testing.T) {
+	var calls int32
+	var bodies []string
+	conn := getClientMockedConnection(t, getNotificationsDoFunc(t, &calls, &bodies))
+
+	store := FileCursorStore{Path: filepath.Join(t.TempDir(), "cursor.json")}
+	watcher := NewNotificationWatcher(conn, store)
+
+	var all, added, family []NotificationEvent
+	watcher.Handle(func(ev NotificationEvent) { all = append(all, ev) })
+	watcher.Handle(func(ev NotificationEvent) { added = append(added, ev) }, FilterByType(ExpenseAdded))
+	watcher.Handle(func(ev NotificationEvent) { family = append(family, ev) }, FilterByGroup("familia cerbino rosso"))
+
+	count, err := watcher.Poll()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count)
+	assert.Len(t, all, 3)
+	assert.Len(t, added, 2)
+	assert.Len(t, family, 1)
+	assert.Equal(t, "Pedidoya", all[0].Subject, "events should be dispatched oldest first")
+
+	count, err = watcher.Poll()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, count)
+	assert.Len(t, all, 3)
+	assert.Contains(t, bodies[1], `"updated_after":"2023-04-11T12:15:53Z"`)
+
+	cursor, err := store.Load()
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2023, 4, 11, 12, 15, 53, 0, time.UTC), cursor.UpdatedAfter.UTC())
+	assert.Len(t, cursor.Seen, 1)
+
+	restarted := NewNotificationWatcher(conn, store)
+	restarted.Handle(func(ev NotificationEvent) { all = append(all, ev) })
+	count, err = restarted.Poll()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, count)
+	assert.Len(t, all, 3)
+	assert.Equal(t, int32(3), calls)
+}
+
+func TestWatcherRun(t *testing.T) {
+	var calls int32
+	conn := getClientMockedConnection(t, getNotificationsDoFunc(t, &calls, nil))
+
+	watcher := NewNotificationWatcher(conn, FileCursorStore{Path: filepath.Join(t.TempDir(), "cursor.json")})
+	watcher.MinInterval = time.Millisecond
+	watcher.MaxInterval = 4 * time.Millisecond
+
+	var received int32
+	watcher.Handle(func(ev NotificationEvent) { atomic.AddInt32(&received, 1) })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err := watcher.Run(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&received))
+	assert.Greater(t, atomic.LoadInt32(&calls), int32(1))
+}
+
+func TestWatcherWithFakeServer(t *testing.T) {
+	server := splitwisetest.NewServer()
+	defer server.Close()
+
+	// every write happens a second after the previous one
+	clock := time.Date(2023, 3, 1, 10, 0, 0, 0, time.UTC)
+	server.Now = func() time.Time {
+		clock = clock.Add(time.Second)
+		return clock
+	}
+
+	ana := server.AddUser("Ana", "B", "ana@example.com")
+	home := server.AddGroup("Home", ana.ID)
+
+	conn := OpenWithOptions(WithBaseURL(server.URL, ""), WithLogger(getTestLogger(t)))
+	watcher := NewNotificationWatcher(conn, FileCursorStore{Path: filepath.Join(t.TempDir(), "cursor.json")})
+
+	events := []NotificationEvent{}
+	watcher.Handle(func(ev NotificationEvent) { events = append(events, ev) })
+
+	created, err := conn.CreateExpenseEqualGroupSplit(30, "Groceries", int(home.ID), nil)
+	assert.NoError(t, err)
+	_, err = conn.UpdateExpense(int(created[0].ID), 40, "Groceries", int(home.ID), nil, nil)
+	assert.NoError(t, err)
+
+	count, err := watcher.Poll()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	assert.NoError(t, conn.DeleteExpense(int(created[0].ID)))
+
+	count, err = watcher.Poll()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	count, err = watcher.Poll()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, count)
+
+	assert.Equal(t, []NotificationEventType{ExpenseAdded, ExpenseUpdated, ExpenseDeleted}, []NotificationEventType{events[0].Type, events[1].Type, events[2].Type})
+	assert.Equal(t, "Home", events[2].GroupName)
+}
+
+func TestWatcherHandlersMayUseTheWatcher(t *testing.T) {
+	var calls int32
+	conn := getClientMockedConnection(t, getNotificationsDoFunc(t, &calls, nil))
+	watcher := NewNotificationWatcher(conn, FileCursorStore{Path: filepath.Join(t.TempDir(), "cursor.json")})
+
+	var nested []int
+	watcher.Handle(func(ev NotificationEvent) {
+		watcher.Handle(func(ev NotificationEvent) {})
+		count, err := watcher.Poll()
+		assert.NoError(t, err)
+		nested = append(nested, count)
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		count, err := watcher.Poll()
+		assert.NoError(t, err)
+		assert.Equal(t, 3, count)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("a handler calling the watcher deadlocked")
+	}
+	assert.Equal(t, []int{0, 0, 0}, nested)
+}
+
+func TestWatcherLimitPagesToTheCursor(t *testing.T) {
+	server := splitwisetest.NewServer()
+	defer server.Close()
+
+	clock := time.Date(2023, 3, 1, 10, 0, 0, 0, time.UTC)
+	server.Now = func() time.Time {
+		clock = clock.Add(time.Second)
+		return clock
+	}
+
+	ana := server.AddUser("Ana", "B", "ana@example.com")
+	home := server.AddGroup("Home", ana.ID)
+
+	conn := OpenWithOptions(WithBaseURL(server.URL, ""), WithLogger(getTestLogger(t)))
+	watcher := NewNotificationWatcher(conn, FileCursorStore{Path: filepath.Join(t.TempDir(), "cursor.json")})
+	watcher.Limit = 2
+
+	var descriptions []string
+	watcher.Handle(func(ev NotificationEvent) { descriptions = append(descriptions, ev.Subject) })
+
+	_, err := conn.CreateExpenseEqualGroupSplit(10, "first", int(home.ID), nil)
+	assert.NoError(t, err)
+	count, err := watcher.Poll()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	for _, d := range []string{"second", "third", "fourth", "fifth", "sixth"} {
+		_, err := conn.CreateExpenseEqualGroupSplit(10, d, int(home.ID), nil)
+		assert.NoError(t, err)
+	}
+
+	count, err = watcher.Poll()
+	assert.NoError(t, err)
+	assert.Equal(t, 5, count)
+	assert.Len(t, descriptions, 6)
+	for i, d := range []string{"first", "second", "third", "fourth", "fifth", "sixth"} {
+		if i < len(descriptions) {
+			assert.Contains(t, descriptions[i], d)
+		}
+	}
+}
+
+func TestWatcherSavesCursorAfterDispatch(t *testing.T) {
+	var calls int32
+	conn := getClientMockedConnection(t, getNotificationsDoFunc(t, &calls, nil))
+
+	store := FileCursorStore{Path: filepath.Join(t.TempDir(), "cursor.json")}
+	watcher := NewNotificationWatcher(conn, store)
+
+	var stored []int
+	watcher.Handle(func(ev NotificationEvent) {
+		cursor, err := store.Load()
+		assert.NoError(t, err)
+		stored = append(stored, len(cursor.Seen))
+	})
+
+	count, err := watcher.Poll()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count)
+	assert.Equal(t, []int{0, 0, 0}, stored, "the cursor should not be saved while handlers run")
+
+	cursor, err := store.Load()
+	assert.NoError(t, err)
+	assert.Len(t, cursor.Seen, 1)
+}
+
+func TestWatcherRunDefaultsZeroIntervals(t *testing.T) {
+	var calls int32
+	conn := getClientMockedConnection(t, getNotificationsDoFunc(t, &calls, nil))
+
+	watcher := &NotificationWatcher{conn: conn, store: FileCursorStore{Path: filepath.Join(t.TempDir(), "cursor.json")}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err := watcher.Run(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
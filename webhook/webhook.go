@@ -0,0 +1,306 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dcerbino-golib/smartsplitwise"
+)
+
+const (
+	SignatureHeader = "X-Smartsplitwise-Signature"
+	EventHeader     = "X-Smartsplitwise-Event"
+	DeliveryHeader  = "X-Smartsplitwise-Delivery"
+
+	DefaultMaxAttempts = 5
+	DefaultBaseDelay   = time.Second
+	DefaultMaxDelay    = time.Minute
+)
+
+type Event struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	SourceID  uint64    `json:"source_id,omitempty"`
+	Actor     string    `json:"actor,omitempty"`
+	Subject   string    `json:"subject,omitempty"`
+	GroupName string    `json:"group_name,omitempty"`
+	Amount    string    `json:"amount,omitempty"`
+	Currency  string    `json:"currency,omitempty"`
+	Direction string    `json:"direction,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func NewEvent(ev smartsplitwise.NotificationEvent) Event {
+	return Event{
+		ID:        strconv.FormatUint(uint64(ev.Notification.ID), 10),
+		Type:      ev.Type.String(),
+		SourceID:  uint64(ev.Notification.Source.ID),
+		Actor:     ev.Actor,
+		Subject:   ev.Subject,
+		GroupName: ev.GroupName,
+		Amount:    ev.Amount,
+		Currency:  ev.Currency,
+		Direction: ev.Direction.String(),
+		CreatedAt: ev.Notification.CreatedAt,
+	}
+}
+
+// Endpoint is a webhook target. Empty filters accept every event.
+// MinAmounts maps a currency, as the notifications show it such as "$" or
+// "€", to a decimal such as "10.50"; events whose amount is lower, missing,
+// or in a currency without a threshold are dropped.
+type Endpoint struct {
+	URL        string
+	Secret     string
+	Groups     []string
+	Types      []smartsplitwise.NotificationEventType
+	MinAmounts map[string]string
+}
+
+func (e Endpoint) Accepts(ev Event) bool {
+	if len(e.Groups) > 0 && !containsFold(e.Groups, ev.GroupName) {
+		return false
+	}
+
+	if len(e.Types) > 0 {
+		found := false
+		for _, t := range e.Types {
+			if t.String() == ev.Type {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if len(e.MinAmounts) > 0 {
+		threshold, ok := e.MinAmounts[ev.Currency]
+		if !ok {
+			return false
+		}
+		min, errMin := smartsplitwise.ParseMoney(threshold, ev.Currency)
+		amount, err := smartsplitwise.ParseMoney(ev.Amount, ev.Currency)
+		if errMin != nil || err != nil {
+			return false
+		}
+		if c, _ := amount.Cmp(min); c < 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+type Dispatcher struct {
+	Endpoints  []Endpoint
+	HttpClient *http.Client
+	// Retry is how failed deliveries are retried; only network errors,
+	// 429 and 5xx are.
+	Retry          smartsplitwise.RetryPolicy
+	DeadLetterPath string
+	// Logger, when set, logs the retries and the failed deliveries.
+	Logger *slog.Logger
+
+	mu sync.Mutex
+}
+
+func NewDispatcher(deadLetterPath string, endpoints ...Endpoint) *Dispatcher {
+	return &Dispatcher{
+		Endpoints:  endpoints,
+		HttpClient: &http.Client{Timeout: 10 * time.Second},
+		Retry: smartsplitwise.RetryPolicy{
+			MaxAttempts: DefaultMaxAttempts,
+			BaseDelay:   DefaultBaseDelay,
+			MaxDelay:    DefaultMaxDelay,
+		},
+		DeadLetterPath: deadLetterPath,
+	}
+}
+
+// NotificationHandler adapts the dispatcher to a NotificationWatcher.
+func (d *Dispatcher) NotificationHandler(ctx context.Context) smartsplitwise.NotificationHandler {
+	return func(ev smartsplitwise.NotificationEvent) {
+		event := NewEvent(ev)
+		if err := d.Dispatch(ctx, event); err != nil && d.Logger != nil {
+			d.Logger.Warn("webhook delivery failed", slog.String("event", event.ID), slog.String("error", err.Error()))
+		}
+	}
+}
+
+// Dispatch delivers ev to every endpoint accepting it. Deliveries that keep
+// failing after Retry.MaxAttempts are written to the dead-letter file and
+// reported in the returned error.
+func (d *Dispatcher) Dispatch(ctx context.Context, ev Event) error {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, endpoint := range d.Endpoints {
+		if !endpoint.Accepts(ev) {
+			continue
+		}
+
+		attempts, err := d.deliver(ctx, endpoint, ev, body)
+		if err == nil {
+			continue
+		}
+
+		errs = append(errs, fmt.Errorf("%s: %w", endpoint.URL, err))
+		if dlErr := d.deadLetter(endpoint, ev, attempts, err); dlErr != nil {
+			errs = append(errs, dlErr)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, endpoint Endpoint, ev Event, body []byte) (int, error) {
+	maxAttempts := d.Retry.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = 1
+	}
+
+	var err error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		var retry bool
+		retry, err = d.post(ctx, endpoint, ev, body)
+		if err == nil {
+			return attempt, nil
+		}
+		if !retry || attempt == maxAttempts {
+			return attempt, err
+		}
+
+		delay := d.Retry.Backoff(attempt)
+		if d.Logger != nil {
+			d.Logger.Info("retrying webhook",
+				slog.String("endpoint", endpoint.URL),
+				slog.String("event", ev.ID),
+				slog.Int("attempt", attempt),
+				slog.Duration("delay", delay),
+				slog.String("error", err.Error()),
+			)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return attempt, ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	return maxAttempts, err
+}
+
+// post sends one delivery and reports whether a failure is worth retrying.
+func (d *Dispatcher) post(ctx context.Context, endpoint Endpoint, ev Event, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, ev.Type)
+	req.Header.Set(DeliveryHeader, ev.ID)
+	if endpoint.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(endpoint.Secret, body))
+	}
+
+	client := d.HttpClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(io.Discard, res.Body)
+
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return false, nil
+	}
+
+	retry := res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500
+	return retry, fmt.Errorf("unexpected status %s", res.Status)
+}
+
+type deadLetterEntry struct {
+	Endpoint string    `json:"endpoint"`
+	Event    Event     `json:"event"`
+	Attempts int       `json:"attempts"`
+	Error    string    `json:"error"`
+	FailedAt time.Time `json:"failed_at"`
+}
+
+func (d *Dispatcher) deadLetter(endpoint Endpoint, ev Event, attempts int, cause error) error {
+	if d.DeadLetterPath == "" {
+		return nil
+	}
+
+	line, err := json.Marshal(deadLetterEntry{
+		Endpoint: endpoint.URL,
+		Event:    ev,
+		Attempts: attempts,
+		Error:    cause.Error(),
+		FailedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	f, err := os.OpenFile(d.DeadLetterPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
+
+// Sign returns the signature header value for body: "sha256=" followed by
+// the hex HMAC-SHA256 of body keyed with secret.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a signature header value in constant time, for receivers.
+func Verify(secret string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
+
+func containsFold(values []string, v string) bool {
+	for _, s := range values {
+		if strings.EqualFold(s, v) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,153 @@
+package webhook
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aanzolaavila/splitwise.go/resources"
+	"github.com/dcerbino-golib/smartsplitwise"
+	"github.com/stretchr/testify/assert"
+)
+
+func testEvent() Event {
+	n := resources.Notification{
+		ID:        7559274538,
+		Type:      0,
+		CreatedAt: time.Date(2023, 4, 11, 1, 51, 9, 0, time.UTC),
+		Content:   "<strong>testf A.</strong> ha aggiunto <strong>“TelViso”</strong> nel gruppo <strong>“Fundación ”</strong>.<br><font color=\"#ff652f\">Devi dare 1.024,25 $</font>",
+	}
+	n.Source.ID = 2292261592
+	n.Source.Type = "Expense"
+
+	return NewEvent(smartsplitwise.ParseNotification(n))
+}
+
+func TestDispatchSigned(t *testing.T) {
+	var received Event
+	var calls int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		body, _ := io.ReadAll(r.Body)
+
+		assert.True(t, Verify("s3cret", body, r.Header.Get(SignatureHeader)))
+		assert.Equal(t, "expense_added", r.Header.Get(EventHeader))
+		assert.Equal(t, "7559274538", r.Header.Get(DeliveryHeader))
+		assert.NoError(t, json.Unmarshal(body, &received))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	d := NewDispatcher("", Endpoint{URL: server.URL, Secret: "s3cret"})
+
+	err := d.Dispatch(context.Background(), testEvent())
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), calls)
+	assert.Equal(t, "TelViso", received.Subject)
+	assert.Equal(t, "Fundación", received.GroupName)
+	assert.Equal(t, "1024.25", received.Amount)
+	assert.Equal(t, "you_owe", received.Direction)
+	assert.Equal(t, uint64(2292261592), received.SourceID)
+}
+
+func TestDispatchRetry(t *testing.T) {
+	var calls int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	deadLetter := filepath.Join(t.TempDir(), "dead.jsonl")
+	d := NewDispatcher(deadLetter, Endpoint{URL: server.URL})
+	d.Retry.BaseDelay = time.Millisecond
+
+	err := d.Dispatch(context.Background(), testEvent())
+	assert.NoError(t, err)
+	assert.Equal(t, int32(3), calls)
+	assert.NoFileExists(t, deadLetter)
+
+}
+
+func TestDispatchDeadLetter(t *testing.T) {
+	var failing, rejecting int32
+
+	failingServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&failing, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failingServer.Close()
+
+	rejectingServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&rejecting, 1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer rejectingServer.Close()
+
+	deadLetter := filepath.Join(t.TempDir(), "dead.jsonl")
+	d := NewDispatcher(deadLetter, Endpoint{URL: failingServer.URL}, Endpoint{URL: rejectingServer.URL})
+	d.Retry.MaxAttempts = 3
+	d.Retry.BaseDelay = time.Millisecond
+
+	err := d.Dispatch(context.Background(), testEvent())
+	assert.Error(t, err)
+	assert.Equal(t, int32(3), failing)
+	assert.Equal(t, int32(1), rejecting, "client errors should not be retried")
+
+	f, err := os.Open(deadLetter)
+	assert.NoError(t, err)
+	defer f.Close()
+
+	var entries []deadLetterEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		entry := deadLetterEntry{}
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &entry))
+		entries = append(entries, entry)
+	}
+
+	assert.Len(t, entries, 2)
+	assert.Equal(t, failingServer.URL, entries[0].Endpoint)
+	assert.Equal(t, 3, entries[0].Attempts)
+	assert.Equal(t, rejectingServer.URL, entries[1].Endpoint)
+	assert.Equal(t, 1, entries[1].Attempts)
+	assert.Equal(t, "7559274538", entries[1].Event.ID)
+}
+
+func TestEndpointFilters(t *testing.T) {
+	ev := testEvent()
+
+	testCases := []struct {
+		Endpoint Endpoint
+		Accepts  bool
+	}{
+		{Endpoint{}, true},
+		{Endpoint{Groups: []string{"fundación"}}, true},
+		{Endpoint{Groups: []string{"Familia"}}, false},
+		{Endpoint{Types: []smartsplitwise.NotificationEventType{smartsplitwise.ExpenseAdded}}, true},
+		{Endpoint{Types: []smartsplitwise.NotificationEventType{smartsplitwise.PaymentRecorded}}, false},
+		{Endpoint{MinAmounts: map[string]string{"$": "1000"}}, true},
+		{Endpoint{MinAmounts: map[string]string{"$": "5000"}}, false},
+		{Endpoint{MinAmounts: map[string]string{"$": "1024.25"}}, true},
+		{Endpoint{MinAmounts: map[string]string{"$": "1024.26"}}, false},
+		{Endpoint{MinAmounts: map[string]string{"$": "5000", "€": "1"}}, false},
+		{Endpoint{MinAmounts: map[string]string{"€": "1"}}, false},
+	}
+
+	for i, tc := range testCases {
+		assert.Equal(t, tc.Accepts, tc.Endpoint.Accepts(ev), "case %d", i)
+	}
+}